@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/export"
+	"github.com/SinghaAnirban005/KuBudget/pkg/logging"
+	"github.com/SinghaAnirban005/KuBudget/services"
+)
+
+// runExport implements `kubudget export`, which writes allocation rows for
+// the configured clusters to a file or stdout and exits.
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+
+	var overrides internal.Overrides
+	configPath := flags.String("config", os.Getenv("KUBUDGET_CONFIG"), "path to the YAML config file")
+	flags.StringVar(&overrides.PrometheusURL, "prometheus-url", "", "Prometheus base URL")
+	flags.StringVar(&overrides.KubeConfigPath, "kubeconfig", "", "path to a kubeconfig file")
+	format := flags.String("format", export.FormatCSV, "output format: csv, ndjson or parquet")
+	columnSpec := flags.String("columns", "", "comma-separated columns to include (default all)")
+	window := flags.Duration("window", time.Hour, "length of the window the costs cover")
+	cluster := flags.String("cluster", "", "export only this cluster")
+	currency := flags.String("currency", "", "ISO 4217 currency to convert costs into (default the base currency)")
+	output := flags.String("output", "-", "output file, - for stdout")
+	flags.Parse(args)
+
+	columns, err := export.ParseColumns(*columnSpec)
+	if err != nil {
+		return err
+	}
+	if *window <= 0 {
+		return fmt.Errorf("window must be positive")
+	}
+
+	cfg, err := internal.LoadConfig(*configPath, overrides)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	// Logs go to stderr so that they never mix with data written to stdout.
+	logger, err := logging.New(os.Stderr, cfg.Logging.Format, slog.LevelWarn)
+	if err != nil {
+		return err
+	}
+
+	configStore := internal.NewConfigStore(cfg)
+	clusterService, err := services.NewClusterService(configStore, logger)
+	if err != nil {
+		return fmt.Errorf("failed to create cluster clients: %v", err)
+	}
+	clusters, err := clusterService.Select(*cluster)
+	if err != nil {
+		return err
+	}
+
+	converter, err := clusterService.Converter(*currency)
+	if err != nil {
+		return err
+	}
+	if cfg.Currency.ExchangeRates.Enabled {
+		exchangeRateService, err := services.NewExchangeRateService(clusterService, configStore, logger)
+		if err != nil {
+			return fmt.Errorf("failed to load exchange rates: %v", err)
+		}
+		if err := exchangeRateService.Reload(context.Background()); err != nil {
+			return fmt.Errorf("failed to load exchange rates: %v", err)
+		}
+	}
+	end := time.Now()
+	rate, err := converter.Rate(end.Add(-*window))
+	if err != nil {
+		return err
+	}
+
+	namespaces, err := clusterService.GetNamespaceCosts(context.Background(), clusters)
+	if err != nil {
+		return fmt.Errorf("failed to get allocations: %v", err)
+	}
+	for i := range namespaces {
+		namespaces[i].Scale(rate)
+	}
+
+	out := os.Stdout
+	if *output != "-" {
+		if out, err = os.Create(*output); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	buffered := bufio.NewWriter(out)
+
+	writer, err := export.NewWriter(*format, buffered, columns)
+	if err != nil {
+		return err
+	}
+	rounding := cfg.Currency.Presentation()
+	for row := range services.AllocationRows(namespaces, *window, end, converter.Currency, cfg.PricingFor) {
+		row.Round(rounding)
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+
+	if out != os.Stdout {
+		return out.Close()
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"github.com/SinghaAnirban005/KuBudget/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type CommitmentHandler struct {
+	commitmentService *services.CommitmentService
+	logger            *slog.Logger
+}
+
+func NewCommitmentHandler(commitmentService *services.CommitmentService, logger *slog.Logger) *CommitmentHandler {
+	return &CommitmentHandler{
+		commitmentService: commitmentService,
+		logger:            logger,
+	}
+}
+
+// GetRecommendations returns reserved instance and savings plan purchase
+// options for each configured window of node history.
+func (h *CommitmentHandler) GetRecommendations(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	recommendations, err := h.commitmentService.Recommend(ctx)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to recommend commitments", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to recommend commitments",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(recommendations)
+}
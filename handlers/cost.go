@@ -1,97 +1,304 @@
 package handlers
 
 import (
+	"errors"
+	"log/slog"
 	"strconv"
 	"time"
 
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/auth"
 	"github.com/SinghaAnirban005/KuBudget/services"
 	"github.com/gofiber/fiber/v2"
 )
 
 type CostHandler struct {
-	costService *services.CostService
+	clusterService      *services.ClusterService
+	externalCostService *services.ExternalCostService
+	logger              *slog.Logger
 }
 
-func NewCostHandler(costService *services.CostService) *CostHandler {
+// NewCostHandler creates a cost handler. externalCostService is nil when
+// external costs are disabled.
+func NewCostHandler(clusterService *services.ClusterService, externalCostService *services.ExternalCostService, logger *slog.Logger) *CostHandler {
 	return &CostHandler{
-		costService: costService,
+		clusterService:      clusterService,
+		externalCostService: externalCostService,
+		logger:              logger,
 	}
 }
 
 func (h *CostHandler) GetCostOverview(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 
-	overview, err := h.costService.GetCostOverview(ctx)
+	clusters, err := selectClusters(c, h.clusterService)
+	if err != nil {
+		return err
+	}
 
+	converter, err := selectConverter(c, h.clusterService)
 	if err != nil {
+		return err
+	}
+
+	overview, err := h.clusterService.GetCostOverview(ctx, clusters)
+
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to get cost overview", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "Failed to get cost overview",
 			"details": err.Error(),
 		})
 	}
 
+	rate, err := conversionRate(converter, overview.Timestamp)
+	if err != nil {
+		return err
+	}
+	overview = scopeOverview(auth.FromCtx(c).Scope, overview)
+	overview.Scale(rate)
+	overview.Round(h.clusterService.Rounding())
+	overview.Currency = converter.Currency
+
 	return c.JSON(overview)
 }
 
+func (h *CostHandler) GetClusterCosts(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	clusters, err := selectClusters(c, h.clusterService)
+	if err != nil {
+		return err
+	}
+
+	converter, err := selectConverter(c, h.clusterService)
+	if err != nil {
+		return err
+	}
+
+	var costs []internal.ClusterCost
+	if scope := auth.FromCtx(c).Scope; scope.ClusterWide() {
+		costs = h.clusterService.GetClusterCosts(ctx, clusters)
+	} else {
+		namespaces, err := h.clusterService.GetNamespaceCosts(ctx, clusters)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to get cluster costs", "error", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "Failed to get cluster costs",
+				"details": err.Error(),
+			})
+		}
+
+		namespaces = scope.FilterNamespaces(namespaces)
+		costs = make([]internal.ClusterCost, 0, len(clusters))
+		for _, cluster := range clusters {
+			costs = append(costs, rollupVisible(cluster.Name, namespaces))
+		}
+	}
+
+	now := time.Now()
+	rate, err := conversionRate(converter, now)
+	if err != nil {
+		return err
+	}
+	rounding := h.clusterService.Rounding()
+	for i := range costs {
+		costs[i].Scale(rate)
+		costs[i].Round(rounding)
+	}
+
+	return c.JSON(fiber.Map{
+		"cluster_costs": costs,
+		"currency":      converter.Currency,
+		"count":         len(costs),
+		"timestamp":     now,
+	})
+}
+
 func (h *CostHandler) GetNamespaceCosts(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 
-	costs, err := h.costService.GetNamespaceCosts(ctx)
+	clusters, err := selectClusters(c, h.clusterService)
 	if err != nil {
+		return err
+	}
+
+	converter, err := selectConverter(c, h.clusterService)
+	if err != nil {
+		return err
+	}
+
+	costs, err := h.clusterService.GetNamespaceCosts(ctx, clusters)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to get namespace costs", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "Failed to get namespace costs",
 			"details": err.Error(),
 		})
 	}
+	costs = auth.FromCtx(c).Scope.FilterNamespaces(costs)
+
+	if aggregate := c.Query("aggregate", ""); aggregate != "" {
+		return h.getAggregatedCosts(c, costs, aggregate, converter)
+	}
+
+	now := time.Now()
+	rate, err := conversionRate(converter, now)
+	if err != nil {
+		return err
+	}
+	rounding := h.clusterService.Rounding()
+	for i := range costs {
+		costs[i].Scale(rate)
+		costs[i].Round(rounding)
+	}
 
 	return c.JSON(fiber.Map{
 		"namespace_costs": costs,
+		"currency":        converter.Currency,
 		"count":           len(costs),
-		"timestamp":       time.Now(),
+		"timestamp":       now,
 	})
 
 }
 
+// getAggregatedCosts groups namespaces and the active external costs by
+// aggregate. External costs are in the base currency too, so both are
+// converted after aggregation.
+func (h *CostHandler) getAggregatedCosts(c *fiber.Ctx, namespaces []internal.NamespaceCost, aggregate string, converter *services.CurrencyConverter) error {
+	now := time.Now()
+	var external []internal.ExternalCost
+	if h.externalCostService != nil {
+		external = visibleExternalCosts(auth.FromCtx(c).Scope, c.Query("cluster", ""), h.externalCostService.Active(now))
+	}
+
+	costs, err := services.AggregateNamespaces(namespaces, external, aggregate)
+	if errors.Is(err, services.ErrInvalidAggregate) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid aggregate parameter",
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	rate, err := conversionRate(converter, now)
+	if err != nil {
+		return err
+	}
+	rounding := h.clusterService.Rounding()
+	for i := range costs {
+		costs[i].Scale(rate)
+		costs[i].Round(rounding)
+	}
+
+	return c.JSON(fiber.Map{
+		"aggregate": aggregate,
+		"costs":     costs,
+		"currency":  converter.Currency,
+		"count":     len(costs),
+		"timestamp": now,
+	})
+}
+
 func (h *CostHandler) GetPodCosts(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 	namespace := c.Query("namespace", "default")
+	if err := validateNames(namespace, ""); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid namespace parameter",
+			"details": err.Error(),
+		})
+	}
+
+	clusters, err := selectClusters(c, h.clusterService)
+	if err != nil {
+		return err
+	}
 
-	costs, err := h.costService.GetPodCosts(ctx, namespace)
+	converter, err := selectConverter(c, h.clusterService)
 	if err != nil {
+		return err
+	}
+
+	costs, err := h.clusterService.GetPodCosts(ctx, clusters, namespace)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to get pod costs", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "Failed to get pod costs",
 			"details": err.Error(),
 		})
 	}
 
+	costs = auth.FromCtx(c).Scope.FilterPods(costs)
+
+	now := time.Now()
+	rate, err := conversionRate(converter, now)
+	if err != nil {
+		return err
+	}
+	rounding := h.clusterService.Rounding()
+	for i := range costs {
+		costs[i].Scale(rate)
+		costs[i].Round(rounding)
+	}
+
 	return c.JSON(fiber.Map{
 		"pod_costs": costs,
 		"namespace": namespace,
+		"currency":  converter.Currency,
 		"count":     len(costs),
-		"timestamp": time.Now(),
+		"timestamp": now,
 	})
 }
 
 func (h *CostHandler) GetNodeCosts(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
+
+	if !auth.FromCtx(c).Scope.ClusterWide() {
+		return insufficientScope(c)
+	}
+
+	clusters, err := selectClusters(c, h.clusterService)
+	if err != nil {
+		return err
+	}
+
+	converter, err := selectConverter(c, h.clusterService)
+	if err != nil {
+		return err
+	}
 
-	costs, err := h.costService.GetNodeCosts(ctx)
+	costs, err := h.clusterService.GetNodeCosts(ctx, clusters)
 	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to get node costs", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "Failed to get node costs",
 			"details": err.Error(),
 		})
 	}
 
+	now := time.Now()
+	rate, err := conversionRate(converter, now)
+	if err != nil {
+		return err
+	}
+	rounding := h.clusterService.Rounding()
+	for i := range costs {
+		costs[i].Scale(rate)
+		costs[i].Round(rounding)
+	}
+
 	return c.JSON(fiber.Map{
 		"node_costs": costs,
+		"currency":   converter.Currency,
 		"count":      len(costs),
-		"timestamp":  time.Now(),
+		"timestamp":  now,
 	})
 }
 
 func (h *CostHandler) GetCostHistory(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 
 	hoursStr := c.Query("hours", "24")
 	hours, err := strconv.Atoi(hoursStr)
@@ -110,14 +317,50 @@ func (h *CostHandler) GetCostHistory(c *fiber.Ctx) error {
 	}
 
 	namespace := c.Query("namespace", "")
+	if err := validateNames(namespace, ""); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid namespace parameter",
+			"details": err.Error(),
+		})
+	}
+	clusters, err := selectClusters(c, h.clusterService)
+	if err != nil {
+		return err
+	}
+	if !namespaceAllowed(auth.FromCtx(c).Scope, namespace, clusters...) {
+		return insufficientScope(c)
+	}
+
+	converter, err := selectConverter(c, h.clusterService)
+	if err != nil {
+		return err
+	}
 
-	history, err := h.costService.GetCostHistory(ctx, time.Duration(hours)*time.Hour, step, namespace)
+	history, err := h.clusterService.GetCostHistory(ctx, clusters, time.Duration(hours)*time.Hour, step, namespace, converter)
+	if errors.Is(err, services.ErrNoExchangeRate) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":   "No exchange rate for the requested currency",
+			"details": err.Error(),
+		})
+	}
 	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to get cost history", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "Failed to get cost history",
 			"details": err.Error(),
 		})
 	}
+	history.Round(h.clusterService.Rounding())
 
 	return c.JSON(history)
 }
+
+// selectClusters resolves the optional cluster query parameter. An empty
+// value selects every cluster; an unknown cluster is a 404.
+func selectClusters(c *fiber.Ctx, clusterService *services.ClusterService) ([]*services.Cluster, error) {
+	clusters, err := clusterService.Select(c.Query("cluster", ""))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	return clusters, nil
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// selectConverter resolves the optional currency query parameter. An empty
+// value keeps costs in the base currency; an invalid code is a 400.
+func selectConverter(c *fiber.Ctx, clusterService *services.ClusterService) (*services.CurrencyConverter, error) {
+	converter, err := clusterService.Converter(c.Query("currency", ""))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return converter, nil
+}
+
+// conversionRate returns the rate converter applies to costs at t. Costs
+// without a rate to convert them are a 422.
+func conversionRate(converter *services.CurrencyConverter, t time.Time) (float64, error) {
+	rate, err := converter.Rate(t)
+	if err != nil {
+		return 0, fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	}
+	return rate, nil
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/SinghaAnirban005/KuBudget/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type DiscountHandler struct {
+	discountService *services.DiscountService
+	logger          *slog.Logger
+}
+
+func NewDiscountHandler(discountService *services.DiscountService, logger *slog.Logger) *DiscountHandler {
+	return &DiscountHandler{
+		discountService: discountService,
+		logger:          logger,
+	}
+}
+
+// GetReport returns the list and effective rate of every node and how much
+// of each reservation and savings plan is used.
+func (h *DiscountHandler) GetReport(c *fiber.Ctx) error {
+	report, err := h.discountService.Report()
+	if errors.Is(err, services.ErrDiscountsNotComputed) {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Discounts have not been computed yet",
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(report)
+}
+
+// Refresh recomputes the rates from the current nodes.
+func (h *DiscountHandler) Refresh(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	report, err := h.discountService.Refresh(ctx)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to compute discounts", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to compute discounts",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(report)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/exchangerate"
+	"github.com/SinghaAnirban005/KuBudget/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ExchangeRateHandler struct {
+	exchangeRateService *services.ExchangeRateService
+	logger              *slog.Logger
+}
+
+func NewExchangeRateHandler(exchangeRateService *services.ExchangeRateService, logger *slog.Logger) *ExchangeRateHandler {
+	return &ExchangeRateHandler{
+		exchangeRateService: exchangeRateService,
+		logger:              logger,
+	}
+}
+
+// ListExchangeRates returns every rate, from files and the API, oldest
+// first.
+func (h *ExchangeRateHandler) ListExchangeRates(c *fiber.Ctx) error {
+	rates := h.exchangeRateService.List()
+
+	return c.JSON(fiber.Map{
+		"exchange_rates": rates,
+		"count":          len(rates),
+		"timestamp":      time.Now(),
+	})
+}
+
+// UploadExchangeRates adds the rates in the request body, which is CSV when
+// the content type is text/csv and JSON otherwise. A rate for the pair and
+// effective time of an existing one replaces it.
+func (h *ExchangeRateHandler) UploadExchangeRates(c *fiber.Ctx) error {
+	var rates []internal.ExchangeRate
+	var err error
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), "text/csv") {
+		rates, err = exchangerate.ParseCSV(bytes.NewReader(c.Body()))
+	} else {
+		rates, err = exchangerate.ParseJSON(bytes.NewReader(c.Body()))
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid exchange rates",
+			"details": err.Error(),
+		})
+	}
+
+	if err := h.exchangeRateService.Upload(rates); err != nil {
+		h.logger.ErrorContext(c.UserContext(), "failed to upload exchange rates", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to upload exchange rates",
+			"details": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"count": len(rates),
+	})
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/pkg/auth"
+	"github.com/SinghaAnirban005/KuBudget/pkg/export"
+	"github.com/SinghaAnirban005/KuBudget/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ExportHandler struct {
+	clusterService *services.ClusterService
+	logger         *slog.Logger
+}
+
+func NewExportHandler(clusterService *services.ClusterService, logger *slog.Logger) *ExportHandler {
+	return &ExportHandler{
+		clusterService: clusterService,
+		logger:         logger,
+	}
+}
+
+// GetSchema describes the columns of allocation exports.
+func (h *ExportHandler) GetSchema(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"columns": export.Columns,
+		"formats": export.Formats,
+	})
+}
+
+// ExportAllocations streams allocation rows in the requested format.
+// Allocation data is collected before the response starts so that failures
+// still produce an error status; rows are then encoded as they are written.
+func (h *ExportHandler) ExportAllocations(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	format := c.Query("format", export.FormatCSV)
+	if !slices.Contains(export.Formats, format) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid format parameter",
+		})
+	}
+
+	columns, err := export.ParseColumns(c.Query("columns", ""))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid columns parameter",
+			"details": err.Error(),
+		})
+	}
+
+	window, err := time.ParseDuration(c.Query("window", "1h"))
+	if err != nil || window <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid window parameter",
+		})
+	}
+
+	clusters, err := selectClusters(c, h.clusterService)
+	if err != nil {
+		return err
+	}
+
+	// Costs are converted at the rate in effect at the start of the window.
+	converter, err := selectConverter(c, h.clusterService)
+	if err != nil {
+		return err
+	}
+	end := time.Now()
+	rate, err := conversionRate(converter, end.Add(-window))
+	if err != nil {
+		return err
+	}
+
+	namespaces, err := h.clusterService.GetNamespaceCosts(ctx, clusters)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to get allocations for export", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to get allocations",
+			"details": err.Error(),
+		})
+	}
+	namespaces = auth.FromCtx(c).Scope.FilterNamespaces(namespaces)
+	for i := range namespaces {
+		namespaces[i].Scale(rate)
+	}
+
+	c.Set(fiber.HeaderContentType, export.ContentType(format))
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="allocations-%s.%s"`, end.UTC().Format("20060102T150405Z"), format))
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		writer, err := export.NewWriter(format, w, columns)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to start export", "error", err)
+			return
+		}
+		rounding := h.clusterService.Rounding()
+		for row := range services.AllocationRows(namespaces, window, end, converter.Currency, h.clusterService.PricingFor) {
+			row.Round(rounding)
+			if err := writer.Write(row); err != nil {
+				h.logger.ErrorContext(ctx, "export aborted", "error", err)
+				return
+			}
+		}
+		if err := writer.Close(); err != nil {
+			h.logger.ErrorContext(ctx, "failed to finish export", "error", err)
+			return
+		}
+		w.Flush()
+	})
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/externalcost"
+	"github.com/SinghaAnirban005/KuBudget/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ExternalCostHandler struct {
+	externalCostService *services.ExternalCostService
+	logger              *slog.Logger
+}
+
+func NewExternalCostHandler(externalCostService *services.ExternalCostService, logger *slog.Logger) *ExternalCostHandler {
+	return &ExternalCostHandler{
+		externalCostService: externalCostService,
+		logger:              logger,
+	}
+}
+
+// ListExternalCosts returns every external cost, or with active=true only
+// those whose time range contains the current time.
+func (h *ExternalCostHandler) ListExternalCosts(c *fiber.Ctx) error {
+	items := h.externalCostService.List()
+	if c.QueryBool("active", false) {
+		items = h.externalCostService.Active(time.Now())
+	}
+
+	return c.JSON(fiber.Map{
+		"external_costs": items,
+		"currency":       h.externalCostService.Currency(),
+		"count":          len(items),
+		"timestamp":      time.Now(),
+	})
+}
+
+// IngestExternalCosts adds the items in the request body, which is CSV when
+// the content type is text/csv and JSON otherwise. Items with the ID of an
+// existing item replace it.
+func (h *ExternalCostHandler) IngestExternalCosts(c *fiber.Ctx) error {
+	var items []internal.ExternalCost
+	var err error
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), "text/csv") {
+		items, err = externalcost.ParseCSV(bytes.NewReader(c.Body()))
+	} else {
+		items, err = externalcost.ParseJSON(bytes.NewReader(c.Body()))
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid external costs",
+			"details": err.Error(),
+		})
+	}
+
+	if err := h.externalCostService.Ingest(items); err != nil {
+		h.logger.ErrorContext(c.UserContext(), "failed to ingest external costs", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to ingest external costs",
+			"details": err.Error(),
+		})
+	}
+
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"ids":   ids,
+		"count": len(ids),
+	})
+}
+
+// DeleteExternalCost removes an item that was posted to the API.
+func (h *ExternalCostHandler) DeleteExternalCost(c *fiber.Ctx) error {
+	err := h.externalCostService.Delete(c.Params("id"))
+	if errors.Is(err, services.ErrExternalCostNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "External cost not found",
+		})
+	}
+	if err != nil {
+		h.logger.ErrorContext(c.UserContext(), "failed to delete external cost", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to delete external cost",
+			"details": err.Error(),
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/auth"
+	"github.com/SinghaAnirban005/KuBudget/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type FederationHandler struct {
+	clusterService    *services.ClusterService
+	federationService *services.FederationService
+	logger            *slog.Logger
+}
+
+func NewFederationHandler(clusterService *services.ClusterService, federationService *services.FederationService, logger *slog.Logger) *FederationHandler {
+	return &FederationHandler{
+		clusterService:    clusterService,
+		federationService: federationService,
+		logger:            logger,
+	}
+}
+
+// federationSourceKey holds the spoke Authenticate resolved the request's
+// token to.
+const federationSourceKey = "federation.source"
+
+// Authenticate rejects requests that do not carry a federation token as a
+// bearer token, and stores the spoke the token belongs to.
+func (h *FederationHandler) Authenticate(c *fiber.Ctx) error {
+	token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	source, valid := h.federationService.Authenticate(token)
+	if !ok || !valid {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid federation token",
+		})
+	}
+	c.Locals(federationSourceKey, source)
+	return c.Next()
+}
+
+// GetSnapshots serves this spoke's current snapshots for a hub to pull.
+func (h *FederationHandler) GetSnapshots(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	snapshots, err := h.federationService.BuildSnapshots(ctx)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to build snapshots", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to build snapshots",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(snapshots)
+}
+
+// PushSnapshots accepts snapshots pushed by a spoke, attributed to the
+// spoke whose token authenticated the request. Fiber transparently decodes
+// gzip request bodies.
+func (h *FederationHandler) PushSnapshots(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	var snapshots []internal.AllocationSnapshot
+	if err := c.BodyParser(&snapshots); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid snapshot payload",
+			"details": err.Error(),
+		})
+	}
+
+	source, _ := c.Locals(federationSourceKey).(string)
+	accepted, err := h.federationService.Ingest(source, snapshots)
+	if errors.Is(err, services.ErrInvalidSnapshot) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid snapshot",
+			"details": err.Error(),
+		})
+	}
+	if errors.Is(err, services.ErrSourceConflict) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "Cluster is reported by another spoke",
+			"details": err.Error(),
+		})
+	}
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to store snapshots", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to store snapshots",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"received":  len(snapshots),
+		"accepted":  accepted,
+		"timestamp": time.Now(),
+	})
+}
+
+// GetClusters lists every federated cluster with its freshness.
+func (h *FederationHandler) GetClusters(c *fiber.Ctx) error {
+	converter, err := selectConverter(c, h.clusterService)
+	if err != nil {
+		return err
+	}
+	view := scopeView(auth.FromCtx(c).Scope, h.federationService.View(converter))
+	view.Round(h.clusterService.Rounding())
+
+	return c.JSON(fiber.Map{
+		"clusters":  view.Clusters,
+		"currency":  view.Currency,
+		"count":     len(view.Clusters),
+		"stale":     view.Stale,
+		"timestamp": view.Timestamp,
+	})
+}
+
+// GetOverview returns the unified cost view across all federated clusters.
+// Spokes may report in different base currencies; each snapshot is
+// converted at the rate in effect when it was generated.
+func (h *FederationHandler) GetOverview(c *fiber.Ctx) error {
+	converter, err := selectConverter(c, h.clusterService)
+	if err != nil {
+		return err
+	}
+	view := scopeView(auth.FromCtx(c).Scope, h.federationService.View(converter))
+	view.Round(h.clusterService.Rounding())
+
+	return c.JSON(view)
+}
+
+// scopeView narrows view to the namespaces scope can see, recomputing the
+// totals and per-cluster costs from them.
+func scopeView(scope auth.Scope, view internal.FederatedView) internal.FederatedView {
+	if scope.ClusterWide() {
+		return view
+	}
+
+	view.Namespaces = scope.FilterNamespaces(view.Namespaces)
+	view.TotalCost = internal.CostBreakdown{}
+	for _, ns := range view.Namespaces {
+		view.TotalCost.CPUCost = view.TotalCost.CPUCost.Add(ns.CPUCost)
+		view.TotalCost.MemoryCost = view.TotalCost.MemoryCost.Add(ns.MemoryCost)
+		view.TotalCost.StorageCost = view.TotalCost.StorageCost.Add(ns.StorageCost)
+		view.TotalCost.NetworkCost = view.TotalCost.NetworkCost.Add(ns.NetworkCost)
+		view.TotalCost.TotalCost = view.TotalCost.TotalCost.Add(ns.TotalCost)
+	}
+	for i, cluster := range view.Clusters {
+		view.Clusters[i].Cost = rollupVisible(cluster.Cluster, view.Namespaces)
+	}
+
+	return view
+}
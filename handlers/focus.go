@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/pkg/focus"
+	"github.com/SinghaAnirban005/KuBudget/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+const maxFocusRecords = 10000
+
+// errStopScan ends a ledger scan early once a page is full.
+var errStopScan = errors.New("stop scan")
+
+type FocusHandler struct {
+	focusService *services.FocusService
+	logger       *slog.Logger
+}
+
+func NewFocusHandler(focusService *services.FocusService, logger *slog.Logger) *FocusHandler {
+	return &FocusHandler{
+		focusService: focusService,
+		logger:       logger,
+	}
+}
+
+// GetPeriods lists the billing periods that have recorded charges.
+func (h *FocusHandler) GetPeriods(c *fiber.Ctx) error {
+	periods, err := h.focusService.Periods()
+	if err != nil {
+		h.logger.ErrorContext(c.UserContext(), "failed to list billing periods", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to list billing periods",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"periods": periods,
+	})
+}
+
+// GetRecords returns one page of the FOCUS records of a billing period,
+// which defaults to the current one.
+func (h *FocusHandler) GetRecords(c *fiber.Ctx) error {
+	period := c.Query("period", focus.PeriodName(time.Now()))
+	if _, _, err := focus.ParsePeriod(period); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid billing period",
+			"details": err.Error(),
+		})
+	}
+
+	limit := c.QueryInt("limit", 1000)
+	offset := c.QueryInt("offset", 0)
+	if limit <= 0 || limit > maxFocusRecords || offset < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Invalid paging parameters, limit must be 1-%d and offset non-negative", maxFocusRecords),
+		})
+	}
+
+	records := make([]*focus.Record, 0)
+	hasMore := false
+	index := 0
+	err := h.focusService.Scan(period, func(record *focus.Record) error {
+		defer func() { index++ }()
+		if index < offset {
+			return nil
+		}
+		if len(records) == limit {
+			hasMore = true
+			return errStopScan
+		}
+		records = append(records, record)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopScan) {
+		return h.scanError(c, period, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"period":   period,
+		"offset":   offset,
+		"limit":    limit,
+		"has_more": hasMore,
+		"records":  records,
+	})
+}
+
+// DownloadPeriod streams every FOCUS record of a billing period as a CSV or
+// NDJSON file.
+func (h *FocusHandler) DownloadPeriod(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	period := c.Params("period")
+
+	format := c.Query("format", "csv")
+	if format != "csv" && format != "ndjson" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid format parameter, must be csv or ndjson",
+		})
+	}
+
+	if _, _, err := focus.ParsePeriod(period); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid billing period",
+			"details": err.Error(),
+		})
+	}
+	periods, err := h.focusService.Periods()
+	if err != nil {
+		return h.scanError(c, period, err)
+	}
+	if !slices.Contains(periods, period) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Billing period not found",
+		})
+	}
+
+	if format == "csv" {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	} else {
+		c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	}
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="focus-%s.%s"`, period, format))
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		cw := csv.NewWriter(w)
+		encoder := json.NewEncoder(w)
+		write := func(record *focus.Record) error {
+			return encoder.Encode(record)
+		}
+		if format == "csv" {
+			if err := cw.Write(focus.Columns); err != nil {
+				return
+			}
+			write = func(record *focus.Record) error {
+				row, err := record.CSV()
+				if err != nil {
+					return err
+				}
+				return cw.Write(row)
+			}
+		}
+
+		if err := h.focusService.Scan(period, write); err != nil {
+			h.logger.ErrorContext(ctx, "FOCUS download aborted", "period", period, "error", err)
+			return
+		}
+		cw.Flush()
+		w.Flush()
+	})
+
+	return nil
+}
+
+func (h *FocusHandler) scanError(c *fiber.Ctx, period string, err error) error {
+	if errors.Is(err, services.ErrPeriodNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Billing period not found",
+		})
+	}
+	h.logger.ErrorContext(c.UserContext(), "failed to read FOCUS ledger", "period", period, "error", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error":   "Failed to read billing records",
+		"details": err.Error(),
+	})
+}
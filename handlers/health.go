@@ -4,38 +4,58 @@ import (
 	"time"
 
 	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/services"
 	"github.com/gofiber/fiber/v2"
 )
 
-type HealthHandler struct{}
+type HealthHandler struct {
+	healthService *services.HealthService
+}
 
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+func NewHealthHandler(healthService *services.HealthService) *HealthHandler {
+	return &HealthHandler{
+		healthService: healthService,
+	}
 }
 
+// Health is the liveness probe. It deliberately checks no dependencies: a
+// broken Prometheus should take the pod out of rotation, not restart it.
 func (h *HealthHandler) Health(c *fiber.Ctx) error {
 	healthStatus := internal.HealthStatus{
-		Status:    "ready",
+		Status:    "alive",
 		Timestamp: time.Now(),
-		Checks: map[string]string{
-			"server": "ok",
-			"api":    "ok",
-		},
 	}
 
 	return c.JSON(healthStatus)
 }
 
+// Ready is the readiness probe. An instance that still serves some of its
+// clusters stays ready and reports itself as degraded.
 func (h *HealthHandler) Ready(c *fiber.Ctx) error {
-	readinessStatus := internal.HealthStatus{
-		Status:    "ready",
+	checks, ready, degraded := h.healthService.Ready(c.UserContext())
+	okStatus := "ready"
+	if degraded {
+		okStatus = "degraded"
+	}
+	return probeResponse(c, checks, ready, okStatus, "unavailable")
+}
+
+func (h *HealthHandler) Startup(c *fiber.Ctx) error {
+	checks, started := h.healthService.Started(c.UserContext())
+	return probeResponse(c, checks, started, "started", "starting")
+}
+
+func probeResponse(c *fiber.Ctx, checks map[string]internal.CheckResult, ok bool, okStatus, failStatus string) error {
+	status := internal.HealthStatus{
+		Status:    okStatus,
 		Timestamp: time.Now(),
-		Checks: map[string]string{
-			"kubernetes": "ok",
-			"prometheus": "ok",
-			"api":        "ready",
-		},
+		Checks:    checks,
+	}
+
+	if !ok {
+		status.Status = failStatus
+		return c.Status(fiber.StatusServiceUnavailable).JSON(status)
 	}
 
-	return c.JSON(readinessStatus)
+	return c.JSON(status)
 }
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/SinghaAnirban005/KuBudget/pkg/focus"
+	"github.com/SinghaAnirban005/KuBudget/pkg/invoice"
+	"github.com/SinghaAnirban005/KuBudget/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type InvoiceHandler struct {
+	invoiceService *services.InvoiceService
+	logger         *slog.Logger
+}
+
+func NewInvoiceHandler(invoiceService *services.InvoiceService, logger *slog.Logger) *InvoiceHandler {
+	return &InvoiceHandler{
+		invoiceService: invoiceService,
+		logger:         logger,
+	}
+}
+
+// ListInvoices lists the invoices of every closed billing period, or of the
+// one given by ?period=YYYY-MM.
+func (h *InvoiceHandler) ListInvoices(c *fiber.Ctx) error {
+	period := c.Query("period")
+	if err := validatePeriod(period); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid billing period",
+			"details": err.Error(),
+		})
+	}
+
+	invoices, err := h.invoiceService.Invoices(period)
+	if err != nil {
+		h.logger.ErrorContext(c.UserContext(), "failed to list invoices", "period", period, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to list invoices",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"invoices": invoices,
+		"count":    len(invoices),
+	})
+}
+
+// GetInvoice returns an invoice as JSON, or with ?format=csv, html or pdf
+// as a document.
+func (h *InvoiceHandler) GetInvoice(c *fiber.Ctx) error {
+	format := c.Query("format", "json")
+	if !slices.Contains(invoice.Formats, format) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid format parameter, must be one of " + strings.Join(invoice.Formats, ", "),
+		})
+	}
+
+	inv, err := h.invoiceService.Invoice(c.Params("number"))
+	if errors.Is(err, services.ErrInvoiceNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Invoice not found",
+		})
+	}
+	if err != nil {
+		h.logger.ErrorContext(c.UserContext(), "failed to read invoice", "number", c.Params("number"), "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to read invoice",
+			"details": err.Error(),
+		})
+	}
+
+	var body bytes.Buffer
+	if err := invoice.Render(&body, format, inv); err != nil {
+		h.logger.ErrorContext(c.UserContext(), "failed to render invoice", "number", inv.Number, "format", format, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to render invoice",
+			"details": err.Error(),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, invoice.ContentType(format))
+	if format == "csv" || format == "pdf" {
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.%s"`, inv.Number, format))
+	}
+	c.Set(fiber.HeaderETag, `"`+inv.ContentHash+`"`)
+	return c.Send(body.Bytes())
+}
+
+// CloseInvoices closes a billing period, issuing its invoices, once
+// invoicing.close_after has passed since it ended and the FOCUS ledger has
+// recorded all of it. Periods are also closed automatically then.
+func (h *InvoiceHandler) CloseInvoices(c *fiber.Ctx) error {
+	var request struct {
+		Period string `json:"period"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+	}
+	if _, _, err := focus.ParsePeriod(request.Period); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid billing period",
+			"details": err.Error(),
+		})
+	}
+
+	invoices, err := h.invoiceService.Close(c.UserContext(), request.Period)
+	switch {
+	case errors.Is(err, services.ErrPeriodClosed):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "Billing period already closed",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrPeriodOpen):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":   "Billing period is still open",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrLedgerGaps):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "Billing period has unrecorded hours",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrPeriodNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Billing period not found",
+		})
+	case err != nil:
+		h.logger.ErrorContext(c.UserContext(), "failed to close billing period", "period", request.Period, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to close billing period",
+			"details": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"period":   request.Period,
+		"invoices": invoices,
+		"count":    len(invoices),
+	})
+}
+
+// ListAdjustments lists invoice adjustments, optionally of one ?period.
+func (h *InvoiceHandler) ListAdjustments(c *fiber.Ctx) error {
+	period := c.Query("period")
+	if err := validatePeriod(period); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid billing period",
+			"details": err.Error(),
+		})
+	}
+
+	adjustments, err := h.invoiceService.Adjustments(period)
+	if err != nil {
+		h.logger.ErrorContext(c.UserContext(), "failed to list adjustments", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to list adjustments",
+			"details": err.Error(),
+		})
+	}
+	if adjustments == nil {
+		adjustments = []invoice.Adjustment{}
+	}
+
+	return c.JSON(fiber.Map{
+		"adjustments": adjustments,
+		"count":       len(adjustments),
+	})
+}
+
+// AddAdjustment adds a credit (negative amount) or charge to a cost
+// center's invoice of a period that is not closed yet.
+func (h *InvoiceHandler) AddAdjustment(c *fiber.Ctx) error {
+	var adjustment invoice.Adjustment
+	if err := c.BodyParser(&adjustment); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+	}
+
+	adjustment, err := h.invoiceService.AddAdjustment(adjustment)
+	switch {
+	case errors.Is(err, services.ErrInvalidAdjustment):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid adjustment",
+			"details": err.Error(),
+		})
+	case errors.Is(err, services.ErrPeriodClosed):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "Billing period already closed",
+			"details": err.Error(),
+		})
+	case err != nil:
+		h.logger.ErrorContext(c.UserContext(), "failed to add adjustment", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to add adjustment",
+			"details": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(adjustment)
+}
+
+// validatePeriod accepts an empty period or a YYYY-MM billing period.
+func validatePeriod(period string) error {
+	if period == "" {
+		return nil
+	}
+	_, _, err := focus.ParsePeriod(period)
+	return err
+}
@@ -1,32 +1,49 @@
 package handlers
 
 import (
+	"log/slog"
 	"time"
 
+	"github.com/SinghaAnirban005/KuBudget/pkg/auth"
 	"github.com/SinghaAnirban005/KuBudget/services"
 	"github.com/gofiber/fiber/v2"
 )
 
 type MetricsHandler struct {
-	metricsService *services.MetricsService
+	clusterService *services.ClusterService
+	logger         *slog.Logger
 }
 
-func NewMetricsHandler(metricsService *services.MetricsService) *MetricsHandler {
+func NewMetricsHandler(clusterService *services.ClusterService, logger *slog.Logger) *MetricsHandler {
 	return &MetricsHandler{
-		metricsService: metricsService,
+		clusterService: clusterService,
+		logger:         logger,
 	}
 }
 
 func (h *MetricsHandler) GetPrometheusMetrics(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 
 	namespace := c.Query("namespace", "")
 	pod := c.Query("pod", "")
-	// fmt.Println("Namespace " + namespace)
-	// fmt.Println("POD " + pod)
-	metrics, err := h.metricsService.GetPrometheusMetrics(ctx, namespace, pod)
+	if err := validateNames(namespace, pod); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid namespace or pod parameter",
+			"details": err.Error(),
+		})
+	}
+	cluster, err := selectCluster(c, h.clusterService)
+	if err != nil {
+		return err
+	}
+	if !namespaceAllowed(auth.FromCtx(c).Scope, namespace, cluster) {
+		return insufficientScope(c)
+	}
+
+	metrics, err := cluster.Metrics.GetPrometheusMetrics(ctx, namespace, pod)
 
 	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to get Prometheus metrics", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "Failed to get Prometheus metrics",
 			"details": err.Error(),
@@ -37,11 +54,21 @@ func (h *MetricsHandler) GetPrometheusMetrics(c *fiber.Ctx) error {
 }
 
 func (h *MetricsHandler) GetClusterMetrics(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
+
+	if !auth.FromCtx(c).Scope.ClusterWide() {
+		return insufficientScope(c)
+	}
+
+	cluster, err := selectCluster(c, h.clusterService)
+	if err != nil {
+		return err
+	}
 
-	metrics, err := h.metricsService.GetClusterMetrics(ctx)
+	metrics, err := cluster.Metrics.GetClusterMetrics(ctx)
 
 	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to get cluster metrics", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "Failed to get cluster metrics",
 			"details": err.Error(),
@@ -52,13 +79,27 @@ func (h *MetricsHandler) GetClusterMetrics(c *fiber.Ctx) error {
 }
 
 func (h *MetricsHandler) GetResourceUsage(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 
 	namespace := c.Query("namespace", "")
 	pod := c.Query("pod", "")
+	if err := validateNames(namespace, pod); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid namespace or pod parameter",
+			"details": err.Error(),
+		})
+	}
+	cluster, err := selectCluster(c, h.clusterService)
+	if err != nil {
+		return err
+	}
+	if !namespaceAllowed(auth.FromCtx(c).Scope, namespace, cluster) {
+		return insufficientScope(c)
+	}
 
-	usage, err := h.metricsService.GetResourceUsage(ctx, namespace, pod)
+	usage, err := cluster.Metrics.GetResourceUsage(ctx, namespace, pod)
 	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to get resource usage", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "Failed to get resource usage",
 			"details": err.Error(),
@@ -67,8 +108,24 @@ func (h *MetricsHandler) GetResourceUsage(c *fiber.Ctx) error {
 
 	return c.JSON(fiber.Map{
 		"resource_usage": usage,
+		"cluster":        cluster.Name,
 		"namespace":      namespace,
 		"pod":            pod,
 		"timestamp":      time.Now(),
 	})
 }
+
+// selectCluster resolves the cluster query parameter for endpoints that
+// report on a single cluster, defaulting to the first configured cluster.
+func selectCluster(c *fiber.Ctx, clusterService *services.ClusterService) (*services.Cluster, error) {
+	name := c.Query("cluster", "")
+	if name == "" {
+		return clusterService.Clusters()[0], nil
+	}
+
+	cluster, err := clusterService.Get(name)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	return cluster, nil
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type PriceHistoryHandler struct {
+	clusterService      *services.ClusterService
+	priceHistoryService *services.PriceHistoryService
+	logger              *slog.Logger
+}
+
+func NewPriceHistoryHandler(clusterService *services.ClusterService, priceHistoryService *services.PriceHistoryService, logger *slog.Logger) *PriceHistoryHandler {
+	return &PriceHistoryHandler{
+		clusterService:      clusterService,
+		priceHistoryService: priceHistoryService,
+		logger:              logger,
+	}
+}
+
+// ListVersions returns every recorded price version, oldest first.
+func (h *PriceHistoryHandler) ListVersions(c *fiber.Ctx) error {
+	versions := h.priceHistoryService.Versions()
+	return c.JSON(fiber.Map{
+		"versions": versions,
+		"count":    len(versions),
+	})
+}
+
+// Compare re-runs the cost history of a past period under one version and
+// returns it next to the history as it was priced.
+func (h *PriceHistoryHandler) Compare(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid version",
+		})
+	}
+
+	start, err := time.Parse(time.RFC3339, c.Query("start"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid start parameter, expected an RFC 3339 timestamp",
+			"details": err.Error(),
+		})
+	}
+	end, err := time.Parse(time.RFC3339, c.Query("end"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid end parameter, expected an RFC 3339 timestamp",
+			"details": err.Error(),
+		})
+	}
+	if !end.After(start) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid period, end must be after start",
+		})
+	}
+
+	step, err := time.ParseDuration(c.Query("step", "1h"))
+	if err != nil || step < time.Second {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid step parameter",
+		})
+	}
+
+	namespace := c.Query("namespace", "")
+	if err := validateNames(namespace, ""); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid namespace parameter",
+			"details": err.Error(),
+		})
+	}
+
+	clusters, err := selectClusters(c, h.clusterService)
+	if err != nil {
+		return err
+	}
+
+	converter, err := selectConverter(c, h.clusterService)
+	if err != nil {
+		return err
+	}
+
+	comparison, err := h.priceHistoryService.Compare(ctx, clusters, id, start, end, step, namespace, converter)
+	if errors.Is(err, services.ErrPriceVersionNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Price version not found",
+		})
+	}
+	if errors.Is(err, services.ErrNoExchangeRate) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":   "No exchange rate for the requested currency",
+			"details": err.Error(),
+		})
+	}
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to compare price versions", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to compare price versions",
+			"details": err.Error(),
+		})
+	}
+	comparison.Round(h.clusterService.Rounding())
+
+	return c.JSON(comparison)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/SinghaAnirban005/KuBudget/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ReconciliationHandler struct {
+	reconciliationService *services.ReconciliationService
+	logger                *slog.Logger
+}
+
+func NewReconciliationHandler(reconciliationService *services.ReconciliationService, logger *slog.Logger) *ReconciliationHandler {
+	return &ReconciliationHandler{
+		reconciliationService: reconciliationService,
+		logger:                logger,
+	}
+}
+
+// GetReport returns the adjustment factors from the last reconciliation.
+func (h *ReconciliationHandler) GetReport(c *fiber.Ctx) error {
+	report, err := h.reconciliationService.Report()
+	if errors.Is(err, services.ErrNotReconciled) {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Reconciliation has not run yet",
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(report)
+}
+
+// Reconcile reloads the billing files and recomputes every factor.
+func (h *ReconciliationHandler) Reconcile(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	report, err := h.reconciliationService.Reconcile(ctx)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "cost reconciliation failed", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to reconcile costs",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(report)
+}
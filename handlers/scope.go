@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/auth"
+	"github.com/SinghaAnirban005/KuBudget/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// insufficientScope rejects requests for data the caller's scope cannot be
+// narrowed to, such as node costs for a namespace-scoped token.
+func insufficientScope(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error": "Insufficient scope",
+	})
+}
+
+// namespaceAllowed reports whether the caller may see all of namespace in
+// every one of clusters, or everything when namespace is empty.
+func namespaceAllowed(scope auth.Scope, namespace string, clusters ...*services.Cluster) bool {
+	if namespace == "" {
+		return scope.ClusterWide()
+	}
+	for _, cluster := range clusters {
+		if !scope.NamespaceVisible(cluster.Name, namespace) {
+			return false
+		}
+	}
+	return true
+}
+
+// scopeOverview narrows overview to what scope can see, recomputing totals
+// and per-cluster rollups from the visible namespaces.
+func scopeOverview(scope auth.Scope, overview *internal.CostOverview) *internal.CostOverview {
+	if scope.ClusterWide() {
+		return overview
+	}
+
+	scoped := &internal.CostOverview{
+		Cluster:        overview.Cluster,
+		Currency:       overview.Currency,
+		NamespacesCost: scope.FilterNamespaces(overview.NamespacesCost),
+		Timestamp:      overview.Timestamp,
+	}
+	for _, ns := range scoped.NamespacesCost {
+		scoped.TotalCost.CPUCost = scoped.TotalCost.CPUCost.Add(ns.CPUCost)
+		scoped.TotalCost.MemoryCost = scoped.TotalCost.MemoryCost.Add(ns.MemoryCost)
+		scoped.TotalCost.StorageCost = scoped.TotalCost.StorageCost.Add(ns.StorageCost)
+		scoped.TotalCost.NetworkCost = scoped.TotalCost.NetworkCost.Add(ns.NetworkCost)
+		scoped.TotalCost.TotalCost = scoped.TotalCost.TotalCost.Add(ns.TotalCost)
+	}
+
+	if overview.Clusters != nil {
+		scoped.Clusters = make([]internal.ClusterCost, 0, len(overview.Clusters))
+		for _, cluster := range overview.Clusters {
+			if cluster.Error != "" {
+				scoped.Clusters = append(scoped.Clusters, cluster)
+				continue
+			}
+			scoped.Clusters = append(scoped.Clusters, rollupVisible(cluster.Cluster, scoped.NamespacesCost))
+		}
+	}
+
+	return scoped
+}
+
+// rollupVisible sums the already filtered namespaces that belong to cluster.
+func rollupVisible(cluster string, namespaces []internal.NamespaceCost) internal.ClusterCost {
+	own := make([]internal.NamespaceCost, 0)
+	for _, ns := range namespaces {
+		if ns.Cluster == cluster {
+			own = append(own, ns)
+		}
+	}
+	return services.RollupNamespaces(cluster, own)
+}
+
+// visibleExternalCosts narrows external costs to those scope grants, treating
+// an item's "namespace" label and labels like a pod's, and to cluster when a
+// single cluster is selected.
+func visibleExternalCosts(scope auth.Scope, cluster string, items []internal.ExternalCost) []internal.ExternalCost {
+	visible := make([]internal.ExternalCost, 0, len(items))
+	for _, item := range items {
+		if cluster != "" && item.Labels["cluster"] != cluster {
+			continue
+		}
+		if !scope.ClusterWide() && !scope.PodVisible(item.Labels["cluster"], item.Labels["namespace"], item.Labels) {
+			continue
+		}
+		visible = append(visible, item)
+	}
+	return visible
+}
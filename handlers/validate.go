@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// validateNames checks the namespace and pod query parameters as
+// Kubernetes object names: a DNS-1123 label and subdomain respectively.
+// Empty values mean "no filter" and are allowed.
+func validateNames(namespace, pod string) error {
+	if namespace != "" {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return fmt.Errorf("invalid namespace %q: %s", namespace, strings.Join(errs, "; "))
+		}
+	}
+	if pod != "" {
+		if errs := validation.IsDNS1123Subdomain(pod); len(errs) > 0 {
+			return fmt.Errorf("invalid pod %q: %s", pod, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
@@ -1,66 +1,1376 @@
 package internal
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	currencyPattern   = regexp.MustCompile(`^[A-Z]{3}$`)
+	metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNamePattern  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+	invoicePrefixPattern = regexp.MustCompile(`^[A-Za-z0-9-]{1,16}$`)
 )
 
 type Config struct {
-	PrometheusURL    string
-	KubeConfigPath   string
-	Port             string
-	MetricsInterval  time.Duration
-	CPUCostPerHour   float64
-	MemoryCostPerGB  float64
-	StorageCostPerGB float64
+	Server         ServerConfig          `yaml:"server"`
+	Clusters       []ClusterConfig       `yaml:"clusters"`
+	Kubernetes     KubernetesConfig      `yaml:"kubernetes"`
+	Prometheus     PrometheusConfig      `yaml:"prometheus"`
+	Pricing        PricingConfig         `yaml:"pricing"`
+	Collectors     CollectorsConfig      `yaml:"collectors"`
+	Logging        LoggingConfig         `yaml:"logging"`
+	Auth           AuthConfig            `yaml:"auth"`
+	Federation     FederationConfig      `yaml:"federation"`
+	Focus          FocusConfig           `yaml:"focus"`
+	Reconciliation ReconciliationConfig  `yaml:"reconciliation"`
+	ExternalCosts  ExternalCostsConfig   `yaml:"external_costs"`
+	Discounts      DiscountsConfig       `yaml:"discounts"`
+	Commitments    CommitmentsConfig     `yaml:"commitments"`
+	Network        NetworkConfig         `yaml:"network"`
+	PriceHistory   PriceHistoryConfig    `yaml:"price_history"`
+	Currency       CurrencyConfig        `yaml:"currency"`
+	Invoicing      InvoicingConfig       `yaml:"invoicing"`
+	Budgets        []BudgetConfig        `yaml:"budgets"`
+	Notifications  []NotificationChannel `yaml:"notifications"`
+}
+
+type ServerConfig struct {
+	Port           string        `yaml:"port"`
+	InternalPort   string        `yaml:"internal_port"`
+	EnablePprof    bool          `yaml:"enable_pprof"`
+	HealthTimeout  time.Duration `yaml:"health_timeout"`
+	HealthCacheTTL time.Duration `yaml:"health_cache_ttl"`
+}
+
+type KubernetesConfig struct {
+	KubeConfigPath string `yaml:"kubeconfig"`
+	ClusterName    string `yaml:"cluster_name"`
+}
+
+// ClusterConfig describes one cluster KuBudget reports on. Pricing, when set,
+// replaces the global pricing for this cluster.
+type ClusterConfig struct {
+	Name          string         `yaml:"name"`
+	KubeConfig    string         `yaml:"kubeconfig"`
+	Context       string         `yaml:"context"`
+	InCluster     bool           `yaml:"in_cluster"`
+	PrometheusURL string         `yaml:"prometheus_url"`
+	Pricing       *PricingConfig `yaml:"pricing"`
+}
+
+type PrometheusConfig struct {
+	URL string `yaml:"url"`
+}
+
+type PricingConfig struct {
+	CPUCostPerHour   float64 `yaml:"cpu_cost_per_hour" json:"cpu_cost_per_hour"`
+	MemoryCostPerGB  float64 `yaml:"memory_cost_per_gb" json:"memory_cost_per_gb"`
+	StorageCostPerGB float64 `yaml:"storage_cost_per_gb" json:"storage_cost_per_gb"`
+	// EphemeralStorageCostPerGB prices node root disks per GB-hour. Pods
+	// pay for the larger of their ephemeral-storage request and usage.
+	EphemeralStorageCostPerGB float64                            `yaml:"ephemeral_storage_cost_per_gb" json:"ephemeral_storage_cost_per_gb"`
+	ExtendedResources         map[string]ExtendedResourcePricing `yaml:"extended_resources" json:"extended_resources,omitempty"`
+	Network                   NetworkPricing                     `yaml:"network" json:"network"`
+	// Timezone is the IANA zone Schedules are evaluated in, UTC if empty.
+	Timezone  string            `yaml:"timezone" json:"timezone,omitempty"`
+	Schedules []PricingSchedule `yaml:"schedules" json:"schedules,omitempty"`
+
+	// calendar is Timezone and Schedules as parsed by ParseSchedules.
+	calendar *calendar
+}
+
+// PricingSchedule reprices recurring hours, such as nights and weekends,
+// by scaling every rate by Multiplier while it applies. Weekdays (mon to
+// sun) and Hours ranges such as "22:00-06:00" are matched independently
+// against local time; either may be empty to match every day or every
+// hour. The first matching schedule applies, and time no schedule covers
+// is the "peak" period at base rates.
+type PricingSchedule struct {
+	Name       string   `yaml:"name" json:"name"`
+	Weekdays   []string `yaml:"weekdays" json:"weekdays,omitempty"`
+	Hours      []string `yaml:"hours" json:"hours,omitempty"`
+	Multiplier float64  `yaml:"multiplier" json:"multiplier"`
+}
+
+// NetworkPricing prices the bytes a pod sends, per GB, by where they go.
+// Received bytes are not charged. Traffic that cannot be classified
+// because no flow metric is configured is priced as cross-zone.
+type NetworkPricing struct {
+	IntraZoneCostPerGB      float64 `yaml:"intra_zone_cost_per_gb" json:"intra_zone_cost_per_gb"`
+	CrossZoneCostPerGB      float64 `yaml:"cross_zone_cost_per_gb" json:"cross_zone_cost_per_gb"`
+	InternetEgressCostPerGB float64 `yaml:"internet_egress_cost_per_gb" json:"internet_egress_cost_per_gb"`
+}
+
+// ExtendedResourcePricing prices a resource other than CPU and memory, such
+// as nvidia.com/gpu or hugepages-2Mi, per unit and hour. Hugepages are
+// priced per GB, everything else per device. InstanceTypes overrides
+// CostPerHour on nodes of those instance types.
+type ExtendedResourcePricing struct {
+	CostPerHour   float64            `yaml:"cost_per_hour" json:"cost_per_hour"`
+	InstanceTypes map[string]float64 `yaml:"instance_types" json:"instance_types,omitempty"`
+}
+
+// ExtendedResourceRate returns the hourly price of one unit of resource on
+// a node of instanceType, and false when resource is not priced.
+func (p PricingConfig) ExtendedResourceRate(resource, instanceType string) (float64, bool) {
+	pricing, ok := p.ExtendedResources[resource]
+	if !ok {
+		return 0, false
+	}
+	if rate, ok := pricing.InstanceTypes[instanceType]; ok && instanceType != "" {
+		return rate, true
+	}
+	return pricing.CostPerHour, true
+}
+
+// PriceHistoryConfig keeps every pricing in effect, with the time it took
+// effect, under StoragePath, so that cost history is priced at the rates
+// that applied at the time rather than at the current ones.
+type PriceHistoryConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	StoragePath string `yaml:"storage_path"`
+}
+
+// CurrencyConfig names the currency, an ISO 4217 code, that every price
+// in the configuration is quoted in and costs are reported in by default.
+// Costs are exact decimals until they are presented, when they are rounded
+// to Precision decimal places with Rounding: half_even, half_up, down
+// (toward zero) or up (away from zero).
+type CurrencyConfig struct {
+	Base          string              `yaml:"base"`
+	Precision     int32               `yaml:"precision"`
+	Rounding      string              `yaml:"rounding"`
+	ExchangeRates ExchangeRatesConfig `yaml:"exchange_rates"`
+}
+
+// Presentation is the rounding applied to costs when they are presented.
+func (c CurrencyConfig) Presentation() money.Rounding {
+	return money.Rounding{Places: c.Precision, Mode: c.Rounding}
+}
+
+// ExchangeRatesConfig enables converting costs into other currencies with
+// dated exchange rates. Rates posted to the API are kept under StoragePath;
+// Files are glob patterns of CSV or JSON files, by extension, that are
+// re-read every Interval.
+type ExchangeRatesConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	StoragePath string        `yaml:"storage_path"`
+	Files       []string      `yaml:"files"`
+	Interval    time.Duration `yaml:"interval"`
+}
+
+// InvoicingConfig enables chargeback invoices. CloseAfter a billing period
+// has ended, the FOCUS ledger of the period is closed into one immutable
+// invoice per cost center, kept under StoragePath; the check runs every
+// Interval. Invoice amounts are rounded to Precision decimal places with
+// currency.rounding.
+//
+// A pod is charged to the first cost center that matches it, or to
+// Unallocated. Costs of SharedNamespaces and node capacity no pod uses are
+// allocated to cost centers in proportion to what they are charged
+// directly.
+type InvoicingConfig struct {
+	Enabled          bool               `yaml:"enabled"`
+	StoragePath      string             `yaml:"storage_path"`
+	NumberPrefix     string             `yaml:"number_prefix"`
+	Precision        int32              `yaml:"precision"`
+	CloseAfter       time.Duration      `yaml:"close_after"`
+	Interval         time.Duration      `yaml:"interval"`
+	Unallocated      string             `yaml:"unallocated"`
+	SharedNamespaces []string           `yaml:"shared_namespaces"`
+	CostCenters      []CostCenterConfig `yaml:"cost_centers"`
+}
+
+// CostCenterConfig matches the pods whose namespace matches one of
+// Namespaces, which are path.Match patterns, and whose labels include all
+// of Labels. Either may be empty, but not both. Discount is the fraction
+// taken off the cost center's invoices.
+type CostCenterConfig struct {
+	Name       string            `yaml:"name"`
+	Namespaces []string          `yaml:"namespaces"`
+	Labels     map[string]string `yaml:"labels"`
+	Discount   float64           `yaml:"discount"`
+}
+
+// Presentation is the rounding of invoice amounts.
+func (c InvoicingConfig) Presentation(currency CurrencyConfig) money.Rounding {
+	return money.Rounding{Places: c.Precision, Mode: currency.Rounding}
+}
+
+// NetworkConfig names the flow metric that classifies pod egress, a
+// counter of bytes labeled with the sending pod and with the destination's
+// zone, pod or IP, such as a Cilium/Hubble flow metric with the matching
+// labelsContext. Matchers select the egress series of the metric. Empty
+// label names are not used; without a FlowMetric, egress is taken from
+// cAdvisor and left unclassified.
+type NetworkConfig struct {
+	FlowMetric                string            `yaml:"flow_metric"`
+	Matchers                  map[string]string `yaml:"matchers"`
+	SourceNamespaceLabel      string            `yaml:"source_namespace_label"`
+	SourcePodLabel            string            `yaml:"source_pod_label"`
+	DestinationZoneLabel      string            `yaml:"destination_zone_label"`
+	DestinationNamespaceLabel string            `yaml:"destination_namespace_label"`
+	DestinationPodLabel       string            `yaml:"destination_pod_label"`
+	DestinationIPLabel        string            `yaml:"destination_ip_label"`
+}
+
+type CollectorsConfig struct {
+	Interval        time.Duration `yaml:"interval"`
+	ExporterMaxPods int           `yaml:"exporter_max_pods"`
+}
+
+type LoggingConfig struct {
+	Format string `yaml:"format"`
+	Level  string `yaml:"level"`
+}
+
+// AuthConfig controls who may call the cost and metrics API. Callers
+// present either a static token listed in Tokens or an OIDC ID token, and
+// see only the namespaces and workloads their scopes grant.
+type AuthConfig struct {
+	Enabled        bool                 `yaml:"enabled"`
+	AllowedOrigins []string             `yaml:"allowed_origins"`
+	Tokens         []TokenConfig        `yaml:"tokens"`
+	OIDC           OIDCConfig           `yaml:"oidc"`
+	Kubernetes     KubernetesAuthConfig `yaml:"kubernetes"`
+}
+
+// TokenConfig is a static API token. Only the hex SHA-256 digest of the
+// token is stored, e.g. the output of `printf %s "$TOKEN" | sha256sum`.
+type TokenConfig struct {
+	Name   string        `yaml:"name"`
+	SHA256 string        `yaml:"sha256"`
+	Scopes []ScopeConfig `yaml:"scopes"`
+}
+
+// ScopeConfig grants access to Namespaces ("*" for every namespace) of
+// Clusters, or of every cluster when Clusters is empty. When Labels is set,
+// access is further limited to pods carrying all of them.
+type ScopeConfig struct {
+	Clusters   []string          `yaml:"clusters"`
+	Namespaces []string          `yaml:"namespaces"`
+	Labels     map[string]string `yaml:"labels"`
+}
+
+// OIDCConfig validates ID tokens from IssuerURL for Audience. Keys come from
+// JWKSURL, or from the issuer's discovery document when it is empty. Scopes
+// are granted by membership of the groups listed in the GroupsClaim claim.
+type OIDCConfig struct {
+	IssuerURL   string            `yaml:"issuer_url"`
+	JWKSURL     string            `yaml:"jwks_url"`
+	Audience    string            `yaml:"audience"`
+	GroupsClaim string            `yaml:"groups_claim"`
+	Groups      []OIDCGroupConfig `yaml:"groups"`
+}
+
+// KubernetesAuthConfig accepts Kubernetes bearer tokens, validated with a
+// TokenReview against Cluster (default: the first cluster). A caller sees a
+// namespace's costs only if a SubjectAccessReview allows them to list pods
+// there. Review results are cached for CacheTTL.
+//
+// DevMode skips the TokenReview for local development: requests run as
+// DevUser and DevGroups, or with unrestricted access when DevUser is empty.
+type KubernetesAuthConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Cluster   string        `yaml:"cluster"`
+	Audiences []string      `yaml:"audiences"`
+	CacheTTL  time.Duration `yaml:"cache_ttl"`
+	DevMode   bool          `yaml:"dev_mode"`
+	DevUser   string        `yaml:"dev_user"`
+	DevGroups []string      `yaml:"dev_groups"`
+}
+
+type OIDCGroupConfig struct {
+	Group  string        `yaml:"group"`
+	Scopes []ScopeConfig `yaml:"scopes"`
 }
 
-func LoadConfig() *Config {
+// FederationConfig sets up hub-and-spoke federation between instances. A
+// spoke pushes allocation snapshots of its clusters to HubURL; a hub accepts
+// them from the spokes it lists and can also pull from them. A spoke
+// authenticates with its Token, which the hub lists as that spoke's token.
+type FederationConfig struct {
+	Mode  string      `yaml:"mode"`
+	Token string      `yaml:"token"`
+	Spoke SpokeConfig `yaml:"spoke"`
+	Hub   HubConfig   `yaml:"hub"`
+}
+
+type SpokeConfig struct {
+	Name     string        `yaml:"name"`
+	HubURL   string        `yaml:"hub_url"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+type HubConfig struct {
+	StoragePath  string        `yaml:"storage_path"`
+	StaleAfter   time.Duration `yaml:"stale_after"`
+	PullInterval time.Duration `yaml:"pull_interval"`
+	Spokes       []SpokeTarget `yaml:"spokes"`
+}
+
+// SpokeTarget is a spoke the hub accepts snapshots from. Snapshots pushed
+// with its Token are attributed to it whatever source they declare. The
+// hub pulls from spokes with a URL, authenticating with the same token.
+type SpokeTarget struct {
+	Name  string `yaml:"name"`
+	URL   string `yaml:"url"`
+	Token string `yaml:"token"`
+}
+
+// FocusConfig enables the FOCUS billing ledger. Every hour the allocated
+// costs of the past hour are appended to one file per monthly billing
+// period under StoragePath. The billing account fields fill the FOCUS
+// columns of the same name. Costs are billed in Currency, converted from
+// the base currency when they differ; empty bills in the base currency.
+type FocusConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	StoragePath        string `yaml:"storage_path"`
+	BillingAccountID   string `yaml:"billing_account_id"`
+	BillingAccountName string `yaml:"billing_account_name"`
+	InvoiceIssuer      string `yaml:"invoice_issuer"`
+	Currency           string `yaml:"currency"`
+}
+
+// ReconciliationConfig scales allocated costs to what the cloud provider
+// actually billed. Billing exports matching Files are re-read every
+// Interval, and their line items are matched to nodes by provider ID and
+// to persistent volumes by volume ID.
+type ReconciliationConfig struct {
+	Enabled  bool                `yaml:"enabled"`
+	Interval time.Duration       `yaml:"interval"`
+	Files    []BillingFileConfig `yaml:"files"`
+}
+
+// BillingFileConfig is a billing export in Format (aws-cur, gcp or azure).
+// Path may be a glob pattern.
+type BillingFileConfig struct {
+	Path   string `yaml:"path"`
+	Format string `yaml:"format"`
+}
+
+// ExternalCostsConfig enables costs from outside the cluster. Items posted
+// to the API are kept under StoragePath; Files are glob patterns of CSV or
+// JSON files, by extension, that are re-read every Interval.
+type ExternalCostsConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	StoragePath string        `yaml:"storage_path"`
+	Files       []string      `yaml:"files"`
+	Interval    time.Duration `yaml:"interval"`
+}
+
+// DiscountsConfig models what nodes cost after discounts rather than at
+// list price. Spot nodes, recognized by their capacity-type labels, are
+// discounted first. The remaining nodes are matched to Reservations by
+// instance type, oldest first, and what is still on demand is covered by
+// SavingsPlans in order until each plan's hourly commitment is used up.
+// Discounts are fractions of the list price. The rates are refreshed every
+// Interval.
+type DiscountsConfig struct {
+	Enabled      bool                `yaml:"enabled"`
+	Interval     time.Duration       `yaml:"interval"`
+	Spot         SpotConfig          `yaml:"spot"`
+	Reservations []ReservationConfig `yaml:"reservations"`
+	SavingsPlans []SavingsPlanConfig `yaml:"savings_plans"`
+}
+
+// SpotConfig is the discount for spot and preemptible nodes. InstanceTypes
+// overrides Discount for individual instance types. Labels adds node labels,
+// with the value that marks a node as spot, to the well-known ones.
+type SpotConfig struct {
+	Discount      float64            `yaml:"discount"`
+	InstanceTypes map[string]float64 `yaml:"instance_types"`
+	Labels        map[string]string  `yaml:"labels"`
+}
+
+// ReservationConfig is Count reserved instances of InstanceType, covering
+// Count instance-hours every hour.
+type ReservationConfig struct {
+	InstanceType string  `yaml:"instance_type"`
+	Count        int     `yaml:"count"`
+	Discount     float64 `yaml:"discount"`
+}
+
+// SavingsPlanConfig is a commitment to spend CommitmentPerHour on usage
+// billed at Discount off the list price, whether or not it is used.
+type SavingsPlanConfig struct {
+	Name              string  `yaml:"name"`
+	CommitmentPerHour float64 `yaml:"commitment_per_hour"`
+	Discount          float64 `yaml:"discount"`
+}
+
+// CommitmentsConfig enables reserved instance and savings plan purchase
+// recommendations from the node history in Prometheus. Each window in
+// WindowDays is analyzed separately, and one option is offered per
+// percentile of hourly on-demand usage in Percentiles: the 0th percentile
+// is the steady baseline, which a commitment covers every hour. Discounts
+// are the expected fractions off list price for a term of TermMonths.
+type CommitmentsConfig struct {
+	Enabled             bool      `yaml:"enabled"`
+	WindowDays          []int     `yaml:"window_days"`
+	Percentiles         []float64 `yaml:"percentiles"`
+	TermMonths          int       `yaml:"term_months"`
+	ReservedDiscount    float64   `yaml:"reserved_discount"`
+	SavingsPlanDiscount float64   `yaml:"savings_plan_discount"`
+}
+
+// BudgetConfig is a spending limit for a namespace over a period. Thresholds
+// are fractions of Amount at which the listed notification channels fire.
+type BudgetConfig struct {
+	Name       string    `yaml:"name"`
+	Namespace  string    `yaml:"namespace"`
+	Period     string    `yaml:"period"`
+	Amount     float64   `yaml:"amount"`
+	Thresholds []float64 `yaml:"thresholds"`
+	Channels   []string  `yaml:"channels"`
+}
+
+type NotificationChannel struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	URL  string `yaml:"url"`
+}
+
+// Overrides holds values set on the command line. They take precedence over
+// both the config file and the environment.
+type Overrides struct {
+	Port           string
+	PrometheusURL  string
+	KubeConfigPath string
+	LogLevel       string
+}
+
+func DefaultConfig() *Config {
 	return &Config{
-		PrometheusURL:    getEnv("PROMETHEUS_URL", "http://localhost:9090"),
-		KubeConfigPath:   getEnv("KUBE_CONFIG_PATH", ""),
-		Port:             getEnv("PORT", "8080"),
-		MetricsInterval:  getDurationEnv("METRICS_INTERVAL", 30*time.Second),
-		CPUCostPerHour:   getFloatEnv("CPU_COST_PER_HOUR", 0.048),
-		MemoryCostPerGB:  getFloatEnv("MEMORY_COST_PER_GB", 0.0067),
-		StorageCostPerGB: getFloatEnv("STORAGE_COST_PER_GB", 0.00014),
+		Server: ServerConfig{
+			Port:           "8000",
+			InternalPort:   "8001",
+			HealthTimeout:  2 * time.Second,
+			HealthCacheTTL: 10 * time.Second,
+		},
+		Kubernetes: KubernetesConfig{
+			ClusterName: "default",
+		},
+		Prometheus: PrometheusConfig{
+			URL: "http://localhost:9090",
+		},
+		Pricing: PricingConfig{
+			CPUCostPerHour:            0.048,
+			MemoryCostPerGB:           0.0067,
+			StorageCostPerGB:          0.00014,
+			EphemeralStorageCostPerGB: 0.00011,
+			Network: NetworkPricing{
+				CrossZoneCostPerGB:      0.01,
+				InternetEgressCostPerGB: 0.09,
+			},
+		},
+		Collectors: CollectorsConfig{
+			Interval:        30 * time.Second,
+			ExporterMaxPods: 5000,
+		},
+		Logging: LoggingConfig{
+			Format: "json",
+			Level:  "info",
+		},
+		Auth: AuthConfig{
+			OIDC: OIDCConfig{
+				GroupsClaim: "groups",
+			},
+			Kubernetes: KubernetesAuthConfig{
+				CacheTTL: time.Minute,
+			},
+		},
+		Federation: FederationConfig{
+			Spoke: SpokeConfig{
+				Interval: 5 * time.Minute,
+			},
+			Hub: HubConfig{
+				StaleAfter:   15 * time.Minute,
+				PullInterval: 5 * time.Minute,
+			},
+		},
+		Focus: FocusConfig{
+			BillingAccountID: "kubudget",
+			InvoiceIssuer:    "KuBudget",
+		},
+		Reconciliation: ReconciliationConfig{
+			Interval: time.Hour,
+		},
+		ExternalCosts: ExternalCostsConfig{
+			Interval: 5 * time.Minute,
+		},
+		Discounts: DiscountsConfig{
+			Interval: 5 * time.Minute,
+		},
+		Commitments: CommitmentsConfig{
+			WindowDays:          []int{30, 60, 90},
+			Percentiles:         []float64{0, 10, 25, 50},
+			TermMonths:          12,
+			ReservedDiscount:    0.4,
+			SavingsPlanDiscount: 0.3,
+		},
+		Network: NetworkConfig{
+			SourceNamespaceLabel:      "source_namespace",
+			SourcePodLabel:            "source_pod",
+			DestinationNamespaceLabel: "destination_namespace",
+			DestinationPodLabel:       "destination_pod",
+			DestinationIPLabel:        "destination_ip",
+		},
+		Currency: CurrencyConfig{
+			Base:      "USD",
+			Precision: 6,
+			Rounding:  money.HalfEven,
+			ExchangeRates: ExchangeRatesConfig{
+				Interval: 5 * time.Minute,
+			},
+		},
+		Invoicing: InvoicingConfig{
+			NumberPrefix: "INV",
+			Precision:    2,
+			CloseAfter:   2 * time.Hour,
+			Interval:     time.Hour,
+			Unallocated:  "unallocated",
+		},
+	}
+}
+
+// LoadConfig builds the configuration from defaults, the YAML file at path
+// (if non-empty), environment variables and command-line overrides, in that
+// order of precedence, and validates the result. Unknown keys in the file
+// and malformed environment values are errors rather than being ignored.
+func LoadConfig(path string, overrides Overrides) (*Config, error) {
+	cfg := DefaultConfig()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+		}
+	}
+
+	if err := applyEnv(cfg); err != nil {
+		return nil, fmt.Errorf("invalid environment: %v", err)
+	}
+	applyOverrides(cfg, overrides)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
 	}
+
+	return cfg, nil
 }
 
-func getEnv(key, defaultValue string) string {
+// Validate reports every problem with the configuration at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if _, err := strconv.ParseUint(c.Server.Port, 10, 16); err != nil {
+		errs = append(errs, fmt.Errorf("server.port: %q is not a valid port", c.Server.Port))
+	}
+	if _, err := strconv.ParseUint(c.Server.InternalPort, 10, 16); err != nil {
+		errs = append(errs, fmt.Errorf("server.internal_port: %q is not a valid port", c.Server.InternalPort))
+	}
+	if c.Server.Port == c.Server.InternalPort {
+		errs = append(errs, fmt.Errorf("server.internal_port: must differ from server.port"))
+	}
+	if c.Server.HealthTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("server.health_timeout: must be positive"))
+	}
+	if c.Server.HealthCacheTTL < 0 {
+		errs = append(errs, fmt.Errorf("server.health_cache_ttl: must not be negative"))
+	}
+
+	if !validHTTPURL(c.Prometheus.URL) {
+		errs = append(errs, fmt.Errorf("prometheus.url: %q is not a valid http(s) URL", c.Prometheus.URL))
+	}
+
+	errs = append(errs, c.Pricing.validate("pricing")...)
+
+	if len(c.Clusters) == 0 && c.Kubernetes.ClusterName == "" {
+		errs = append(errs, fmt.Errorf("kubernetes.cluster_name: is required"))
+	}
+
+	clusterNames := make(map[string]bool, len(c.Clusters))
+	for i, cluster := range c.Clusters {
+		field := fmt.Sprintf("clusters[%d]", i)
+		if cluster.Name == "" {
+			errs = append(errs, fmt.Errorf("%s.name: is required", field))
+		} else if clusterNames[cluster.Name] {
+			errs = append(errs, fmt.Errorf("%s.name: duplicate cluster %q", field, cluster.Name))
+		}
+		clusterNames[cluster.Name] = true
+
+		if cluster.InCluster && (cluster.KubeConfig != "" || cluster.Context != "") {
+			errs = append(errs, fmt.Errorf("%s: in_cluster cannot be combined with kubeconfig or context", field))
+		}
+		if !validHTTPURL(cluster.PrometheusURL) {
+			errs = append(errs, fmt.Errorf("%s.prometheus_url: %q is not a valid http(s) URL", field, cluster.PrometheusURL))
+		}
+		if cluster.Pricing != nil {
+			errs = append(errs, cluster.Pricing.validate(field+".pricing")...)
+		}
+	}
+
+	if c.Collectors.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("collectors.interval: must be positive"))
+	}
+	if c.Collectors.ExporterMaxPods < 0 {
+		errs = append(errs, fmt.Errorf("collectors.exporter_max_pods: must not be negative"))
+	}
+
+	if c.Logging.Format != "json" && c.Logging.Format != "text" {
+		errs = append(errs, fmt.Errorf("logging.format: %q must be json or text", c.Logging.Format))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Logging.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("logging.level: %q must be debug, info, warn or error", c.Logging.Level))
+	}
+
+	errs = append(errs, c.Auth.validate()...)
+	if c.Auth.Kubernetes.Cluster != "" && !slices.ContainsFunc(c.ClusterConfigs(), func(cluster ClusterConfig) bool {
+		return cluster.Name == c.Auth.Kubernetes.Cluster
+	}) {
+		errs = append(errs, fmt.Errorf("auth.kubernetes.cluster: unknown cluster %q", c.Auth.Kubernetes.Cluster))
+	}
+	errs = append(errs, c.Federation.validate()...)
+	errs = append(errs, c.Focus.validate()...)
+	errs = append(errs, c.Reconciliation.validate()...)
+	errs = append(errs, c.ExternalCosts.validate()...)
+	errs = append(errs, c.Discounts.validate()...)
+	errs = append(errs, c.Commitments.validate()...)
+	errs = append(errs, c.Network.validate()...)
+	errs = append(errs, c.PriceHistory.validate()...)
+	errs = append(errs, c.Currency.validate()...)
+	if c.Focus.Enabled && c.Focus.Currency != "" && c.Focus.Currency != c.Currency.Base && !c.Currency.ExchangeRates.Enabled {
+		errs = append(errs, fmt.Errorf("focus.currency: %s differs from currency.base and requires currency.exchange_rates", c.Focus.Currency))
+	}
+	errs = append(errs, c.Invoicing.validate()...)
+	if c.Invoicing.Enabled && !c.Focus.Enabled {
+		errs = append(errs, fmt.Errorf("invoicing.enabled: requires focus.enabled, invoices are closed from the FOCUS ledger"))
+	}
+
+	channels := make(map[string]bool, len(c.Notifications))
+	for i, channel := range c.Notifications {
+		field := fmt.Sprintf("notifications[%d]", i)
+		if channel.Name == "" {
+			errs = append(errs, fmt.Errorf("%s.name: is required", field))
+		} else if channels[channel.Name] {
+			errs = append(errs, fmt.Errorf("%s.name: duplicate channel %q", field, channel.Name))
+		}
+		channels[channel.Name] = true
+
+		switch channel.Type {
+		case "slack", "webhook", "email":
+		default:
+			errs = append(errs, fmt.Errorf("%s.type: %q must be slack, webhook or email", field, channel.Type))
+		}
+		if channel.URL == "" {
+			errs = append(errs, fmt.Errorf("%s.url: is required", field))
+		}
+	}
+
+	for i, budget := range c.Budgets {
+		field := fmt.Sprintf("budgets[%d]", i)
+		if budget.Name == "" {
+			errs = append(errs, fmt.Errorf("%s.name: is required", field))
+		}
+		switch budget.Period {
+		case "daily", "weekly", "monthly":
+		default:
+			errs = append(errs, fmt.Errorf("%s.period: %q must be daily, weekly or monthly", field, budget.Period))
+		}
+		if budget.Amount <= 0 {
+			errs = append(errs, fmt.Errorf("%s.amount: must be positive", field))
+		}
+		for _, threshold := range budget.Thresholds {
+			if threshold <= 0 {
+				errs = append(errs, fmt.Errorf("%s.thresholds: %v must be positive", field, threshold))
+			}
+		}
+		for _, name := range budget.Channels {
+			if !channels[name] {
+				errs = append(errs, fmt.Errorf("%s.channels: unknown notification channel %q", field, name))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (a AuthConfig) validate() []error {
+	var errs []error
+
+	for i, origin := range a.AllowedOrigins {
+		if origin == "*" {
+			errs = append(errs, fmt.Errorf("auth.allowed_origins[%d]: wildcard origins are not allowed", i))
+		} else if !validHTTPURL(origin) {
+			errs = append(errs, fmt.Errorf("auth.allowed_origins[%d]: %q is not a valid http(s) origin", i, origin))
+		}
+	}
+
+	if !a.Enabled {
+		return errs
+	}
+
+	if len(a.Tokens) == 0 && a.OIDC.IssuerURL == "" && !a.Kubernetes.Enabled {
+		errs = append(errs, fmt.Errorf("auth: at least one token, an oidc issuer or kubernetes auth is required when enabled"))
+	}
+
+	if a.Kubernetes.Enabled && a.Kubernetes.CacheTTL <= 0 {
+		errs = append(errs, fmt.Errorf("auth.kubernetes.cache_ttl: must be positive"))
+	}
+	if a.Kubernetes.DevUser != "" && !a.Kubernetes.DevMode {
+		errs = append(errs, fmt.Errorf("auth.kubernetes.dev_user: requires dev_mode"))
+	}
+
+	names := make(map[string]bool, len(a.Tokens))
+	for i, token := range a.Tokens {
+		field := fmt.Sprintf("auth.tokens[%d]", i)
+		if token.Name == "" {
+			errs = append(errs, fmt.Errorf("%s.name: is required", field))
+		} else if names[token.Name] {
+			errs = append(errs, fmt.Errorf("%s.name: duplicate token %q", field, token.Name))
+		}
+		names[token.Name] = true
+
+		if digest, err := hex.DecodeString(token.SHA256); err != nil || len(digest) != sha256.Size {
+			errs = append(errs, fmt.Errorf("%s.sha256: must be a hex SHA-256 digest", field))
+		}
+		errs = append(errs, validateScopes(field, token.Scopes)...)
+	}
+
+	if a.OIDC.IssuerURL != "" {
+		if !validHTTPURL(a.OIDC.IssuerURL) {
+			errs = append(errs, fmt.Errorf("auth.oidc.issuer_url: %q is not a valid http(s) URL", a.OIDC.IssuerURL))
+		}
+		if a.OIDC.JWKSURL != "" && !validHTTPURL(a.OIDC.JWKSURL) {
+			errs = append(errs, fmt.Errorf("auth.oidc.jwks_url: %q is not a valid http(s) URL", a.OIDC.JWKSURL))
+		}
+		if a.OIDC.Audience == "" {
+			errs = append(errs, fmt.Errorf("auth.oidc.audience: is required"))
+		}
+		if a.OIDC.GroupsClaim == "" {
+			errs = append(errs, fmt.Errorf("auth.oidc.groups_claim: is required"))
+		}
+		for i, group := range a.OIDC.Groups {
+			field := fmt.Sprintf("auth.oidc.groups[%d]", i)
+			if group.Group == "" {
+				errs = append(errs, fmt.Errorf("%s.group: is required", field))
+			}
+			errs = append(errs, validateScopes(field, group.Scopes)...)
+		}
+	}
+
+	return errs
+}
+
+func validateScopes(field string, scopes []ScopeConfig) []error {
+	if len(scopes) == 0 {
+		return []error{fmt.Errorf("%s.scopes: at least one scope is required", field)}
+	}
+
+	var errs []error
+	for i, scope := range scopes {
+		if len(scope.Namespaces) == 0 {
+			errs = append(errs, fmt.Errorf("%s.scopes[%d].namespaces: is required, use \"*\" for all namespaces", field, i))
+		}
+	}
+	return errs
+}
+
+func (f FederationConfig) validate() []error {
+	var errs []error
+
+	switch f.Mode {
+	case "":
+		return nil
+	case "hub", "spoke":
+	default:
+		return []error{fmt.Errorf("federation.mode: %q must be hub or spoke", f.Mode)}
+	}
+
+	if f.Mode == "spoke" {
+		if len(f.Token) < 16 {
+			errs = append(errs, fmt.Errorf("federation.token: must be at least 16 characters"))
+		}
+		if f.Spoke.Name == "" {
+			errs = append(errs, fmt.Errorf("federation.spoke.name: is required"))
+		}
+		if f.Spoke.HubURL != "" && !validHTTPURL(f.Spoke.HubURL) {
+			errs = append(errs, fmt.Errorf("federation.spoke.hub_url: %q is not a valid http(s) URL", f.Spoke.HubURL))
+		}
+		if f.Spoke.Interval <= 0 {
+			errs = append(errs, fmt.Errorf("federation.spoke.interval: must be positive"))
+		}
+	}
+
+	if f.Mode == "hub" {
+		if f.Hub.StoragePath == "" {
+			errs = append(errs, fmt.Errorf("federation.hub.storage_path: is required"))
+		}
+		if f.Hub.StaleAfter <= 0 {
+			errs = append(errs, fmt.Errorf("federation.hub.stale_after: must be positive"))
+		}
+		names := make(map[string]bool, len(f.Hub.Spokes))
+		tokens := make(map[string]bool, len(f.Hub.Spokes))
+		pulls := false
+		for i, spoke := range f.Hub.Spokes {
+			if spoke.Name == "" {
+				errs = append(errs, fmt.Errorf("federation.hub.spokes[%d].name: is required", i))
+			} else if names[spoke.Name] {
+				errs = append(errs, fmt.Errorf("federation.hub.spokes[%d].name: %q is listed more than once", i, spoke.Name))
+			}
+			names[spoke.Name] = true
+			if len(spoke.Token) < 16 {
+				errs = append(errs, fmt.Errorf("federation.hub.spokes[%d].token: must be at least 16 characters", i))
+			} else if tokens[spoke.Token] {
+				errs = append(errs, fmt.Errorf("federation.hub.spokes[%d].token: is shared with another spoke", i))
+			}
+			tokens[spoke.Token] = true
+			if spoke.URL != "" && !validHTTPURL(spoke.URL) {
+				errs = append(errs, fmt.Errorf("federation.hub.spokes[%d].url: %q is not a valid http(s) URL", i, spoke.URL))
+			}
+			pulls = pulls || spoke.URL != ""
+		}
+		if pulls && f.Hub.PullInterval <= 0 {
+			errs = append(errs, fmt.Errorf("federation.hub.pull_interval: must be positive"))
+		}
+	}
+
+	return errs
+}
+
+func (f FocusConfig) validate() []error {
+	if !f.Enabled {
+		return nil
+	}
+
+	var errs []error
+	if f.StoragePath == "" {
+		errs = append(errs, fmt.Errorf("focus.storage_path: is required"))
+	}
+	if f.BillingAccountID == "" {
+		errs = append(errs, fmt.Errorf("focus.billing_account_id: is required"))
+	}
+	if f.Currency != "" && !currencyPattern.MatchString(f.Currency) {
+		errs = append(errs, fmt.Errorf("focus.currency: %q must be an ISO 4217 code such as USD", f.Currency))
+	}
+	return errs
+}
+
+func (r ReconciliationConfig) validate() []error {
+	if !r.Enabled {
+		return nil
+	}
+
+	var errs []error
+	if r.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("reconciliation.interval: must be positive"))
+	}
+	if len(r.Files) == 0 {
+		errs = append(errs, fmt.Errorf("reconciliation.files: at least one billing file is required"))
+	}
+	for i, file := range r.Files {
+		if file.Path == "" {
+			errs = append(errs, fmt.Errorf("reconciliation.files[%d].path: is required", i))
+		}
+		switch file.Format {
+		case "aws-cur", "gcp", "azure":
+		default:
+			errs = append(errs, fmt.Errorf("reconciliation.files[%d].format: %q must be aws-cur, gcp or azure", i, file.Format))
+		}
+	}
+	return errs
+}
+
+func (e ExternalCostsConfig) validate() []error {
+	if !e.Enabled {
+		return nil
+	}
+
+	var errs []error
+	if e.StoragePath == "" {
+		errs = append(errs, fmt.Errorf("external_costs.storage_path: is required"))
+	}
+	if e.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("external_costs.interval: must be positive"))
+	}
+	for i, file := range e.Files {
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".csv", ".json", ".ndjson":
+		default:
+			errs = append(errs, fmt.Errorf("external_costs.files[%d]: %q must end in .csv, .json or .ndjson", i, file))
+		}
+	}
+	return errs
+}
+
+func (d DiscountsConfig) validate() []error {
+	if !d.Enabled {
+		return nil
+	}
+
+	var errs []error
+	if d.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("discounts.interval: must be positive"))
+	}
+	if d.Spot.Discount < 0 || d.Spot.Discount >= 1 {
+		errs = append(errs, fmt.Errorf("discounts.spot.discount: must be at least 0 and less than 1"))
+	}
+	for instanceType, discount := range d.Spot.InstanceTypes {
+		if discount < 0 || discount >= 1 {
+			errs = append(errs, fmt.Errorf("discounts.spot.instance_types.%s: must be at least 0 and less than 1", instanceType))
+		}
+	}
+	for key := range d.Spot.Labels {
+		if key == "" {
+			errs = append(errs, fmt.Errorf("discounts.spot.labels: label names must not be empty"))
+		}
+	}
+
+	reserved := make(map[string]bool, len(d.Reservations))
+	for i, reservation := range d.Reservations {
+		field := fmt.Sprintf("discounts.reservations[%d]", i)
+		if reservation.InstanceType == "" {
+			errs = append(errs, fmt.Errorf("%s.instance_type: is required", field))
+		} else if reserved[reservation.InstanceType] {
+			errs = append(errs, fmt.Errorf("%s.instance_type: duplicate instance type %q", field, reservation.InstanceType))
+		}
+		reserved[reservation.InstanceType] = true
+		if reservation.Count <= 0 {
+			errs = append(errs, fmt.Errorf("%s.count: must be positive", field))
+		}
+		if reservation.Discount < 0 || reservation.Discount >= 1 {
+			errs = append(errs, fmt.Errorf("%s.discount: must be at least 0 and less than 1", field))
+		}
+	}
+
+	plans := make(map[string]bool, len(d.SavingsPlans))
+	for i, plan := range d.SavingsPlans {
+		field := fmt.Sprintf("discounts.savings_plans[%d]", i)
+		if plan.Name == "" {
+			errs = append(errs, fmt.Errorf("%s.name: is required", field))
+		} else if plans[plan.Name] {
+			errs = append(errs, fmt.Errorf("%s.name: duplicate savings plan %q", field, plan.Name))
+		}
+		plans[plan.Name] = true
+		if plan.CommitmentPerHour <= 0 {
+			errs = append(errs, fmt.Errorf("%s.commitment_per_hour: must be positive", field))
+		}
+		if plan.Discount < 0 || plan.Discount >= 1 {
+			errs = append(errs, fmt.Errorf("%s.discount: must be at least 0 and less than 1", field))
+		}
+	}
+	return errs
+}
+
+func (c CommitmentsConfig) validate() []error {
+	if !c.Enabled {
+		return nil
+	}
+
+	var errs []error
+	if len(c.WindowDays) == 0 {
+		errs = append(errs, fmt.Errorf("commitments.window_days: at least one window is required"))
+	}
+	for i, days := range c.WindowDays {
+		if days <= 0 || days > 365 {
+			errs = append(errs, fmt.Errorf("commitments.window_days[%d]: must be between 1 and 365", i))
+		}
+	}
+	if len(c.Percentiles) == 0 {
+		errs = append(errs, fmt.Errorf("commitments.percentiles: at least one percentile is required"))
+	}
+	for i, percentile := range c.Percentiles {
+		if percentile < 0 || percentile > 100 {
+			errs = append(errs, fmt.Errorf("commitments.percentiles[%d]: must be between 0 and 100", i))
+		}
+	}
+	if c.TermMonths <= 0 {
+		errs = append(errs, fmt.Errorf("commitments.term_months: must be positive"))
+	}
+	if c.ReservedDiscount <= 0 || c.ReservedDiscount >= 1 {
+		errs = append(errs, fmt.Errorf("commitments.reserved_discount: must be between 0 and 1"))
+	}
+	if c.SavingsPlanDiscount <= 0 || c.SavingsPlanDiscount >= 1 {
+		errs = append(errs, fmt.Errorf("commitments.savings_plan_discount: must be between 0 and 1"))
+	}
+	return errs
+}
+
+func validHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func (p *PricingConfig) validate(field string) []error {
+	var errs []error
+	if p.CPUCostPerHour < 0 {
+		errs = append(errs, fmt.Errorf("%s.cpu_cost_per_hour: must not be negative", field))
+	}
+	if p.MemoryCostPerGB < 0 {
+		errs = append(errs, fmt.Errorf("%s.memory_cost_per_gb: must not be negative", field))
+	}
+	if p.StorageCostPerGB < 0 {
+		errs = append(errs, fmt.Errorf("%s.storage_cost_per_gb: must not be negative", field))
+	}
+	if p.EphemeralStorageCostPerGB < 0 {
+		errs = append(errs, fmt.Errorf("%s.ephemeral_storage_cost_per_gb: must not be negative", field))
+	}
+	if p.Network.IntraZoneCostPerGB < 0 {
+		errs = append(errs, fmt.Errorf("%s.network.intra_zone_cost_per_gb: must not be negative", field))
+	}
+	if p.Network.CrossZoneCostPerGB < 0 {
+		errs = append(errs, fmt.Errorf("%s.network.cross_zone_cost_per_gb: must not be negative", field))
+	}
+	if p.Network.InternetEgressCostPerGB < 0 {
+		errs = append(errs, fmt.Errorf("%s.network.internet_egress_cost_per_gb: must not be negative", field))
+	}
+	if err := p.ParseSchedules(); err != nil {
+		errs = append(errs, fmt.Errorf("%s.%v", field, err))
+	}
+	names := make(map[string]bool, len(p.Schedules))
+	for i, schedule := range p.Schedules {
+		switch {
+		case schedule.Name == "":
+			errs = append(errs, fmt.Errorf("%s.schedules[%d].name: is required", field, i))
+		case schedule.Name == PeakPeriod:
+			errs = append(errs, fmt.Errorf("%s.schedules[%d].name: %q is reserved for unscheduled time", field, i, PeakPeriod))
+		case names[schedule.Name]:
+			errs = append(errs, fmt.Errorf("%s.schedules[%d].name: duplicate schedule %q", field, i, schedule.Name))
+		}
+		names[schedule.Name] = true
+		if schedule.Multiplier < 0 {
+			errs = append(errs, fmt.Errorf("%s.schedules[%d].multiplier: must not be negative", field, i))
+		}
+	}
+	for resource, pricing := range p.ExtendedResources {
+		switch resource {
+		case "", "cpu", "memory", "ephemeral-storage":
+			errs = append(errs, fmt.Errorf("%s.extended_resources: %q is not an extended resource", field, resource))
+			continue
+		}
+		if pricing.CostPerHour < 0 {
+			errs = append(errs, fmt.Errorf("%s.extended_resources.%s.cost_per_hour: must not be negative", field, resource))
+		}
+		for instanceType, rate := range pricing.InstanceTypes {
+			if rate < 0 {
+				errs = append(errs, fmt.Errorf("%s.extended_resources.%s.instance_types.%s: must not be negative", field, resource, instanceType))
+			}
+		}
+	}
+	return errs
+}
+
+func (c PriceHistoryConfig) validate() []error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.StoragePath == "" {
+		return []error{fmt.Errorf("price_history.storage_path: is required")}
+	}
+	return nil
+}
+
+func (c CurrencyConfig) validate() []error {
+	var errs []error
+	if !currencyPattern.MatchString(c.Base) {
+		errs = append(errs, fmt.Errorf("currency.base: %q must be an ISO 4217 code such as USD", c.Base))
+	}
+	if c.Precision < 0 || c.Precision > 12 {
+		errs = append(errs, fmt.Errorf("currency.precision: must be between 0 and 12"))
+	}
+	if !slices.Contains(money.Modes, c.Rounding) {
+		errs = append(errs, fmt.Errorf("currency.rounding: %q must be one of %s", c.Rounding, strings.Join(money.Modes, ", ")))
+	}
+
+	rates := c.ExchangeRates
+	if !rates.Enabled {
+		return errs
+	}
+	if rates.StoragePath == "" {
+		errs = append(errs, fmt.Errorf("currency.exchange_rates.storage_path: is required"))
+	}
+	if rates.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("currency.exchange_rates.interval: must be positive"))
+	}
+	for i, file := range rates.Files {
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".csv", ".json", ".ndjson":
+		default:
+			errs = append(errs, fmt.Errorf("currency.exchange_rates.files[%d]: %q must end in .csv, .json or .ndjson", i, file))
+		}
+	}
+	return errs
+}
+
+func (c InvoicingConfig) validate() []error {
+	if !c.Enabled {
+		return nil
+	}
+
+	var errs []error
+	if c.StoragePath == "" {
+		errs = append(errs, fmt.Errorf("invoicing.storage_path: is required"))
+	}
+	if !invoicePrefixPattern.MatchString(c.NumberPrefix) {
+		errs = append(errs, fmt.Errorf("invoicing.number_prefix: %q must be 1-16 letters, digits or dashes", c.NumberPrefix))
+	}
+	if c.Precision < 0 || c.Precision > 12 {
+		errs = append(errs, fmt.Errorf("invoicing.precision: must be between 0 and 12"))
+	}
+	if c.CloseAfter < 0 {
+		errs = append(errs, fmt.Errorf("invoicing.close_after: must not be negative"))
+	}
+	if c.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("invoicing.interval: must be positive"))
+	}
+	if c.Unallocated == "" {
+		errs = append(errs, fmt.Errorf("invoicing.unallocated: is required"))
+	}
+	for i, pattern := range c.SharedNamespaces {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invoicing.shared_namespaces[%d]: invalid pattern %q", i, pattern))
+		}
+	}
+
+	names := map[string]bool{c.Unallocated: true}
+	for i, center := range c.CostCenters {
+		field := fmt.Sprintf("invoicing.cost_centers[%d]", i)
+		if center.Name == "" {
+			errs = append(errs, fmt.Errorf("%s.name: is required", field))
+		} else if names[center.Name] {
+			errs = append(errs, fmt.Errorf("%s.name: duplicate cost center %q", field, center.Name))
+		}
+		names[center.Name] = true
+		if len(center.Namespaces) == 0 && len(center.Labels) == 0 {
+			errs = append(errs, fmt.Errorf("%s: namespaces or labels are required", field))
+		}
+		for j, pattern := range center.Namespaces {
+			if _, err := path.Match(pattern, ""); err != nil {
+				errs = append(errs, fmt.Errorf("%s.namespaces[%d]: invalid pattern %q", field, j, pattern))
+			}
+		}
+		if center.Discount < 0 || center.Discount > 1 {
+			errs = append(errs, fmt.Errorf("%s.discount: must be between 0 and 1", field))
+		}
+	}
+	return errs
+}
+
+func (c NetworkConfig) validate() []error {
+	if c.FlowMetric == "" {
+		return nil
+	}
+
+	var errs []error
+	if !metricNamePattern.MatchString(c.FlowMetric) {
+		errs = append(errs, fmt.Errorf("network.flow_metric: %q is not a valid metric name", c.FlowMetric))
+	}
+	for label := range c.Matchers {
+		if !labelNamePattern.MatchString(label) {
+			errs = append(errs, fmt.Errorf("network.matchers: %q is not a valid label name", label))
+		}
+	}
+	if c.SourceNamespaceLabel == "" || c.SourcePodLabel == "" {
+		errs = append(errs, fmt.Errorf("network.source_namespace_label, network.source_pod_label: are required with a flow metric"))
+	}
+	if c.DestinationZoneLabel == "" && c.DestinationPodLabel == "" && c.DestinationIPLabel == "" {
+		errs = append(errs, fmt.Errorf("network: one of destination_zone_label, destination_pod_label or destination_ip_label is required with a flow metric"))
+	}
+	labels := []struct{ field, name string }{
+		{"source_namespace_label", c.SourceNamespaceLabel},
+		{"source_pod_label", c.SourcePodLabel},
+		{"destination_zone_label", c.DestinationZoneLabel},
+		{"destination_namespace_label", c.DestinationNamespaceLabel},
+		{"destination_pod_label", c.DestinationPodLabel},
+		{"destination_ip_label", c.DestinationIPLabel},
+	}
+	for _, label := range labels {
+		if label.name != "" && !labelNamePattern.MatchString(label.name) {
+			errs = append(errs, fmt.Errorf("network.%s: %q is not a valid label name", label.field, label.name))
+		}
+	}
+	return errs
+}
+
+// ClusterConfigs returns the configured clusters. When none are listed, the
+// top-level kubernetes and prometheus settings describe a single cluster, so
+// single-cluster deployments need no clusters section.
+func (c *Config) ClusterConfigs() []ClusterConfig {
+	if len(c.Clusters) > 0 {
+		return c.Clusters
+	}
+
+	return []ClusterConfig{{
+		Name:          c.Kubernetes.ClusterName,
+		KubeConfig:    c.Kubernetes.KubeConfigPath,
+		PrometheusURL: c.Prometheus.URL,
+	}}
+}
+
+// PricingFor returns the pricing that applies to the named cluster.
+func (c *Config) PricingFor(cluster string) PricingConfig {
+	for _, cc := range c.Clusters {
+		if cc.Name == cluster && cc.Pricing != nil {
+			return *cc.Pricing
+		}
+	}
+	return c.Pricing
+}
+
+// ConfigStore holds the current configuration. Components read from it on
+// every use so that a hot-reloaded config takes effect without a restart.
+type ConfigStore struct {
+	current atomic.Pointer[Config]
+}
+
+func NewConfigStore(cfg *Config) *ConfigStore {
+	store := &ConfigStore{}
+	store.current.Store(cfg)
+	return store
+}
+
+func (s *ConfigStore) Current() *Config {
+	return s.current.Load()
+}
+
+func (s *ConfigStore) Set(cfg *Config) {
+	s.current.Store(cfg)
+}
+
+func applyEnv(cfg *Config) error {
+	var errs []error
+
+	setString(&cfg.Prometheus.URL, "PROMETHEUS_URL")
+	setString(&cfg.Kubernetes.KubeConfigPath, "KUBE_CONFIG_PATH")
+	setString(&cfg.Kubernetes.ClusterName, "CLUSTER_NAME")
+	setString(&cfg.Server.Port, "PORT")
+	setString(&cfg.Server.InternalPort, "INTERNAL_PORT")
+	setString(&cfg.Logging.Format, "LOG_FORMAT")
+	setString(&cfg.Logging.Level, "LOG_LEVEL")
+	setString(&cfg.Federation.Token, "FEDERATION_TOKEN")
+	setString(&cfg.Currency.Base, "BASE_CURRENCY")
+
+	errs = append(errs,
+		setBool(&cfg.Server.EnablePprof, "ENABLE_PPROF"),
+		setBool(&cfg.Auth.Enabled, "AUTH_ENABLED"),
+		setDuration(&cfg.Server.HealthTimeout, "HEALTH_TIMEOUT"),
+		setDuration(&cfg.Server.HealthCacheTTL, "HEALTH_CACHE_TTL"),
+		setDuration(&cfg.Collectors.Interval, "METRICS_INTERVAL"),
+		setInt(&cfg.Collectors.ExporterMaxPods, "EXPORTER_MAX_PODS"),
+		setFloat(&cfg.Pricing.CPUCostPerHour, "CPU_COST_PER_HOUR"),
+		setFloat(&cfg.Pricing.MemoryCostPerGB, "MEMORY_COST_PER_GB"),
+		setFloat(&cfg.Pricing.StorageCostPerGB, "STORAGE_COST_PER_GB"),
+		setFloat(&cfg.Pricing.EphemeralStorageCostPerGB, "EPHEMERAL_STORAGE_COST_PER_GB"),
+	)
+
+	return errors.Join(errs...)
+}
+
+func applyOverrides(cfg *Config, overrides Overrides) {
+	if overrides.Port != "" {
+		cfg.Server.Port = overrides.Port
+	}
+	if overrides.PrometheusURL != "" {
+		cfg.Prometheus.URL = overrides.PrometheusURL
+	}
+	if overrides.KubeConfigPath != "" {
+		cfg.Kubernetes.KubeConfigPath = overrides.KubeConfigPath
+	}
+	if overrides.LogLevel != "" {
+		cfg.Logging.Level = overrides.LogLevel
+	}
+}
+
+func setString(target *string, key string) {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		*target = value
+	}
+}
+
+func setFloat(target *float64, key string) error {
 	value := os.Getenv(key)
-	if value != "" {
-		return value
+	if value == "" {
+		return nil
 	}
-	return defaultValue
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%s: %q is not a number", key, value)
+	}
+
+	*target = parsed
+	return nil
 }
 
-func getFloatEnv(key string, defaultValue float64) float64 {
+func setInt(target *int, key string) error {
 	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s: %q is not an integer", key, value)
+	}
 
+	*target = parsed
+	return nil
+}
+
+func setBool(target *bool, key string) error {
+	value := os.Getenv(key)
 	if value == "" {
-		return defaultValue
+		return nil
 	}
 
-	parsed, err := strconv.ParseFloat(value, 64)
+	parsed, err := strconv.ParseBool(value)
 	if err != nil {
-		return defaultValue
+		return fmt.Errorf("%s: %q is not a boolean", key, value)
 	}
 
-	return parsed
+	*target = parsed
+	return nil
 }
 
-func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+func setDuration(target *time.Duration, key string) error {
 	value := os.Getenv(key)
 	if value == "" {
-		return defaultValue
+		return nil
 	}
 
 	parsed, err := time.ParseDuration(value)
 	if err != nil {
-		return defaultValue
+		return fmt.Errorf("%s: %q is not a duration", key, value)
 	}
 
-	return parsed
+	*target = parsed
+	return nil
 }
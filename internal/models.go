@@ -1,77 +1,257 @@
 package internal
 
-import "time"
+import (
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+)
 
 type CostBreakdown struct {
-	CPUCost     float64 `json:"cpu_cost"`
-	MemoryCost  float64 `json:"memory_cost"`
-	StorageCost float64 `json:"storage_cost"`
-	NetworkCost float64 `json:"network_cost"`
-	TotalCost   float64 `json:"totalCost"`
+	CPUCost     money.Amount `json:"cpu_cost"`
+	MemoryCost  money.Amount `json:"memory_cost"`
+	StorageCost money.Amount `json:"storage_cost"`
+	NetworkCost money.Amount `json:"network_cost"`
+	TotalCost   money.Amount `json:"totalCost"`
 }
 
 type CostOverview struct {
+	Cluster        string          `json:"cluster,omitempty"`
+	Currency       string          `json:"currency"`
 	TotalCost      CostBreakdown   `json:"total_cost"`
 	NamespacesCost []NamespaceCost `json:"namespace_costs"`
+	Clusters       []ClusterCost   `json:"clusters,omitempty"`
 	Timestamp      time.Time       `json:"timestamp"`
 }
 
+// ClusterCost is the rollup of every namespace in one cluster. Error is set
+// when the cluster could not be reached and its costs are missing.
+type ClusterCost struct {
+	Cluster        string       `json:"cluster"`
+	CPUCost        money.Amount `json:"cpu_cost"`
+	MemoryCost     money.Amount `json:"memory_cost"`
+	StorageCost    money.Amount `json:"storage_cost"`
+	NetworkCost    money.Amount `json:"network_cost"`
+	TotalCost      money.Amount `json:"total_cost"`
+	ListCost       money.Amount `json:"list_cost"`
+	Savings        money.Amount `json:"savings"`
+	NamespaceCount int          `json:"namespace_count"`
+	PodCount       int          `json:"pod_count"`
+	Error          string       `json:"error,omitempty"`
+	Timestamp      time.Time    `json:"timestamp"`
+}
+
+// AggregatedCost is the cost of every pod sharing an aggregation key, such as
+// a namespace name or a label value, across one or more clusters, plus the
+// hourly rate of the external costs carrying the same key.
+type AggregatedCost struct {
+	Key           string                  `json:"key"`
+	Clusters      []string                `json:"clusters"`
+	CPUCost       money.Amount            `json:"cpu_cost"`
+	MemoryCost    money.Amount            `json:"memory_cost"`
+	StorageCost   money.Amount            `json:"storage_cost"`
+	NetworkCost   money.Amount            `json:"network_cost"`
+	NetworkEgress map[string]money.Amount `json:"network_egress,omitempty"`
+	ExternalCost  money.Amount            `json:"external_cost"`
+	TotalCost     money.Amount            `json:"total_cost"`
+	PodCount      int                     `json:"pod_count"`
+	ExternalCount int                     `json:"external_count"`
+	Timestamp     time.Time               `json:"timestamp"`
+}
+
+// ExternalCost is a cost incurred outside the cluster, such as a managed
+// database or SaaS seats, attributed through its labels. Cost is the total
+// over [Start, End).
+type ExternalCost struct {
+	ID          string            `json:"id"`
+	Source      string            `json:"source"`
+	Description string            `json:"description,omitempty"`
+	Cost        money.Amount      `json:"cost"`
+	Start       time.Time         `json:"start"`
+	End         time.Time         `json:"end"`
+	Labels      map[string]string `json:"labels"`
+}
+
 type NamespaceCost struct {
-	Namespace   string    `json:"namespace"`
-	CPUCost     float64   `json:"cpu_cost"`
-	MemoryCost  float64   `json:"memory_cost"`
-	StorageCost float64   `json:"storage_cost"`
-	NetworkCost float64   `json:"network_cost"`
-	TotalCost   float64   `json:"total_cost"`
-	PodCount    int       `json:"pod_count"`
-	Pods        []PodCost `json:"pods,omitempty"`
-	Timestamp   time.Time `json:"timestamp"`
+	Cluster              string                  `json:"cluster"`
+	Namespace            string                  `json:"namespace"`
+	Labels               map[string]string       `json:"labels,omitempty"`
+	CPUCost              money.Amount            `json:"cpu_cost"`
+	MemoryCost           money.Amount            `json:"memory_cost"`
+	StorageCost          money.Amount            `json:"storage_cost"`
+	NetworkCost          money.Amount            `json:"network_cost"`
+	NetworkEgress        map[string]money.Amount `json:"network_egress,omitempty"`
+	EphemeralStorageCost money.Amount            `json:"ephemeral_storage_cost"`
+	ExtendedCost         money.Amount            `json:"extended_cost"`
+	ExtendedResources    map[string]money.Amount `json:"extended_resources,omitempty"`
+	TotalCost            money.Amount            `json:"total_cost"`
+	ListCost             money.Amount            `json:"list_cost"`
+	Savings              money.Amount            `json:"savings"`
+	PodCount             int                     `json:"pod_count"`
+	Pods                 []PodCost               `json:"pods,omitempty"`
+	Timestamp            time.Time               `json:"timestamp"`
 }
 
 type PodCost struct {
-	Name        string    `json:"name"`
-	Namespace   string    `json:"namespace"`
-	CPUCost     float64   `json:"cpu_cost"`
-	MemoryCost  float64   `json:"memory_cost"`
-	StorageCost float64   `json:"storage_cost"`
-	NetworkCost float64   `json:"network_cost"`
-	TotalCost   float64   `json:"total_cost"`
-	CPUUsage    float64   `json:"cpu_usage"`
-	MemoryUsage int64     `json:"memory_usage"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"created_at"`
-	Timestamp   time.Time `json:"timestamp"`
+	Cluster               string                  `json:"cluster"`
+	Name                  string                  `json:"name"`
+	Namespace             string                  `json:"namespace"`
+	Node                  string                  `json:"node"`
+	ControllerKind        string                  `json:"controller_kind,omitempty"`
+	Controller            string                  `json:"controller,omitempty"`
+	Labels                map[string]string       `json:"labels,omitempty"`
+	CPUCost               money.Amount            `json:"cpu_cost"`
+	MemoryCost            money.Amount            `json:"memory_cost"`
+	StorageCost           money.Amount            `json:"storage_cost"`
+	NetworkCost           money.Amount            `json:"network_cost"`
+	NetworkEgress         map[string]money.Amount `json:"network_egress,omitempty"`
+	EphemeralStorageCost  money.Amount            `json:"ephemeral_storage_cost"`
+	ExtendedCost          money.Amount            `json:"extended_cost"`
+	ExtendedResources     map[string]money.Amount `json:"extended_resources,omitempty"`
+	TotalCost             money.Amount            `json:"total_cost"`
+	ListCost              money.Amount            `json:"list_cost"`
+	Savings               money.Amount            `json:"savings"`
+	NodeRate              float64                 `json:"node_rate"`
+	StorageRate           float64                 `json:"storage_rate"`
+	CPUUsage              float64                 `json:"cpu_usage"`
+	MemoryUsage           int64                   `json:"memory_usage"`
+	EphemeralStorageUsage int64                   `json:"ephemeral_storage_usage"`
+	Status                string                  `json:"status"`
+	CreatedAt             time.Time               `json:"created_at"`
+	Timestamp             time.Time               `json:"timestamp"`
 }
 
+// NodeCost is the cost of one node. ExtendedCost prices the node's full
+// capacity of extended resources such as GPUs; ExtendedIdleCost is the part
+// of it that no running pod requests and GPUIdleCost the GPU share of that.
+// BootDiskCost prices the node's root disk, which pods share through their
+// ephemeral storage. Rate is the factor between the node's effective and
+// list cost of CPU, memory and extended resources.
 type NodeCost struct {
-	Name           string    `json:"name"`
-	CPUCost        float64   `json:"cpu_cost"`
-	MemoryCost     float64   `json:"memory_cost"`
-	StorageCost    float64   `json:"storage_cost"`
-	TotalCost      float64   `json:"total_cost"`
-	CPUCapacity    string    `json:"cpu_capacity"`
-	MemoryCapacity string    `json:"memory_capacity"`
-	CPUUsage       float64   `json:"cpu_usage"`
-	MemoryUsage    int64     `json:"memory_usage"`
-	PodCount       int       `json:"pod_count"`
-	Status         string    `json:"status"`
-	Timestamp      time.Time `json:"timestamp"`
+	Cluster           string                  `json:"cluster"`
+	Name              string                  `json:"name"`
+	CPUCost           money.Amount            `json:"cpu_cost"`
+	MemoryCost        money.Amount            `json:"memory_cost"`
+	StorageCost       money.Amount            `json:"storage_cost"`
+	BootDiskCost      money.Amount            `json:"boot_disk_cost"`
+	ExtendedCost      money.Amount            `json:"extended_cost"`
+	ExtendedResources map[string]money.Amount `json:"extended_resources,omitempty"`
+	ExtendedIdleCost  money.Amount            `json:"extended_idle_cost"`
+	GPUIdleCost       money.Amount            `json:"gpu_idle_cost"`
+	TotalCost         money.Amount            `json:"total_cost"`
+	ListCost          money.Amount            `json:"list_cost"`
+	Savings           money.Amount            `json:"savings"`
+	Rate              float64                 `json:"rate"`
+	PricingModel      string                  `json:"pricing_model"`
+	CPUCapacity       string                  `json:"cpu_capacity"`
+	MemoryCapacity    string                  `json:"memory_capacity"`
+	CPUUsage          float64                 `json:"cpu_usage"`
+	MemoryUsage       int64                   `json:"memory_usage"`
+	PodCount          int                     `json:"pod_count"`
+	Status            string                  `json:"status"`
+	Timestamp         time.Time               `json:"timestamp"`
 }
 
 type CostHistory struct {
+	Cluster   string             `json:"cluster,omitempty"`
+	Currency  string             `json:"currency"`
 	Period    string             `json:"period"`
 	StartTime time.Time          `json:"start_time"`
 	EndTime   time.Time          `json:"end_time"`
 	Data      []CostHistoryPoint `json:"data"`
+	// PricingPeriods breaks the cost of the history down by the pricing
+	// period, such as peak or off-peak, each point fell in.
+	PricingPeriods []PeriodCost `json:"pricing_periods,omitempty"`
 }
 
+// CostHistoryPoint holds the hourly cost rates at Timestamp, at the rates
+// of the pricing period then in effect.
 type CostHistoryPoint struct {
-	Timestamp   time.Time `json:"timestamp"`
-	CPUCost     float64   `json:"cpu_cost"`
-	MemoryCost  float64   `json:"memory_cost"`
-	StorageCost float64   `json:"storage_cost"`
-	NetworkCost float64   `json:"network_cost"`
-	TotalCost   float64   `json:"total_cost"`
+	Timestamp     time.Time    `json:"timestamp"`
+	PricingPeriod string       `json:"pricing_period,omitempty"`
+	CPUCost       money.Amount `json:"cpu_cost"`
+	MemoryCost    money.Amount `json:"memory_cost"`
+	StorageCost   money.Amount `json:"storage_cost"`
+	NetworkCost   money.Amount `json:"network_cost"`
+	TotalCost     money.Amount `json:"total_cost"`
+}
+
+// PeriodCost is the cost incurred during one pricing period of a range
+// and the hours the range spent in it.
+type PeriodCost struct {
+	Period string       `json:"period"`
+	Hours  float64      `json:"hours"`
+	Cost   money.Amount `json:"cost"`
+}
+
+// ExchangeRate converts From into To from EffectiveFrom until the next
+// rate for the same pair takes effect: one unit of From is Rate units of
+// To.
+type ExchangeRate struct {
+	From          string    `json:"from"`
+	To            string    `json:"to"`
+	Rate          float64   `json:"rate"`
+	EffectiveFrom time.Time `json:"effective_from"`
+}
+
+// PriceVersion is the pricing of every cluster, by name, from
+// EffectiveFrom until the next version takes effect.
+type PriceVersion struct {
+	ID            int                      `json:"id"`
+	EffectiveFrom time.Time                `json:"effective_from"`
+	Pricing       map[string]PricingConfig `json:"pricing"`
+}
+
+// PriceComparison is the cost history of a past period as it was priced,
+// with the version in effect at each point, and as it would have been
+// priced under Version throughout.
+type PriceComparison struct {
+	Version      int          `json:"version"`
+	Currency     string       `json:"currency"`
+	StartTime    time.Time    `json:"start_time"`
+	EndTime      time.Time    `json:"end_time"`
+	Step         string       `json:"step"`
+	ActualCost   money.Amount `json:"actual_cost"`
+	RepricedCost money.Amount `json:"repriced_cost"`
+	Difference   money.Amount `json:"difference"`
+	Actual       *CostHistory `json:"actual"`
+	Repriced     *CostHistory `json:"repriced"`
+}
+
+// AllocationSnapshot is the compacted cost state of one cluster that a spoke
+// sends to the hub. ID is a content hash, so resending an unchanged
+// snapshot is a no-op on the hub. Costs are in Currency, the spoke's base
+// currency; snapshots from spokes that predate it leave it empty.
+type AllocationSnapshot struct {
+	ID          string          `json:"id"`
+	Source      string          `json:"source"`
+	Cluster     string          `json:"cluster"`
+	Currency    string          `json:"currency,omitempty"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Namespaces  []NamespaceCost `json:"namespaces"`
+	Nodes       []NodeCost      `json:"nodes"`
+}
+
+// FederatedCluster is the hub's view of one spoke cluster. Stale is set once
+// the latest snapshot is older than the configured threshold, for example
+// because the spoke is unreachable.
+type FederatedCluster struct {
+	Cluster     string      `json:"cluster"`
+	Source      string      `json:"source"`
+	SnapshotID  string      `json:"snapshot_id"`
+	GeneratedAt time.Time   `json:"generated_at"`
+	ReceivedAt  time.Time   `json:"received_at"`
+	Stale       bool        `json:"stale"`
+	LastError   string      `json:"last_error,omitempty"`
+	Cost        ClusterCost `json:"cost"`
+}
+
+type FederatedView struct {
+	Currency   string             `json:"currency"`
+	TotalCost  CostBreakdown      `json:"total_cost"`
+	Clusters   []FederatedCluster `json:"clusters"`
+	Namespaces []NamespaceCost    `json:"namespace_costs"`
+	Stale      bool               `json:"stale"`
+	Timestamp  time.Time          `json:"timestamp"`
 }
 
 type ResourceUsage struct {
@@ -84,6 +264,7 @@ type ResourceUsage struct {
 }
 
 type ClusterMetrics struct {
+	Cluster            string        `json:"cluster"`
 	TotalNodes         int           `json:"total_nodes"`
 	TotalPods          int           `json:"total_pods"`
 	TotalNamespaces    int           `json:"total_namespaces"`
@@ -95,6 +276,7 @@ type ClusterMetrics struct {
 }
 
 type PromMetrics struct {
+	Cluster        string        `json:"cluster"`
 	CPUMetrics     []MetricPoint `json:"cpu_metrics"`
 	MemoryMetrics  []MetricPoint `json:"memory_metrics"`
 	NetworkMetrics []MetricPoint `json:"network_metrics"`
@@ -108,10 +290,36 @@ type MetricPoint struct {
 	Labels    map[string]string `json:"labels"`
 }
 
+// AllocationRow is one flattened allocation record: the cost of a single
+// resource of a single pod over a window. See pkg/export for the schema.
+type AllocationRow struct {
+	WindowStart    time.Time    `json:"window_start"`
+	WindowEnd      time.Time    `json:"window_end"`
+	Cluster        string       `json:"cluster"`
+	Namespace      string       `json:"namespace"`
+	ControllerKind string       `json:"controller_kind"`
+	Controller     string       `json:"controller"`
+	Pod            string       `json:"pod"`
+	Node           string       `json:"node"`
+	Resource       string       `json:"resource"`
+	Usage          float64      `json:"usage"`
+	UsageUnit      string       `json:"usage_unit"`
+	PricingPeriod  string       `json:"pricing_period"`
+	Cost           money.Amount `json:"cost"`
+	Currency       string       `json:"currency"`
+}
+
 type HealthStatus struct {
-	Status    string            `json:"status"`
-	Timestamp time.Time         `json:"timestamp"`
-	Checks    map[string]string `json:"checks"`
+	Status    string                 `json:"status"`
+	Timestamp time.Time              `json:"timestamp"`
+	Checks    map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type CheckResult struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checked_at"`
 }
 
 type ErrorResponse struct {
@@ -124,3 +332,133 @@ type SamplePair struct {
 	Timestamp time.Time `json:"timestamp"`
 	Value     float64   `json:"value"`
 }
+
+// ReconciliationReport compares KuBudget's list-price estimates with the
+// costs in imported cloud billing files. StorageFactors holds the factor
+// applied to pod storage costs in each cluster.
+type ReconciliationReport struct {
+	GeneratedAt    time.Time            `json:"generated_at"`
+	Currency       string               `json:"currency"`
+	Files          []string             `json:"files"`
+	LineItems      int                  `json:"line_items"`
+	BilledCost     float64              `json:"billed_cost"`
+	MatchedCost    float64              `json:"matched_cost"`
+	UnmatchedCost  float64              `json:"unmatched_cost"`
+	Resources      []ResourceAdjustment `json:"resources"`
+	StorageFactors map[string]float64   `json:"storage_factors"`
+	Errors         []string             `json:"errors,omitempty"`
+}
+
+// ResourceAdjustment is the reconciliation of one node or volume. Factor is
+// BilledCost divided by EstimatedCost, the list-price cost of the resource
+// over the BilledHours its line items cover.
+type ResourceAdjustment struct {
+	Cluster       string  `json:"cluster"`
+	Kind          string  `json:"kind"`
+	Name          string  `json:"name"`
+	ResourceID    string  `json:"resource_id"`
+	BilledHours   float64 `json:"billed_hours"`
+	EstimatedCost float64 `json:"estimated_cost"`
+	BilledCost    float64 `json:"billed_cost"`
+	Factor        float64 `json:"factor"`
+}
+
+// DiscountReport is the effective hourly rate of every node after spot
+// discounts, reserved instances and savings plans, next to its list rate.
+type DiscountReport struct {
+	GeneratedAt   time.Time          `json:"generated_at"`
+	Currency      string             `json:"currency"`
+	ListCost      float64            `json:"list_cost"`
+	EffectiveCost float64            `json:"effective_cost"`
+	Savings       float64            `json:"savings"`
+	Nodes         []NodeRate         `json:"nodes"`
+	Reservations  []ReservationUsage `json:"reservations"`
+	SavingsPlans  []SavingsPlanUsage `json:"savings_plans"`
+	Errors        []string           `json:"errors,omitempty"`
+}
+
+// NodeRate is the hourly cost of one node. PricingModel is spot, reserved,
+// savings_plan or on_demand; a node only partly covered by a savings plan
+// is savings_plan.
+type NodeRate struct {
+	Cluster       string  `json:"cluster"`
+	Node          string  `json:"node"`
+	InstanceType  string  `json:"instance_type,omitempty"`
+	PricingModel  string  `json:"pricing_model"`
+	ListCost      float64 `json:"list_cost"`
+	EffectiveCost float64 `json:"effective_cost"`
+	Savings       float64 `json:"savings"`
+}
+
+// ReservationUsage is how many of the reserved instances of a type are
+// matched by running on-demand nodes.
+type ReservationUsage struct {
+	InstanceType string  `json:"instance_type"`
+	Count        int     `json:"count"`
+	Used         int     `json:"used"`
+	Discount     float64 `json:"discount"`
+}
+
+// SavingsPlanUsage is the hourly commitment of a savings plan and how much
+// of it running nodes consume. The unused part is paid but not allocated.
+type SavingsPlanUsage struct {
+	Name              string  `json:"name"`
+	CommitmentPerHour float64 `json:"commitment_per_hour"`
+	UsedPerHour       float64 `json:"used_per_hour"`
+	UnusedPerHour     float64 `json:"unused_per_hour"`
+	Discount          float64 `json:"discount"`
+}
+
+// CommitmentRecommendations are reserved instance and savings plan purchase
+// options for each analyzed window of node history.
+type CommitmentRecommendations struct {
+	GeneratedAt time.Time          `json:"generated_at"`
+	Currency    string             `json:"currency"`
+	TermMonths  int                `json:"term_months"`
+	Windows     []CommitmentWindow `json:"windows"`
+	Errors      []string           `json:"errors,omitempty"`
+}
+
+// CommitmentWindow analyzes the hourly on-demand list cost of nodes over
+// the last Days days, net of the reservations and savings plans already
+// configured. Spot nodes are excluded.
+type CommitmentWindow struct {
+	Days                int                         `json:"days"`
+	Start               time.Time                   `json:"start"`
+	End                 time.Time                   `json:"end"`
+	HoursObserved       int                         `json:"hours_observed"`
+	AverageOnDemandCost float64                     `json:"average_on_demand_cost"`
+	ReservedInstances   []ReservationRecommendation `json:"reserved_instances"`
+	SavingsPlans        []CommitmentOption          `json:"savings_plans"`
+}
+
+// ReservationRecommendation is the reserved instance options for one
+// instance family, counted in its most common instance type.
+type ReservationRecommendation struct {
+	Family              string             `json:"family"`
+	InstanceType        string             `json:"instance_type"`
+	InstanceHourlyCost  float64            `json:"instance_hourly_cost"`
+	AverageOnDemandCost float64            `json:"average_on_demand_cost"`
+	Options             []CommitmentOption `json:"options"`
+}
+
+// CommitmentOption is one purchase amount. CommitmentPerHour is what the
+// commitment costs every hour of the term and CoveredPerHour the list-price
+// usage it pays for. Coverage is the share of on-demand cost it would have
+// covered and Utilization the share of it that would have been used; below
+// BreakEvenUtilization it costs more than it saves. BreakEvenMonths is when
+// an upfront payment would be recovered at that utilization, and is omitted
+// when it never is within the term.
+type CommitmentOption struct {
+	Percentile           float64  `json:"percentile"`
+	Instances            int      `json:"instances,omitempty"`
+	CommitmentPerHour    float64  `json:"commitment_per_hour"`
+	CoveredPerHour       float64  `json:"covered_per_hour"`
+	Coverage             float64  `json:"coverage"`
+	Utilization          float64  `json:"utilization"`
+	UnderutilizedHours   float64  `json:"underutilized_hours"`
+	MonthlySavings       float64  `json:"monthly_savings"`
+	BreakEvenUtilization float64  `json:"break_even_utilization"`
+	BreakEvenMonths      *float64 `json:"break_even_months,omitempty"`
+	Risk                 string   `json:"risk"`
+}
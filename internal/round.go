@@ -0,0 +1,241 @@
+package internal
+
+import (
+	"slices"
+
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+)
+
+// The Round methods round every cost of a value for presentation. Totals
+// are recomputed from their rounded parts rather than rounded themselves,
+// so that a total always equals the sum of the line items presented with
+// it; a part of a total that has no field of its own, such as the
+// ephemeral storage in a cluster's total, is rounded as one more line
+// item. Savings are list cost less total cost after rounding. Like with
+// Scale, maps and slices are copied rather than rounded in place.
+
+func (o *CostOverview) Round(r money.Rounding) {
+	o.NamespacesCost = roundNamespaces(o.NamespacesCost, r)
+	o.TotalCost = sumNamespaces(o.NamespacesCost)
+	o.Clusters = slices.Clone(o.Clusters)
+	for i := range o.Clusters {
+		if o.Clusters[i].Error != "" {
+			o.Clusters[i].Round(r)
+			continue
+		}
+		o.Clusters[i].rollup(o.NamespacesCost)
+	}
+}
+
+func (v *FederatedView) Round(r money.Rounding) {
+	v.Namespaces = roundNamespaces(v.Namespaces, r)
+	v.TotalCost = sumNamespaces(v.Namespaces)
+	v.Clusters = slices.Clone(v.Clusters)
+	for i := range v.Clusters {
+		v.Clusters[i].Cost.rollup(v.Namespaces)
+	}
+}
+
+func (c *ClusterCost) Round(r money.Rounding) {
+	rest := unitemized(c.TotalCost, c.CPUCost, c.MemoryCost, c.StorageCost, c.NetworkCost)
+	c.TotalCost = roundSum(r, rest, &c.CPUCost, &c.MemoryCost, &c.StorageCost, &c.NetworkCost)
+	c.ListCost = r.Round(c.ListCost)
+	c.Savings = c.ListCost.Sub(c.TotalCost)
+}
+
+// rollup replaces c's costs with the sums of those of the namespaces in
+// namespaces that belong to c's cluster.
+func (c *ClusterCost) rollup(namespaces []NamespaceCost) {
+	c.CPUCost, c.MemoryCost, c.StorageCost, c.NetworkCost = money.Zero, money.Zero, money.Zero, money.Zero
+	c.TotalCost, c.ListCost, c.Savings = money.Zero, money.Zero, money.Zero
+	for _, ns := range namespaces {
+		if ns.Cluster != c.Cluster {
+			continue
+		}
+		c.CPUCost = c.CPUCost.Add(ns.CPUCost)
+		c.MemoryCost = c.MemoryCost.Add(ns.MemoryCost)
+		c.StorageCost = c.StorageCost.Add(ns.StorageCost)
+		c.NetworkCost = c.NetworkCost.Add(ns.NetworkCost)
+		c.TotalCost = c.TotalCost.Add(ns.TotalCost)
+		c.ListCost = c.ListCost.Add(ns.ListCost)
+		c.Savings = c.Savings.Add(ns.Savings)
+	}
+}
+
+func (a *AggregatedCost) Round(r money.Rounding) {
+	rest := unitemized(a.TotalCost, a.CPUCost, a.MemoryCost, a.StorageCost, a.NetworkCost, a.ExternalCost)
+	a.NetworkEgress = roundMap(r, a.NetworkEgress, &a.NetworkCost)
+	a.TotalCost = roundSum(r, rest, &a.CPUCost, &a.MemoryCost, &a.StorageCost, &a.NetworkCost, &a.ExternalCost)
+}
+
+// Round rounds the namespace's pods and sums them into its costs. A
+// namespace listed without its pods is rounded like a pod.
+func (n *NamespaceCost) Round(r money.Rounding) {
+	if len(n.Pods) == 0 {
+		rest := unitemized(n.TotalCost, n.CPUCost, n.MemoryCost, n.StorageCost, n.NetworkCost, n.EphemeralStorageCost, n.ExtendedCost)
+		n.NetworkEgress = roundMap(r, n.NetworkEgress, &n.NetworkCost)
+		n.ExtendedResources = roundMap(r, n.ExtendedResources, &n.ExtendedCost)
+		n.TotalCost = roundSum(r, rest, &n.CPUCost, &n.MemoryCost, &n.StorageCost, &n.NetworkCost, &n.EphemeralStorageCost, &n.ExtendedCost)
+		n.ListCost = r.Round(n.ListCost)
+		n.Savings = n.ListCost.Sub(n.TotalCost)
+		return
+	}
+
+	n.Pods = slices.Clone(n.Pods)
+	for i := range n.Pods {
+		n.Pods[i].Round(r)
+	}
+	n.SetPods(n.Pods)
+}
+
+// SetPods replaces the namespace's pods with pods and its costs with their
+// sums. The namespace's breakdown maps are replaced, never written to, so
+// they may be shared with other copies of it. PodCount is left as is.
+func (n *NamespaceCost) SetPods(pods []PodCost) {
+	var sum NamespaceCost
+	for i := range pods {
+		sum.addPod(&pods[i])
+	}
+	n.Pods = pods
+	n.CPUCost, n.MemoryCost, n.StorageCost = sum.CPUCost, sum.MemoryCost, sum.StorageCost
+	n.NetworkCost, n.NetworkEgress = sum.NetworkCost, sum.NetworkEgress
+	n.EphemeralStorageCost = sum.EphemeralStorageCost
+	n.ExtendedCost, n.ExtendedResources = sum.ExtendedCost, sum.ExtendedResources
+	n.TotalCost, n.ListCost, n.Savings = sum.TotalCost, sum.ListCost, sum.Savings
+}
+
+func (n *NamespaceCost) addPod(pod *PodCost) {
+	n.CPUCost = n.CPUCost.Add(pod.CPUCost)
+	n.MemoryCost = n.MemoryCost.Add(pod.MemoryCost)
+	n.StorageCost = n.StorageCost.Add(pod.StorageCost)
+	n.NetworkCost = n.NetworkCost.Add(pod.NetworkCost)
+	n.NetworkEgress = addMap(n.NetworkEgress, pod.NetworkEgress)
+	n.EphemeralStorageCost = n.EphemeralStorageCost.Add(pod.EphemeralStorageCost)
+	n.ExtendedCost = n.ExtendedCost.Add(pod.ExtendedCost)
+	n.ExtendedResources = addMap(n.ExtendedResources, pod.ExtendedResources)
+	n.TotalCost = n.TotalCost.Add(pod.TotalCost)
+	n.ListCost = n.ListCost.Add(pod.ListCost)
+	n.Savings = n.Savings.Add(pod.Savings)
+}
+
+func (p *PodCost) Round(r money.Rounding) {
+	rest := unitemized(p.TotalCost, p.CPUCost, p.MemoryCost, p.StorageCost, p.NetworkCost, p.EphemeralStorageCost, p.ExtendedCost)
+	p.NetworkEgress = roundMap(r, p.NetworkEgress, &p.NetworkCost)
+	p.ExtendedResources = roundMap(r, p.ExtendedResources, &p.ExtendedCost)
+	p.TotalCost = roundSum(r, rest, &p.CPUCost, &p.MemoryCost, &p.StorageCost, &p.NetworkCost, &p.EphemeralStorageCost, &p.ExtendedCost)
+	p.ListCost = r.Round(p.ListCost)
+	p.Savings = p.ListCost.Sub(p.TotalCost)
+}
+
+// Round rounds the node's costs. Idle costs are a share of its capacity,
+// not line items of its total, and are rounded on their own.
+func (n *NodeCost) Round(r money.Rounding) {
+	rest := unitemized(n.TotalCost, n.CPUCost, n.MemoryCost, n.StorageCost, n.BootDiskCost, n.ExtendedCost)
+	n.ExtendedResources = roundMap(r, n.ExtendedResources, &n.ExtendedCost)
+	n.TotalCost = roundSum(r, rest, &n.CPUCost, &n.MemoryCost, &n.StorageCost, &n.BootDiskCost, &n.ExtendedCost)
+	n.ExtendedIdleCost = r.Round(n.ExtendedIdleCost)
+	n.GPUIdleCost = r.Round(n.GPUIdleCost)
+	n.ListCost = r.Round(n.ListCost)
+	n.Savings = n.ListCost.Sub(n.TotalCost)
+}
+
+func (h *CostHistory) Round(r money.Rounding) {
+	h.Data = slices.Clone(h.Data)
+	for i := range h.Data {
+		h.Data[i].Round(r)
+	}
+	h.PricingPeriods = slices.Clone(h.PricingPeriods)
+	for i := range h.PricingPeriods {
+		h.PricingPeriods[i].Cost = r.Round(h.PricingPeriods[i].Cost)
+	}
+}
+
+func (p *CostHistoryPoint) Round(r money.Rounding) {
+	rest := unitemized(p.TotalCost, p.CPUCost, p.MemoryCost, p.StorageCost, p.NetworkCost)
+	p.TotalCost = roundSum(r, rest, &p.CPUCost, &p.MemoryCost, &p.StorageCost, &p.NetworkCost)
+}
+
+// Round rounds both histories and totals each from its rounded pricing
+// periods.
+func (c *PriceComparison) Round(r money.Rounding) {
+	actual, repriced := *c.Actual, *c.Repriced
+	actual.Round(r)
+	repriced.Round(r)
+	c.Actual, c.Repriced = &actual, &repriced
+	c.ActualCost, c.RepricedCost = sumPeriods(actual.PricingPeriods), sumPeriods(repriced.PricingPeriods)
+	c.Difference = c.RepricedCost.Sub(c.ActualCost)
+}
+
+func (a *AllocationRow) Round(r money.Rounding) {
+	a.Cost = r.Round(a.Cost)
+}
+
+func roundNamespaces(namespaces []NamespaceCost, r money.Rounding) []NamespaceCost {
+	rounded := slices.Clone(namespaces)
+	for i := range rounded {
+		rounded[i].Round(r)
+	}
+	return rounded
+}
+
+func sumNamespaces(namespaces []NamespaceCost) CostBreakdown {
+	var total CostBreakdown
+	for _, ns := range namespaces {
+		total.CPUCost = total.CPUCost.Add(ns.CPUCost)
+		total.MemoryCost = total.MemoryCost.Add(ns.MemoryCost)
+		total.StorageCost = total.StorageCost.Add(ns.StorageCost)
+		total.NetworkCost = total.NetworkCost.Add(ns.NetworkCost)
+		total.TotalCost = total.TotalCost.Add(ns.TotalCost)
+	}
+	return total
+}
+
+func sumPeriods(periods []PeriodCost) money.Amount {
+	var total money.Amount
+	for _, period := range periods {
+		total = total.Add(period.Cost)
+	}
+	return total
+}
+
+// unitemized returns the part of total that is in none of parts.
+func unitemized(total money.Amount, parts ...money.Amount) money.Amount {
+	return total.Sub(money.Sum(parts...))
+}
+
+// roundSum rounds parts in place and returns their sum plus rest, rounded.
+func roundSum(r money.Rounding, rest money.Amount, parts ...*money.Amount) money.Amount {
+	total := r.Round(rest)
+	for _, part := range parts {
+		*part = r.Round(*part)
+		total = total.Add(*part)
+	}
+	return total
+}
+
+// roundMap rounds a breakdown of *total into a new map, and *total to the
+// sum of the rounded breakdown.
+func roundMap(r money.Rounding, costs map[string]money.Amount, total *money.Amount) map[string]money.Amount {
+	if len(costs) == 0 {
+		return costs
+	}
+	rest := *total
+	for _, cost := range costs {
+		rest = rest.Sub(cost)
+	}
+	rounded, sum := r.RoundMap(costs)
+	*total = sum.Add(r.Round(rest))
+	return rounded
+}
+
+// addMap adds costs into sum, creating it if needed, and returns it. sum
+// must not be shared.
+func addMap(sum, costs map[string]money.Amount) map[string]money.Amount {
+	for key, cost := range costs {
+		if sum == nil {
+			sum = make(map[string]money.Amount, len(costs))
+		}
+		sum[key] = sum[key].Add(cost)
+	}
+	return sum
+}
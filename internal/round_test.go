@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+)
+
+// TestNamespaceCostRound checks that after rounding every total equals the
+// sum of the parts presented with it, for a namespace with and without
+// its pods, under every rounding mode.
+func TestNamespaceCostRound(t *testing.T) {
+	pods := []PodCost{
+		testPod("api-1", "0.3333", "0.1667", "0.0049", map[string]string{"internet": "0.0125", "zone": "0.0031"}, map[string]string{"nvidia.com/gpu": "1.2345"}),
+		testPod("api-2", "0.3333", "0.1667", "0.0051", map[string]string{"internet": "0.0125"}, nil),
+		testPod("worker", "1.0005", "0.0015", "0", nil, map[string]string{"nvidia.com/gpu": "0.0055"}),
+	}
+	tests := []struct {
+		name string
+		ns   NamespaceCost
+	}{
+		{"with pods", NamespaceCost{Namespace: "default", Pods: pods}},
+		{"without pods", sumPods(pods)},
+	}
+
+	for _, tt := range tests {
+		for _, mode := range money.Modes {
+			r := money.Rounding{Places: 2, Mode: mode}
+			ns := tt.ns
+			ns.Round(r)
+
+			for _, pod := range ns.Pods {
+				checkRounded(t, tt.name+"/"+mode+"/"+pod.Name, r, pod.TotalCost, pod.ListCost, pod.Savings,
+					[]money.Amount{pod.CPUCost, pod.MemoryCost, pod.StorageCost, pod.NetworkCost, pod.EphemeralStorageCost, pod.ExtendedCost},
+					pod.NetworkCost, pod.NetworkEgress, pod.ExtendedCost, pod.ExtendedResources)
+			}
+			checkRounded(t, tt.name+"/"+mode, r, ns.TotalCost, ns.ListCost, ns.Savings,
+				[]money.Amount{ns.CPUCost, ns.MemoryCost, ns.StorageCost, ns.NetworkCost, ns.EphemeralStorageCost, ns.ExtendedCost},
+				ns.NetworkCost, ns.NetworkEgress, ns.ExtendedCost, ns.ExtendedResources)
+
+			if len(ns.Pods) > 0 {
+				sum := sumPods(ns.Pods)
+				if !ns.TotalCost.Equal(sum.TotalCost) || !ns.CPUCost.Equal(sum.CPUCost) || !ns.ListCost.Equal(sum.ListCost) {
+					t.Errorf("%s/%s: namespace total %s, cpu %s, list %s; pods add up to %s, %s, %s", tt.name, mode,
+						ns.TotalCost, ns.CPUCost, ns.ListCost, sum.TotalCost, sum.CPUCost, sum.ListCost)
+				}
+				if !pods[0].CPUCost.Equal(amount("0.3333")) {
+					t.Errorf("%s/%s: Round changed the pods it was given", tt.name, mode)
+				}
+			}
+		}
+	}
+}
+
+// checkRounded checks that every amount is rounded, that total is the sum
+// of parts, that each breakdown adds up to its cost and that savings are
+// list cost less total.
+func checkRounded(t *testing.T, name string, r money.Rounding, total, list, savings money.Amount, parts []money.Amount, network money.Amount, egress map[string]money.Amount, extended money.Amount, resources map[string]money.Amount) {
+	t.Helper()
+	amounts := append([]money.Amount{total, list, savings}, parts...)
+	for _, a := range egress {
+		amounts = append(amounts, a)
+	}
+	for _, a := range resources {
+		amounts = append(amounts, a)
+	}
+	for _, a := range amounts {
+		if !r.Round(a).Equal(a) {
+			t.Errorf("%s: %s is not rounded", name, a)
+		}
+	}
+
+	if sum := money.Sum(parts...); !total.Equal(sum) {
+		t.Errorf("%s: total %s, parts add up to %s", name, total, sum)
+	}
+	if len(egress) > 0 && !network.Equal(sumMap(egress)) {
+		t.Errorf("%s: network %s, egress adds up to %s", name, network, sumMap(egress))
+	}
+	if len(resources) > 0 && !extended.Equal(sumMap(resources)) {
+		t.Errorf("%s: extended %s, resources add up to %s", name, extended, sumMap(resources))
+	}
+	if want := list.Sub(total); !savings.Equal(want) {
+		t.Errorf("%s: savings %s, want %s", name, savings, want)
+	}
+}
+
+func testPod(name, cpu, memory, ephemeral string, egress, resources map[string]string) PodCost {
+	pod := PodCost{
+		Name:                 name,
+		Namespace:            "default",
+		CPUCost:              amount(cpu),
+		MemoryCost:           amount(memory),
+		EphemeralStorageCost: amount(ephemeral),
+	}
+	for class, cost := range egress {
+		pod.NetworkEgress = addMap(pod.NetworkEgress, map[string]money.Amount{class: amount(cost)})
+	}
+	pod.NetworkCost = sumMap(pod.NetworkEgress)
+	for resource, cost := range resources {
+		pod.ExtendedResources = addMap(pod.ExtendedResources, map[string]money.Amount{resource: amount(cost)})
+	}
+	pod.ExtendedCost = sumMap(pod.ExtendedResources)
+	pod.TotalCost = money.Sum(pod.CPUCost, pod.MemoryCost, pod.NetworkCost, pod.EphemeralStorageCost, pod.ExtendedCost)
+	pod.ListCost = pod.TotalCost.Mul(1.25)
+	pod.Savings = pod.ListCost.Sub(pod.TotalCost)
+	return pod
+}
+
+func sumPods(pods []PodCost) NamespaceCost {
+	ns := NamespaceCost{Namespace: "default", PodCount: len(pods)}
+	ns.SetPods(pods)
+	return ns
+}
+
+func sumMap(costs map[string]money.Amount) money.Amount {
+	var sum money.Amount
+	for _, cost := range costs {
+		sum = sum.Add(cost)
+	}
+	return sum
+}
+
+// amount parses a test amount.
+func amount(s string) money.Amount {
+	a, err := money.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
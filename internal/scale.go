@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"slices"
+
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+)
+
+// The Scale methods multiply every cost of a value by factor, for example
+// to convert it into another currency. Maps and slices are copied rather
+// than scaled in place, since they may be shared with stored data.
+
+func (b *CostBreakdown) Scale(factor float64) {
+	b.CPUCost = b.CPUCost.Mul(factor)
+	b.MemoryCost = b.MemoryCost.Mul(factor)
+	b.StorageCost = b.StorageCost.Mul(factor)
+	b.NetworkCost = b.NetworkCost.Mul(factor)
+	b.TotalCost = b.TotalCost.Mul(factor)
+}
+
+func (o *CostOverview) Scale(factor float64) {
+	o.TotalCost.Scale(factor)
+	o.NamespacesCost = slices.Clone(o.NamespacesCost)
+	for i := range o.NamespacesCost {
+		o.NamespacesCost[i].Scale(factor)
+	}
+	o.Clusters = slices.Clone(o.Clusters)
+	for i := range o.Clusters {
+		o.Clusters[i].Scale(factor)
+	}
+}
+
+func (c *ClusterCost) Scale(factor float64) {
+	c.CPUCost = c.CPUCost.Mul(factor)
+	c.MemoryCost = c.MemoryCost.Mul(factor)
+	c.StorageCost = c.StorageCost.Mul(factor)
+	c.NetworkCost = c.NetworkCost.Mul(factor)
+	c.TotalCost = c.TotalCost.Mul(factor)
+	c.ListCost = c.ListCost.Mul(factor)
+	c.Savings = c.Savings.Mul(factor)
+}
+
+func (a *AggregatedCost) Scale(factor float64) {
+	a.CPUCost = a.CPUCost.Mul(factor)
+	a.MemoryCost = a.MemoryCost.Mul(factor)
+	a.StorageCost = a.StorageCost.Mul(factor)
+	a.NetworkCost = a.NetworkCost.Mul(factor)
+	a.NetworkEgress = scaleMap(a.NetworkEgress, factor)
+	a.ExternalCost = a.ExternalCost.Mul(factor)
+	a.TotalCost = a.TotalCost.Mul(factor)
+}
+
+func (n *NamespaceCost) Scale(factor float64) {
+	n.CPUCost = n.CPUCost.Mul(factor)
+	n.MemoryCost = n.MemoryCost.Mul(factor)
+	n.StorageCost = n.StorageCost.Mul(factor)
+	n.NetworkCost = n.NetworkCost.Mul(factor)
+	n.NetworkEgress = scaleMap(n.NetworkEgress, factor)
+	n.EphemeralStorageCost = n.EphemeralStorageCost.Mul(factor)
+	n.ExtendedCost = n.ExtendedCost.Mul(factor)
+	n.ExtendedResources = scaleMap(n.ExtendedResources, factor)
+	n.TotalCost = n.TotalCost.Mul(factor)
+	n.ListCost = n.ListCost.Mul(factor)
+	n.Savings = n.Savings.Mul(factor)
+	n.Pods = slices.Clone(n.Pods)
+	for i := range n.Pods {
+		n.Pods[i].Scale(factor)
+	}
+}
+
+func (p *PodCost) Scale(factor float64) {
+	p.CPUCost = p.CPUCost.Mul(factor)
+	p.MemoryCost = p.MemoryCost.Mul(factor)
+	p.StorageCost = p.StorageCost.Mul(factor)
+	p.NetworkCost = p.NetworkCost.Mul(factor)
+	p.NetworkEgress = scaleMap(p.NetworkEgress, factor)
+	p.EphemeralStorageCost = p.EphemeralStorageCost.Mul(factor)
+	p.ExtendedCost = p.ExtendedCost.Mul(factor)
+	p.ExtendedResources = scaleMap(p.ExtendedResources, factor)
+	p.TotalCost = p.TotalCost.Mul(factor)
+	p.ListCost = p.ListCost.Mul(factor)
+	p.Savings = p.Savings.Mul(factor)
+}
+
+func (n *NodeCost) Scale(factor float64) {
+	n.CPUCost = n.CPUCost.Mul(factor)
+	n.MemoryCost = n.MemoryCost.Mul(factor)
+	n.StorageCost = n.StorageCost.Mul(factor)
+	n.BootDiskCost = n.BootDiskCost.Mul(factor)
+	n.ExtendedCost = n.ExtendedCost.Mul(factor)
+	n.ExtendedResources = scaleMap(n.ExtendedResources, factor)
+	n.ExtendedIdleCost = n.ExtendedIdleCost.Mul(factor)
+	n.GPUIdleCost = n.GPUIdleCost.Mul(factor)
+	n.TotalCost = n.TotalCost.Mul(factor)
+	n.ListCost = n.ListCost.Mul(factor)
+	n.Savings = n.Savings.Mul(factor)
+}
+
+func (p *CostHistoryPoint) Scale(factor float64) {
+	p.CPUCost = p.CPUCost.Mul(factor)
+	p.MemoryCost = p.MemoryCost.Mul(factor)
+	p.StorageCost = p.StorageCost.Mul(factor)
+	p.NetworkCost = p.NetworkCost.Mul(factor)
+	p.TotalCost = p.TotalCost.Mul(factor)
+}
+
+func scaleMap(costs map[string]money.Amount, factor float64) map[string]money.Amount {
+	if costs == nil {
+		return nil
+	}
+	scaled := make(map[string]money.Amount, len(costs))
+	for key, cost := range costs {
+		scaled[key] = cost.Mul(factor)
+	}
+	return scaled
+}
@@ -0,0 +1,273 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	// Pricing timezones must resolve in minimal container images without a
+	// system zoneinfo database.
+	_ "time/tzdata"
+)
+
+// PeakPeriod names the time no pricing schedule covers, which is priced at
+// the base rates.
+const PeakPeriod = "peak"
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// PricingSegment is a stretch of time priced under one period.
+type PricingSegment struct {
+	Start      time.Time
+	End        time.Time
+	Period     string
+	Multiplier float64
+}
+
+// Hours is the length of the segment in hours.
+func (s PricingSegment) Hours() float64 {
+	return s.End.Sub(s.Start).Hours()
+}
+
+// schedule is a PricingSchedule with its ranges parsed into minutes of the
+// day.
+type schedule struct {
+	name       string
+	days       [7]bool
+	ranges     [][2]int
+	multiplier float64
+}
+
+func (s schedule) matches(local time.Time) bool {
+	if !s.days[local.Weekday()] {
+		return false
+	}
+	if len(s.ranges) == 0 {
+		return true
+	}
+	minute := local.Hour()*60 + local.Minute()
+	for _, r := range s.ranges {
+		if r[0] < r[1] && minute >= r[0] && minute < r[1] {
+			return true
+		}
+		if r[0] > r[1] && (minute >= r[0] || minute < r[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// calendar is the parsed form of a pricing's timezone and schedules.
+type calendar struct {
+	location  *time.Location
+	schedules []schedule
+	// boundaries are the minutes of the day at which some schedule may
+	// start or stop applying, in ascending order and starting at 0.
+	boundaries []int
+}
+
+// ParseSchedules parses p's timezone and schedules and keeps the result,
+// so that pricing a point in time does not parse them again. Validate
+// calls it for every pricing in the configuration.
+func (p *PricingConfig) ParseSchedules() error {
+	c, err := p.parseCalendar()
+	if err != nil {
+		return err
+	}
+	p.calendar = c
+	return nil
+}
+
+// schedule returns p's parsed calendar, parsing it now if p was not
+// parsed on load.
+func (p PricingConfig) schedule() (*calendar, error) {
+	if p.calendar != nil {
+		return p.calendar, nil
+	}
+	return p.parseCalendar()
+}
+
+func (p PricingConfig) parseCalendar() (*calendar, error) {
+	location := time.UTC
+	if p.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(p.Timezone); err != nil {
+			return nil, fmt.Errorf("timezone: %v", err)
+		}
+	}
+
+	c := &calendar{location: location}
+	minutes := map[int]bool{0: true}
+	for i, ps := range p.Schedules {
+		s := schedule{name: ps.Name, multiplier: ps.Multiplier}
+		for _, day := range ps.Weekdays {
+			weekday, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return nil, fmt.Errorf("schedules[%d].weekdays: %q must be one of mon, tue, wed, thu, fri, sat or sun", i, day)
+			}
+			s.days[weekday] = true
+		}
+		if len(ps.Weekdays) == 0 {
+			s.days = [7]bool{true, true, true, true, true, true, true}
+		}
+		for _, hours := range ps.Hours {
+			r, err := parseHourRange(hours)
+			if err != nil {
+				return nil, fmt.Errorf("schedules[%d].hours: %v", i, err)
+			}
+			s.ranges = append(s.ranges, r)
+			minutes[r[0]], minutes[r[1]] = true, true
+		}
+		c.schedules = append(c.schedules, s)
+	}
+	for minute := 0; minute < 24*60; minute++ {
+		if minutes[minute] {
+			c.boundaries = append(c.boundaries, minute)
+		}
+	}
+	return c, nil
+}
+
+// parseHourRange parses a range such as "22:00-06:00" into minutes of the
+// day. A range whose end is before its start wraps past midnight, and
+// "24:00" ends a range at midnight.
+func parseHourRange(value string) ([2]int, error) {
+	from, to, ok := strings.Cut(value, "-")
+	if !ok {
+		return [2]int{}, fmt.Errorf("%q must be a range like 22:00-06:00", value)
+	}
+	start, err := parseClock(strings.TrimSpace(from))
+	if err != nil {
+		return [2]int{}, fmt.Errorf("%q: %v", value, err)
+	}
+	end, err := parseClock(strings.TrimSpace(to))
+	if err != nil {
+		return [2]int{}, fmt.Errorf("%q: %v", value, err)
+	}
+	if start == 24*60 {
+		return [2]int{}, fmt.Errorf("%q: a range cannot start at 24:00", value)
+	}
+	if start == end%(24*60) {
+		return [2]int{}, fmt.Errorf("%q: start and end must differ", value)
+	}
+	return [2]int{start, end % (24 * 60)}, nil
+}
+
+func parseClock(value string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(value, "%d:%d", &hour, &minute); err != nil || len(value) != 5 {
+		return 0, fmt.Errorf("%q is not a time like 06:00", value)
+	}
+	if hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("%q is not a time of day", value)
+	}
+	return hour*60 + minute, nil
+}
+
+// at returns the period in effect at t and its multiplier. The first
+// matching schedule wins; time no schedule covers is peak.
+func (c *calendar) at(t time.Time) (string, float64) {
+	local := t.In(c.location)
+	for _, s := range c.schedules {
+		if s.matches(local) {
+			return s.name, s.multiplier
+		}
+	}
+	return PeakPeriod, 1
+}
+
+// next returns the first boundary after t at which the period may change.
+// Boundaries are taken at t's UTC offset, which holds until the next clock
+// change; a clock change is a boundary too, since it moves local time past
+// boundaries or back before them.
+func (c *calendar) next(t time.Time) time.Time {
+	local := t.In(c.location)
+	name, offset := local.Zone()
+	zone := time.FixedZone(name, offset)
+	year, month, day := local.Date()
+	minute := local.Hour()*60 + local.Minute()
+
+	next := time.Date(year, month, day+1, 0, 0, 0, 0, zone)
+	for _, boundary := range c.boundaries {
+		if boundary > minute {
+			next = time.Date(year, month, day, boundary/60, boundary%60, 0, 0, zone)
+			break
+		}
+	}
+	if _, change := local.ZoneBounds(); !change.IsZero() && change.Before(next) {
+		next = change
+	}
+	return next.In(c.location)
+}
+
+// PricingPeriodAt returns the pricing period in effect at t and the
+// multiplier it applies to every rate. The configuration is validated on
+// load, so a pricing that fails to parse is treated as unscheduled.
+func (p PricingConfig) PricingPeriodAt(t time.Time) (string, float64) {
+	c, err := p.schedule()
+	if err != nil {
+		return PeakPeriod, 1
+	}
+	return c.at(t)
+}
+
+// PricingSegments splits [start, end) into consecutive segments priced
+// under one period each. Without schedules the whole range is one peak
+// segment.
+func (p PricingConfig) PricingSegments(start, end time.Time) []PricingSegment {
+	if !end.After(start) {
+		return nil
+	}
+	c, err := p.schedule()
+	if err != nil || len(c.schedules) == 0 {
+		return []PricingSegment{{Start: start, End: end, Period: PeakPeriod, Multiplier: 1}}
+	}
+
+	var segments []PricingSegment
+	for t := start; t.Before(end); {
+		period, multiplier := c.at(t)
+		next := c.next(t)
+		if next.After(end) {
+			next = end
+		}
+		if n := len(segments); n > 0 && segments[n-1].Period == period {
+			segments[n-1].End = next
+		} else {
+			segments = append(segments, PricingSegment{Start: t, End: next, Period: period, Multiplier: multiplier})
+		}
+		t = next
+	}
+	return segments
+}
+
+// PricingPeriod is the time a range spends in one pricing period.
+type PricingPeriod struct {
+	Name       string
+	Multiplier float64
+	Hours      float64
+}
+
+// PricingPeriods sums the hours of [start, end) by pricing period, in the
+// order the periods first occur.
+func (p PricingConfig) PricingPeriods(start, end time.Time) []PricingPeriod {
+	var periods []PricingPeriod
+	index := make(map[string]int)
+	for _, segment := range p.PricingSegments(start, end) {
+		i, ok := index[segment.Period]
+		if !ok {
+			i = len(periods)
+			index[segment.Period] = i
+			periods = append(periods, PricingPeriod{Name: segment.Period, Multiplier: segment.Multiplier})
+		}
+		periods[i].Hours += segment.Hours()
+	}
+	return periods
+}
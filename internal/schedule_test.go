@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPricingSegments(t *testing.T) {
+	utc := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.March, day, hour, minute, 0, 0, time.UTC)
+	}
+	november := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.November, day, hour, minute, 0, 0, time.UTC)
+	}
+	night := PricingSchedule{Name: "night", Hours: []string{"22:00-06:00"}, Multiplier: 0.5}
+
+	tests := []struct {
+		name       string
+		pricing    PricingConfig
+		start, end time.Time
+		want       []PricingSegment
+	}{
+		{
+			name:    "wraps midnight",
+			pricing: PricingConfig{Schedules: []PricingSchedule{night}},
+			start:   utc(2, 20, 0), end: utc(3, 8, 0),
+			want: []PricingSegment{
+				{utc(2, 20, 0), utc(2, 22, 0), PeakPeriod, 1},
+				{utc(2, 22, 0), utc(3, 6, 0), "night", 0.5},
+				{utc(3, 6, 0), utc(3, 8, 0), PeakPeriod, 1},
+			},
+		},
+		{
+			name:    "starts inside a wrapped range",
+			pricing: PricingConfig{Schedules: []PricingSchedule{night}},
+			start:   utc(3, 1, 30), end: utc(3, 23, 0),
+			want: []PricingSegment{
+				{utc(3, 1, 30), utc(3, 6, 0), "night", 0.5},
+				{utc(3, 6, 0), utc(3, 22, 0), PeakPeriod, 1},
+				{utc(3, 22, 0), utc(3, 23, 0), "night", 0.5},
+			},
+		},
+		{
+			name: "ends at 24:00",
+			pricing: PricingConfig{Schedules: []PricingSchedule{
+				{Name: "evening", Hours: []string{"18:00-24:00"}, Multiplier: 0.8},
+			}},
+			start: utc(2, 12, 0), end: utc(3, 12, 0),
+			want: []PricingSegment{
+				{utc(2, 12, 0), utc(2, 18, 0), PeakPeriod, 1},
+				{utc(2, 18, 0), utc(3, 0, 0), "evening", 0.8},
+				{utc(3, 0, 0), utc(3, 12, 0), PeakPeriod, 1},
+			},
+		},
+		{
+			// Weekdays and hours are matched independently, so a Friday
+			// night range stops at midnight.
+			name: "wrapped range on one weekday",
+			pricing: PricingConfig{Schedules: []PricingSchedule{
+				{Name: "friday-night", Weekdays: []string{"fri"}, Hours: []string{"22:00-06:00"}, Multiplier: 0.5},
+			}},
+			start: utc(6, 20, 0), end: utc(7, 8, 0),
+			want: []PricingSegment{
+				{utc(6, 20, 0), utc(6, 22, 0), PeakPeriod, 1},
+				{utc(6, 22, 0), utc(7, 0, 0), "friday-night", 0.5},
+				{utc(7, 0, 0), utc(7, 8, 0), PeakPeriod, 1},
+			},
+		},
+		{
+			// New York springs forward at 02:00 EST on March 8th: the
+			// night from 22:00 EST to 06:00 EDT is 7 hours long.
+			name:    "spring forward",
+			pricing: PricingConfig{Timezone: "America/New_York", Schedules: []PricingSchedule{night}},
+			start:   utc(7, 17, 0), end: utc(8, 16, 0),
+			want: []PricingSegment{
+				{utc(7, 17, 0), utc(8, 3, 0), PeakPeriod, 1},
+				{utc(8, 3, 0), utc(8, 10, 0), "night", 0.5},
+				{utc(8, 10, 0), utc(8, 16, 0), PeakPeriod, 1},
+			},
+		},
+		{
+			// 02:30 does not exist on March 8th in New York; the range
+			// applies from when the clock jumps to 03:00 EDT.
+			name: "range starting in the skipped hour",
+			pricing: PricingConfig{Timezone: "America/New_York", Schedules: []PricingSchedule{
+				{Name: "batch", Hours: []string{"02:30-04:00"}, Multiplier: 0.7},
+			}},
+			start: utc(8, 5, 0), end: utc(8, 9, 0),
+			want: []PricingSegment{
+				{utc(8, 5, 0), utc(8, 7, 0), PeakPeriod, 1},
+				{utc(8, 7, 0), utc(8, 8, 0), "batch", 0.7},
+				{utc(8, 8, 0), utc(8, 9, 0), PeakPeriod, 1},
+			},
+		},
+		{
+			// New York falls back at 02:00 EDT on November 1st: the night
+			// from 22:00 EDT to 06:00 EST is 9 hours long.
+			name:    "fall back",
+			pricing: PricingConfig{Timezone: "America/New_York", Schedules: []PricingSchedule{night}},
+			start:   november(0, 16, 0), end: november(1, 17, 0),
+			want: []PricingSegment{
+				{november(0, 16, 0), november(1, 2, 0), PeakPeriod, 1},
+				{november(1, 2, 0), november(1, 11, 0), "night", 0.5},
+				{november(1, 11, 0), november(1, 17, 0), PeakPeriod, 1},
+			},
+		},
+		{
+			// 01:30 happens twice on November 1st in New York; the range
+			// applies from both, and not in the half hour between them.
+			name: "range starting in the repeated hour",
+			pricing: PricingConfig{Timezone: "America/New_York", Schedules: []PricingSchedule{
+				{Name: "batch", Hours: []string{"01:30-05:00"}, Multiplier: 0.7},
+			}},
+			start: november(1, 5, 0), end: november(1, 11, 0),
+			want: []PricingSegment{
+				{november(1, 5, 0), november(1, 5, 30), PeakPeriod, 1},
+				{november(1, 5, 30), november(1, 6, 0), "batch", 0.7},
+				{november(1, 6, 0), november(1, 6, 30), PeakPeriod, 1},
+				{november(1, 6, 30), november(1, 10, 0), "batch", 0.7},
+				{november(1, 10, 0), november(1, 11, 0), PeakPeriod, 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		// Pricings are parsed on load, but must also work unparsed, such
+		// as when built in code.
+		parsed := tt.pricing
+		if err := parsed.ParseSchedules(); err != nil {
+			t.Fatalf("%s: ParseSchedules: %v", tt.name, err)
+		}
+		for _, pricing := range []PricingConfig{parsed, tt.pricing} {
+			got := pricing.PricingSegments(tt.start, tt.end)
+			if !sameSegments(got, tt.want) {
+				t.Errorf("%s (parsed %t):\n got %v\nwant %v", tt.name, pricing.calendar != nil, got, tt.want)
+			}
+			for _, segment := range got {
+				if period, _ := pricing.PricingPeriodAt(segment.Start); period != segment.Period {
+					t.Errorf("%s: PricingPeriodAt(%v) = %s, segment is %s", tt.name, segment.Start, period, segment.Period)
+				}
+			}
+		}
+	}
+}
+
+// sameSegments compares segments by instant rather than by location.
+func sameSegments(a, b []PricingSegment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Start.Equal(b[i].Start) || !a[i].End.Equal(b[i].End) || a[i].Period != b[i].Period || a[i].Multiplier != b[i].Multiplier {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseSchedules(t *testing.T) {
+	tests := []struct {
+		name    string
+		pricing PricingConfig
+	}{
+		{"unknown timezone", PricingConfig{Timezone: "Mars/Olympus_Mons"}},
+		{"unknown weekday", PricingConfig{Schedules: []PricingSchedule{{Name: "x", Weekdays: []string{"funday"}}}}},
+		{"not a range", PricingConfig{Schedules: []PricingSchedule{{Name: "x", Hours: []string{"22:00"}}}}},
+		{"empty range", PricingConfig{Schedules: []PricingSchedule{{Name: "x", Hours: []string{"00:00-24:00"}}}}},
+		{"starts at 24:00", PricingConfig{Schedules: []PricingSchedule{{Name: "x", Hours: []string{"24:00-06:00"}}}}},
+		{"not a time", PricingConfig{Schedules: []PricingSchedule{{Name: "x", Hours: []string{"25:00-06:00"}}}}},
+	}
+	for _, tt := range tests {
+		if err := tt.pricing.ParseSchedules(); err == nil {
+			t.Errorf("%s: ParseSchedules accepted %+v", tt.name, tt.pricing)
+		}
+		if tt.pricing.calendar != nil {
+			t.Errorf("%s: ParseSchedules kept a calendar it failed to parse", tt.name)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce coalesces the burst of events editors and ConfigMap updates
+// produce for a single logical change.
+const reloadDebounce = 500 * time.Millisecond
+
+// WatchConfig reloads the config file at path whenever it changes and stores
+// the result in store. A file that fails to load or validate is logged and
+// ignored, leaving the previous configuration in effect. onReload, if set,
+// is called with each newly applied configuration.
+//
+// The parent directory is watched rather than the file itself so that
+// atomic replacements (rename over, or Kubernetes ConfigMap symlink swaps)
+// are picked up.
+func WatchConfig(ctx context.Context, path string, overrides Overrides, store *ConfigStore, logger *slog.Logger, onReload func(*Config)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == filepath.Clean(path) || filepath.Base(event.Name) == "..data" {
+					debounce = time.After(reloadDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warn("config watcher error", "error", err)
+			case <-debounce:
+				debounce = nil
+				cfg, err := LoadConfig(path, overrides)
+				if err != nil {
+					logger.Error("failed to reload config, keeping previous configuration", "path", path, "error", err)
+					continue
+				}
+
+				warnRestartRequired(logger, store.Current(), cfg)
+				store.Set(cfg)
+				logger.Info("config reloaded", "path", path)
+				if onReload != nil {
+					onReload(cfg)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// warnRestartRequired logs settings that are only read at startup and so do
+// not take effect on reload.
+func warnRestartRequired(logger *slog.Logger, old, updated *Config) {
+	if old.Server != updated.Server {
+		logger.Warn("server settings changed, restart required for them to take effect")
+	}
+	if old.Kubernetes != updated.Kubernetes {
+		logger.Warn("kubernetes settings changed, restart required for them to take effect")
+	}
+	if !reflect.DeepEqual(clusterConnections(old), clusterConnections(updated)) {
+		logger.Warn("cluster list changed, restart required for it to take effect")
+	}
+	if old.Prometheus != updated.Prometheus {
+		logger.Warn("prometheus settings changed, restart required for them to take effect")
+	}
+	if !reflect.DeepEqual(old.Federation, updated.Federation) {
+		logger.Warn("federation settings changed, restart required for them to take effect")
+	}
+	if old.Logging.Format != updated.Logging.Format {
+		logger.Warn("logging format changed, restart required for it to take effect")
+	}
+}
+
+// clusterConnections strips the reloadable pricing from each cluster so that
+// only connection changes are reported.
+func clusterConnections(cfg *Config) []ClusterConfig {
+	clusters := make([]ClusterConfig, 0, len(cfg.ClusterConfigs()))
+	for _, cluster := range cfg.ClusterConfigs() {
+		cluster.Pricing = nil
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
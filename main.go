@@ -1,37 +1,183 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"slices"
 
 	"github.com/SinghaAnirban005/KuBudget/handlers"
 	"github.com/SinghaAnirban005/KuBudget/internal"
-	"github.com/SinghaAnirban005/KuBudget/pkg/kubernetes"
-	"github.com/SinghaAnirban005/KuBudget/pkg/prometheus"
+	"github.com/SinghaAnirban005/KuBudget/pkg/auth"
+	"github.com/SinghaAnirban005/KuBudget/pkg/logging"
+	"github.com/SinghaAnirban005/KuBudget/pkg/observability"
 	"github.com/SinghaAnirban005/KuBudget/services"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	promclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "export failed:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var overrides internal.Overrides
+	configPath := flag.String("config", os.Getenv("KUBUDGET_CONFIG"), "path to the YAML config file")
+	flag.StringVar(&overrides.Port, "port", "", "port to serve the API on")
+	flag.StringVar(&overrides.PrometheusURL, "prometheus-url", "", "Prometheus base URL")
+	flag.StringVar(&overrides.KubeConfigPath, "kubeconfig", "", "path to a kubeconfig file")
+	flag.StringVar(&overrides.LogLevel, "log-level", "", "log level: debug, info, warn or error")
+	flag.Parse()
+
+	cfg, err := internal.LoadConfig(*configPath, overrides)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	configStore := internal.NewConfigStore(cfg)
+
+	var logLevel slog.LevelVar
+	logLevel.UnmarshalText([]byte(cfg.Logging.Level))
+
+	appLogger, err := logging.New(os.Stdout, cfg.Logging.Format, &logLevel)
+	if err != nil {
+		log.Fatalf("Failed to create logger: %v", err)
+	}
+	slog.SetDefault(appLogger)
+
+	if *configPath != "" {
+		err := internal.WatchConfig(context.Background(), *configPath, overrides, configStore, appLogger, func(cfg *internal.Config) {
+			logLevel.UnmarshalText([]byte(cfg.Logging.Level))
+		})
+		if err != nil {
+			appLogger.Error("failed to watch config file", "path", *configPath, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	shutdownTracing, err := observability.InitTracing(context.Background())
+	if err != nil {
+		appLogger.Error("failed to initialize tracing", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
 
-	cfg := internal.LoadConfig()
+	clusterService, err := services.NewClusterService(configStore, appLogger)
+	if err != nil {
+		appLogger.Error("failed to create cluster clients", "error", err)
+		os.Exit(1)
+	}
+
+	costExporter := services.NewCostExporter(clusterService, configStore, appLogger)
+	registry := promclient.NewRegistry()
+	registry.MustRegister(costExporter)
+	go costExporter.Run(context.Background())
+
+	healthService := services.NewHealthService(clusterService, costExporter, configStore)
 
-	k8sClient, err := kubernetes.NewClient(cfg.KubeConfigPath)
+	federationService, err := services.NewFederationService(clusterService, configStore, appLogger)
 	if err != nil {
-		log.Fatalf("Failed to create kubernetes cluster,  %v", err)
+		appLogger.Error("failed to initialize federation", "error", err)
+		os.Exit(1)
+	}
+	switch cfg.Federation.Mode {
+	case services.FederationModeHub:
+		go federationService.RunHub(context.Background())
+	case services.FederationModeSpoke:
+		go federationService.RunSpoke(context.Background())
+	}
+
+	var focusService *services.FocusService
+	if cfg.Focus.Enabled {
+		if focusService, err = services.NewFocusService(clusterService, configStore, appLogger); err != nil {
+			appLogger.Error("failed to initialize FOCUS ledger", "error", err)
+			os.Exit(1)
+		}
+		go focusService.Run(context.Background())
 	}
 
-	promClient := prometheus.NewClient(cfg.PrometheusURL)
+	var invoiceService *services.InvoiceService
+	if cfg.Invoicing.Enabled {
+		if invoiceService, err = services.NewInvoiceService(focusService, configStore, appLogger); err != nil {
+			appLogger.Error("failed to initialize invoicing", "error", err)
+			os.Exit(1)
+		}
+		go invoiceService.Run(context.Background())
+	}
+
+	var externalCostService *services.ExternalCostService
+	if cfg.ExternalCosts.Enabled {
+		if externalCostService, err = services.NewExternalCostService(configStore, appLogger); err != nil {
+			appLogger.Error("failed to initialize external costs", "error", err)
+			os.Exit(1)
+		}
+		go externalCostService.Run(context.Background())
+	}
 
-	costService := services.NewCostService(k8sClient, promClient)
-	metricsService := services.NewMetricsService(k8sClient, promClient)
+	var exchangeRateService *services.ExchangeRateService
+	if cfg.Currency.ExchangeRates.Enabled {
+		if exchangeRateService, err = services.NewExchangeRateService(clusterService, configStore, appLogger); err != nil {
+			appLogger.Error("failed to initialize exchange rates", "error", err)
+			os.Exit(1)
+		}
+		go exchangeRateService.Run(context.Background())
+	}
 
-	costHandler := handlers.NewCostHandler(costService)
-	healthHandler := handlers.NewHealthHandler()
-	metricsHandler := handlers.NewMetricsHandler(metricsService)
+	reconciliationService := services.NewReconciliationService(clusterService, configStore, appLogger)
+	if cfg.Reconciliation.Enabled {
+		go reconciliationService.Run(context.Background())
+	}
+
+	discountService := services.NewDiscountService(clusterService, configStore, appLogger)
+	if cfg.Discounts.Enabled {
+		go discountService.Run(context.Background())
+	}
+
+	var priceHistoryService *services.PriceHistoryService
+	if cfg.PriceHistory.Enabled {
+		if priceHistoryService, err = services.NewPriceHistoryService(clusterService, configStore, appLogger); err != nil {
+			appLogger.Error("failed to initialize price history", "error", err)
+			os.Exit(1)
+		}
+		go priceHistoryService.Run(context.Background())
+	}
+
+	reviewCluster := clusterService.Clusters()[0]
+	if name := cfg.Auth.Kubernetes.Cluster; name != "" {
+		if reviewCluster, err = clusterService.Get(name); err != nil {
+			appLogger.Error("failed to select cluster for Kubernetes auth", "error", err)
+			os.Exit(1)
+		}
+	}
+	if cfg.Auth.Kubernetes.Enabled && cfg.Auth.Kubernetes.DevMode {
+		appLogger.Warn("Kubernetes auth is in dev mode, bearer tokens are not reviewed", "dev_user", cfg.Auth.Kubernetes.DevUser)
+	}
+	authenticator := auth.NewAuthenticator(configStore, reviewCluster.Name, reviewCluster.K8sClient, appLogger)
+
+	costHandler := handlers.NewCostHandler(clusterService, externalCostService, appLogger)
+	healthHandler := handlers.NewHealthHandler(healthService)
+	metricsHandler := handlers.NewMetricsHandler(clusterService, appLogger)
+	exportHandler := handlers.NewExportHandler(clusterService, appLogger)
+	federationHandler := handlers.NewFederationHandler(clusterService, federationService, appLogger)
+	focusHandler := handlers.NewFocusHandler(focusService, appLogger)
+	invoiceHandler := handlers.NewInvoiceHandler(invoiceService, appLogger)
+	reconciliationHandler := handlers.NewReconciliationHandler(reconciliationService, appLogger)
+	externalCostHandler := handlers.NewExternalCostHandler(externalCostService, appLogger)
+	exchangeRateHandler := handlers.NewExchangeRateHandler(exchangeRateService, appLogger)
+	discountHandler := handlers.NewDiscountHandler(discountService, appLogger)
+	priceHistoryHandler := handlers.NewPriceHistoryHandler(clusterService, priceHistoryService, appLogger)
+	commitmentHandler := handlers.NewCommitmentHandler(services.NewCommitmentService(clusterService, configStore, appLogger), appLogger)
 
 	app := fiber.New(fiber.Config{
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
@@ -45,36 +191,118 @@ func main() {
 		},
 	})
 
-	app.Use(logger.New())
+	app.Use(observability.TracingMiddleware())
+	app.Use(logging.Middleware(appLogger))
 	app.Use(recover.New())
+	app.Use(observability.Middleware())
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
+		AllowOriginsFunc: func(origin string) bool {
+			return slices.Contains(configStore.Current().Auth.AllowedOrigins, origin)
+		},
 		AllowMethods: "GET,POST,HEAD,PUT,DELETE,PATCH,OPTIONS",
 		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
 	}))
 
+	app.Get("/livez", healthHandler.Health)
+	app.Get("/readyz", healthHandler.Ready)
+	app.Get("/startupz", healthHandler.Startup)
+	app.Get("/metrics", authenticator.Middleware(), auth.RequireClusterWide(), adaptor.HTTPHandler(promhttp.HandlerFor(registry, promhttp.HandlerOpts{})))
+
 	api := app.Group("/api/v1")
 
 	api.Get("/health", healthHandler.Health)
 	api.Get("/ready", healthHandler.Ready)
-	api.Get("/costs/overview", costHandler.GetCostOverview)
-	api.Get("/costs/namespaces", costHandler.GetNamespaceCosts)
-	api.Get("/costs/pods", costHandler.GetPodCosts)
-	api.Get("/costs/nodes", costHandler.GetNodeCosts)
-	api.Get("/costs/history", costHandler.GetCostHistory)
 
-	api.Get("/metrics/prometheus", metricsHandler.GetPrometheusMetrics)
-	api.Get("/metrics/cluster", metricsHandler.GetClusterMetrics)
-	api.Get("/metrics/resource-usage", metricsHandler.GetResourceUsage)
+	costs := api.Group("/costs", authenticator.Middleware())
+	costs.Get("/overview", costHandler.GetCostOverview)
+	costs.Get("/clusters", costHandler.GetClusterCosts)
+	costs.Get("/namespaces", costHandler.GetNamespaceCosts)
+	costs.Get("/pods", costHandler.GetPodCosts)
+	costs.Get("/nodes", costHandler.GetNodeCosts)
+	costs.Get("/history", costHandler.GetCostHistory)
+
+	metrics := api.Group("/metrics", authenticator.Middleware())
+	metrics.Get("/prometheus", metricsHandler.GetPrometheusMetrics)
+	metrics.Get("/cluster", metricsHandler.GetClusterMetrics)
+	metrics.Get("/resource-usage", metricsHandler.GetResourceUsage)
+
+	exports := api.Group("/export", authenticator.Middleware())
+	exports.Get("/schema", exportHandler.GetSchema)
+	exports.Get("/allocations", exportHandler.ExportAllocations)
+
+	if cfg.Focus.Enabled {
+		billing := api.Group("/focus", authenticator.Middleware(), auth.RequireClusterWide())
+		billing.Get("/periods", focusHandler.GetPeriods)
+		billing.Get("/periods/:period", focusHandler.DownloadPeriod)
+		billing.Get("/records", focusHandler.GetRecords)
+	}
+
+	if cfg.Invoicing.Enabled {
+		invoices := api.Group("/invoices", authenticator.Middleware(), auth.RequireClusterWide())
+		invoices.Get("/", invoiceHandler.ListInvoices)
+		invoices.Post("/close", invoiceHandler.CloseInvoices)
+		invoices.Get("/adjustments", invoiceHandler.ListAdjustments)
+		invoices.Post("/adjustments", invoiceHandler.AddAdjustment)
+		invoices.Get("/:number", invoiceHandler.GetInvoice)
+	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8000"
+	if cfg.Reconciliation.Enabled {
+		reconciliation := api.Group("/reconciliation", authenticator.Middleware(), auth.RequireClusterWide())
+		reconciliation.Get("/", reconciliationHandler.GetReport)
+		reconciliation.Post("/refresh", reconciliationHandler.Reconcile)
 	}
 
-	log.Printf("Server is starting on port %s", port)
+	if cfg.ExternalCosts.Enabled {
+		external := api.Group("/external-costs", authenticator.Middleware(), auth.RequireClusterWide())
+		external.Get("/", externalCostHandler.ListExternalCosts)
+		external.Post("/", externalCostHandler.IngestExternalCosts)
+		external.Delete("/:id", externalCostHandler.DeleteExternalCost)
+	}
+
+	if cfg.Currency.ExchangeRates.Enabled {
+		rates := api.Group("/exchange-rates", authenticator.Middleware(), auth.RequireClusterWide())
+		rates.Get("/", exchangeRateHandler.ListExchangeRates)
+		rates.Post("/", exchangeRateHandler.UploadExchangeRates)
+	}
+
+	if cfg.Discounts.Enabled {
+		discounts := api.Group("/discounts", authenticator.Middleware(), auth.RequireClusterWide())
+		discounts.Get("/", discountHandler.GetReport)
+		discounts.Post("/refresh", discountHandler.Refresh)
+	}
+
+	if cfg.Commitments.Enabled {
+		commitments := api.Group("/commitments", authenticator.Middleware(), auth.RequireClusterWide())
+		commitments.Get("/recommendations", commitmentHandler.GetRecommendations)
+	}
+
+	if cfg.PriceHistory.Enabled {
+		prices := api.Group("/pricing/versions", authenticator.Middleware(), auth.RequireClusterWide())
+		prices.Get("/", priceHistoryHandler.ListVersions)
+		prices.Get("/:id/compare", priceHistoryHandler.Compare)
+	}
+
+	federation := api.Group("/federation")
+	switch cfg.Federation.Mode {
+	case services.FederationModeHub:
+		federation.Post("/snapshots", federationHandler.Authenticate, federationHandler.PushSnapshots)
+		federation.Get("/clusters", authenticator.Middleware(), federationHandler.GetClusters)
+		federation.Get("/overview", authenticator.Middleware(), federationHandler.GetOverview)
+	case services.FederationModeSpoke:
+		federation.Get("/snapshots", federationHandler.Authenticate, federationHandler.GetSnapshots)
+	}
+
+	go func() {
+		appLogger.Info("internal metrics server is starting", "port", cfg.Server.InternalPort)
+		if err := http.ListenAndServe(":"+cfg.Server.InternalPort, observability.Handler(cfg.Server.EnablePprof)); err != nil {
+			appLogger.Error("internal metrics server stopped", "error", err)
+		}
+	}()
+
+	appLogger.Info("server is starting", "port", cfg.Server.Port)
 
-	if err := app.Listen(":" + port); err != nil {
-		log.Fatalf("Failed to start server %v", err)
+	if err := app.Listen(":" + cfg.Server.Port); err != nil {
+		appLogger.Error("failed to start server", "error", err)
+		os.Exit(1)
 	}
 }
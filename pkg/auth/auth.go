@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/kubernetes"
+	"github.com/gofiber/fiber/v2"
+)
+
+const principalKey = "auth.principal"
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Name   string
+	Method string
+	Scope  Scope
+}
+
+// anonymous is the principal used when authentication is disabled.
+var anonymous = Principal{Name: "anonymous", Method: "none", Scope: Unrestricted()}
+
+// Authenticator resolves bearer tokens to principals. It reads the current
+// configuration on every request, so token and scope changes apply on
+// reload.
+type Authenticator struct {
+	config     *internal.ConfigStore
+	oidc       *oidcVerifier
+	kubernetes *kubernetesAuthenticator
+	logger     *slog.Logger
+}
+
+// NewAuthenticator creates an Authenticator. reviewer is the client of
+// cluster, the cluster used for Kubernetes token and access reviews.
+func NewAuthenticator(config *internal.ConfigStore, cluster string, reviewer Reviewer, logger *slog.Logger) *Authenticator {
+	return &Authenticator{
+		config:     config,
+		oidc:       &oidcVerifier{},
+		kubernetes: &kubernetesAuthenticator{cluster: cluster, reviewer: reviewer},
+		logger:     logger,
+	}
+}
+
+// Middleware authenticates the request and stores the principal for
+// FromCtx. Requests without a valid token, or whose identity is granted no
+// scopes, are rejected.
+func (a *Authenticator) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		current := a.config.Current()
+		cfg := current.Auth
+		if !cfg.Enabled {
+			c.Locals(principalKey, anonymous)
+			return c.Next()
+		}
+
+		ctx := c.UserContext()
+
+		token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if (!ok || token == "") && !(cfg.Kubernetes.Enabled && cfg.Kubernetes.DevMode) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Missing bearer token",
+			})
+		}
+
+		principal, ok := a.authenticateToken(cfg, token)
+		if !ok && cfg.OIDC.IssuerURL != "" {
+			var err error
+			principal, err = a.oidc.authenticate(ctx, cfg.OIDC, token)
+			if err != nil {
+				a.logger.DebugContext(ctx, "rejected OIDC token", "error", err)
+			} else {
+				ok = true
+			}
+		}
+		if !ok && cfg.Kubernetes.Enabled {
+			var err error
+			principal, err = a.kubernetes.authenticate(ctx, cfg.Kubernetes, a.kubernetes.grantedClusters(current), token)
+			switch {
+			case errors.Is(err, kubernetes.ErrUnauthenticated):
+				a.logger.DebugContext(ctx, "rejected Kubernetes token", "error", err)
+			case err != nil:
+				a.logger.ErrorContext(ctx, "failed to review Kubernetes token", "error", err)
+				return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+					"error":   "Failed to review token",
+					"details": err.Error(),
+				})
+			default:
+				ok = true
+			}
+		}
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid bearer token",
+			})
+		}
+
+		if principal.Scope.Empty() {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "No scopes granted",
+			})
+		}
+
+		c.Locals(principalKey, principal)
+		return c.Next()
+	}
+}
+
+// authenticateToken matches token against the configured static tokens.
+// Every digest is compared so that timing does not reveal which matched.
+func (a *Authenticator) authenticateToken(cfg internal.AuthConfig, token string) (Principal, bool) {
+	digest := sha256.Sum256([]byte(token))
+
+	var principal Principal
+	found := false
+	for _, t := range cfg.Tokens {
+		expected, err := hex.DecodeString(t.SHA256)
+		if err != nil {
+			continue
+		}
+		if subtle.ConstantTimeCompare(digest[:], expected) == 1 {
+			principal = Principal{Name: t.Name, Method: "token", Scope: NewScope(t.Scopes...)}
+			found = true
+		}
+	}
+
+	return principal, found
+}
+
+// FromCtx returns the principal stored by Middleware. Routes that are not
+// behind Middleware get an empty principal that can see nothing.
+func FromCtx(c *fiber.Ctx) Principal {
+	principal, _ := c.Locals(principalKey).(Principal)
+	return principal
+}
+
+// RequireClusterWide rejects principals whose scope does not cover the
+// whole cluster, for routes whose output cannot be filtered.
+func RequireClusterWide() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !FromCtx(c).Scope.ClusterWide() {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Insufficient scope",
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/kubernetes"
+	"github.com/SinghaAnirban005/KuBudget/pkg/observability"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// maxCacheEntries bounds each review cache; expired entries are swept
+	// once it is reached.
+	maxCacheEntries = 10000
+
+	// reviewConcurrency bounds the SubjectAccessReviews issued at once when
+	// resolving a caller's namespaces.
+	reviewConcurrency = 10
+)
+
+// Reviewer is the part of the Kubernetes client used to authenticate and
+// authorize callers.
+type Reviewer interface {
+	ReviewToken(ctx context.Context, token string, audiences []string) (*authenticationv1.UserInfo, error)
+	CanListPods(ctx context.Context, user *authenticationv1.UserInfo, namespace string) (bool, error)
+	GetNamespaces(ctx context.Context) (*corev1.NamespaceList, error)
+}
+
+type cacheEntry[T any] struct {
+	value   T
+	err     error
+	expires time.Time
+}
+
+// ttlCache is a small map cache whose entries expire after a TTL.
+type ttlCache[T any] struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry[T]
+}
+
+func (c *ttlCache[T]) get(key string, now time.Time) (cacheEntry[T], bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || now.After(entry.expires) {
+		return cacheEntry[T]{}, false
+	}
+	return entry, true
+}
+
+func (c *ttlCache[T]) set(key string, entry cacheEntry[T], now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]cacheEntry[T])
+	}
+	if len(c.entries) >= maxCacheEntries {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCacheEntries {
+			clear(c.entries)
+		}
+	}
+	c.entries[key] = entry
+}
+
+// kubernetesAuthenticator turns Kubernetes bearer tokens into principals
+// whose scope is the set of namespaces the user may list pods in. Reviews
+// are made against a single cluster, so the namespaces are only granted in
+// that cluster.
+type kubernetesAuthenticator struct {
+	cluster    string
+	reviewer   Reviewer
+	tokens     ttlCache[*authenticationv1.UserInfo]
+	access     ttlCache[bool]
+	namespaces ttlCache[[]string]
+}
+
+// grantedClusters returns the clusters that reviews grant access to: the
+// reviewed cluster, or nil for every cluster when it is the only one. A hub
+// also serves its spokes' clusters, which are never reviewed.
+func (k *kubernetesAuthenticator) grantedClusters(cfg *internal.Config) []string {
+	if len(cfg.ClusterConfigs()) == 1 && cfg.Federation.Mode != "hub" {
+		return nil
+	}
+	return []string{k.cluster}
+}
+
+func (k *kubernetesAuthenticator) authenticate(ctx context.Context, cfg internal.KubernetesAuthConfig, clusters []string, token string) (Principal, error) {
+	if cfg.DevMode {
+		if cfg.DevUser == "" {
+			return Principal{Name: "dev", Method: "kubernetes-dev", Scope: Unrestricted()}, nil
+		}
+		user := &authenticationv1.UserInfo{Username: cfg.DevUser, Groups: cfg.DevGroups}
+		return k.principal(ctx, cfg, clusters, user, "kubernetes-dev")
+	}
+
+	user, err := k.reviewToken(ctx, cfg, token)
+	if err != nil {
+		return Principal{}, err
+	}
+	return k.principal(ctx, cfg, clusters, user, "kubernetes")
+}
+
+func (k *kubernetesAuthenticator) principal(ctx context.Context, cfg internal.KubernetesAuthConfig, clusters []string, user *authenticationv1.UserInfo, method string) (Principal, error) {
+	scope, err := k.scope(ctx, cfg, clusters, user)
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{Name: user.Username, Method: method, Scope: scope}, nil
+}
+
+func (k *kubernetesAuthenticator) reviewToken(ctx context.Context, cfg internal.KubernetesAuthConfig, token string) (*authenticationv1.UserInfo, error) {
+	// Tokens are cached by digest so that raw credentials are not kept in
+	// memory longer than the request.
+	digest := sha256.Sum256([]byte(token))
+	key := string(digest[:])
+	now := time.Now()
+
+	entry, hit := k.tokens.get(key, now)
+	observability.ObserveCacheLookup("token_review", hit)
+	if hit {
+		return entry.value, entry.err
+	}
+
+	user, err := k.reviewer.ReviewToken(ctx, token, cfg.Audiences)
+	if err != nil && !errors.Is(err, kubernetes.ErrUnauthenticated) {
+		// Only rejections are cached; a failure to reach the API server
+		// is retried on the next request.
+		return nil, err
+	}
+	k.tokens.set(key, cacheEntry[*authenticationv1.UserInfo]{value: user, err: err, expires: now.Add(cfg.CacheTTL)}, now)
+
+	return user, err
+}
+
+// scope resolves the namespaces user may list pods in and grants them in
+// clusters. A user allowed to list pods cluster-wide is granted every
+// namespace without per-namespace reviews.
+func (k *kubernetesAuthenticator) scope(ctx context.Context, cfg internal.KubernetesAuthConfig, clusters []string, user *authenticationv1.UserInfo) (Scope, error) {
+	allowed, err := k.canListPods(ctx, cfg, user, "")
+	if err != nil {
+		return Scope{}, err
+	}
+	if allowed {
+		return NewScope(internal.ScopeConfig{Clusters: clusters, Namespaces: []string{"*"}}), nil
+	}
+
+	namespaces, err := k.listNamespaces(ctx, cfg)
+	if err != nil {
+		return Scope{}, err
+	}
+
+	visible := make([]bool, len(namespaces))
+	errs := make([]error, len(namespaces))
+	sem := make(chan struct{}, reviewConcurrency)
+
+	var wg sync.WaitGroup
+	for i, namespace := range namespaces {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			visible[i], errs[i] = k.canListPods(ctx, cfg, user, namespace)
+		}()
+	}
+	wg.Wait()
+
+	granted := make([]string, 0)
+	for i, namespace := range namespaces {
+		if errs[i] != nil {
+			return Scope{}, errs[i]
+		}
+		if visible[i] {
+			granted = append(granted, namespace)
+		}
+	}
+
+	if len(granted) == 0 {
+		return Scope{}, nil
+	}
+	return NewScope(internal.ScopeConfig{Clusters: clusters, Namespaces: granted}), nil
+}
+
+func (k *kubernetesAuthenticator) canListPods(ctx context.Context, cfg internal.KubernetesAuthConfig, user *authenticationv1.UserInfo, namespace string) (bool, error) {
+	groups := append([]string(nil), user.Groups...)
+	sort.Strings(groups)
+	key := user.Username + "\x00" + user.UID + "\x00" + strings.Join(groups, ",") + "\x00" + namespace
+	now := time.Now()
+
+	entry, hit := k.access.get(key, now)
+	observability.ObserveCacheLookup("access_review", hit)
+	if hit {
+		return entry.value, nil
+	}
+
+	allowed, err := k.reviewer.CanListPods(ctx, user, namespace)
+	if err != nil {
+		// Failed reviews are not cached so that a transient API error
+		// does not lock the caller out for a full TTL.
+		return false, err
+	}
+	k.access.set(key, cacheEntry[bool]{value: allowed, expires: now.Add(cfg.CacheTTL)}, now)
+
+	return allowed, nil
+}
+
+func (k *kubernetesAuthenticator) listNamespaces(ctx context.Context, cfg internal.KubernetesAuthConfig) ([]string, error) {
+	now := time.Now()
+
+	entry, hit := k.namespaces.get("", now)
+	observability.ObserveCacheLookup("namespace_list", hit)
+	if hit {
+		return entry.value, nil
+	}
+
+	list, err := k.reviewer.GetNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := make([]string, 0, len(list.Items))
+	for _, ns := range list.Items {
+		namespaces = append(namespaces, ns.Name)
+	}
+	k.namespaces.set("", cacheEntry[[]string]{value: namespaces, expires: now.Add(cfg.CacheTTL)}, now)
+
+	return namespaces, nil
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// oidcVerifier lazily builds an ID token verifier for the configured issuer
+// and rebuilds it when the issuer settings change, so that an unreachable
+// issuer does not prevent startup.
+type oidcVerifier struct {
+	mu       sync.Mutex
+	key      [3]string
+	verifier *oidc.IDTokenVerifier
+}
+
+func (v *oidcVerifier) authenticate(ctx context.Context, cfg internal.OIDCConfig, rawToken string) (Principal, error) {
+	verifier, err := v.get(cfg)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	token, err := verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	var claims map[string]any
+	if err := token.Claims(&claims); err != nil {
+		return Principal{}, fmt.Errorf("failed to decode claims: %v", err)
+	}
+
+	groups := claimStrings(claims[cfg.GroupsClaim])
+
+	var scope Scope
+	for _, group := range cfg.Groups {
+		for _, member := range groups {
+			if member == group.Group {
+				scope = scope.Merge(NewScope(group.Scopes...))
+				break
+			}
+		}
+	}
+
+	return Principal{Name: token.Subject, Method: "oidc", Scope: scope}, nil
+}
+
+func (v *oidcVerifier) get(cfg internal.OIDCConfig) (*oidc.IDTokenVerifier, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key := [3]string{cfg.IssuerURL, cfg.JWKSURL, cfg.Audience}
+	if v.verifier != nil && v.key == key {
+		return v.verifier, nil
+	}
+
+	// The key set keeps refreshing keys with this context, so it must
+	// outlive the request that triggered initialization.
+	ctx := context.Background()
+	config := &oidc.Config{ClientID: cfg.Audience}
+
+	if cfg.JWKSURL != "" {
+		v.verifier = oidc.NewVerifier(cfg.IssuerURL, oidc.NewRemoteKeySet(ctx, cfg.JWKSURL), config)
+	} else {
+		provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover OIDC issuer: %v", err)
+		}
+		v.verifier = provider.Verifier(config)
+	}
+	v.key = key
+
+	return v.verifier, nil
+}
+
+// claimStrings accepts a claim holding either a single string or a list.
+func claimStrings(claim any) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
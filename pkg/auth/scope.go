@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"slices"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+)
+
+// Scope is the set of namespaces and workloads a caller may see. It is the
+// union of the grants of every token scope or group the caller matched.
+type Scope struct {
+	grants []internal.ScopeConfig
+}
+
+// Unrestricted returns a scope that grants everything.
+func Unrestricted() Scope {
+	return Scope{grants: []internal.ScopeConfig{{Namespaces: []string{"*"}}}}
+}
+
+func NewScope(grants ...internal.ScopeConfig) Scope {
+	return Scope{grants: grants}
+}
+
+// Merge returns the union of s and other.
+func (s Scope) Merge(other Scope) Scope {
+	return Scope{grants: append(slices.Clip(s.grants), other.grants...)}
+}
+
+// Empty reports whether the scope grants nothing at all.
+func (s Scope) Empty() bool {
+	return len(s.grants) == 0
+}
+
+// ClusterWide reports whether the scope covers every pod in every
+// namespace of every cluster, which is required for cluster-level data such
+// as nodes.
+func (s Scope) ClusterWide() bool {
+	for _, grant := range s.grants {
+		if len(grant.Clusters) == 0 && len(grant.Labels) == 0 && slices.Contains(grant.Namespaces, "*") {
+			return true
+		}
+	}
+	return false
+}
+
+// NamespaceVisible reports whether every pod in namespace of cluster is
+// visible.
+func (s Scope) NamespaceVisible(cluster, namespace string) bool {
+	for _, grant := range s.grants {
+		if len(grant.Labels) == 0 && grantsCluster(grant, cluster) && grantsNamespace(grant, namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// PodVisible reports whether a pod with labels in namespace of cluster is
+// visible.
+func (s Scope) PodVisible(cluster, namespace string, labels map[string]string) bool {
+	for _, grant := range s.grants {
+		if grantsCluster(grant, cluster) && grantsNamespace(grant, namespace) && matchesLabels(grant.Labels, labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterNamespaces drops namespaces the scope cannot see. A namespace that
+// is only partly visible keeps its visible pods, with its totals recomputed
+// from them so that hidden pods cannot be inferred.
+func (s Scope) FilterNamespaces(namespaces []internal.NamespaceCost) []internal.NamespaceCost {
+	if s.ClusterWide() {
+		return namespaces
+	}
+
+	filtered := make([]internal.NamespaceCost, 0, len(namespaces))
+	for _, ns := range namespaces {
+		if s.NamespaceVisible(ns.Cluster, ns.Namespace) {
+			filtered = append(filtered, ns)
+			continue
+		}
+
+		pods := s.FilterPods(ns.Pods)
+		if len(pods) == 0 {
+			continue
+		}
+
+		ns.SetPods(pods)
+		ns.PodCount = len(pods)
+		filtered = append(filtered, ns)
+	}
+	return filtered
+}
+
+// FilterPods drops pods the scope cannot see.
+func (s Scope) FilterPods(pods []internal.PodCost) []internal.PodCost {
+	if s.ClusterWide() {
+		return pods
+	}
+
+	filtered := make([]internal.PodCost, 0, len(pods))
+	for _, pod := range pods {
+		if s.PodVisible(pod.Cluster, pod.Namespace, pod.Labels) {
+			filtered = append(filtered, pod)
+		}
+	}
+	return filtered
+}
+
+func grantsCluster(grant internal.ScopeConfig, cluster string) bool {
+	return len(grant.Clusters) == 0 || slices.Contains(grant.Clusters, cluster)
+}
+
+func grantsNamespace(grant internal.ScopeConfig, namespace string) bool {
+	return slices.Contains(grant.Namespaces, "*") || slices.Contains(grant.Namespaces, namespace)
+}
+
+func matchesLabels(selector, labels map[string]string) bool {
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
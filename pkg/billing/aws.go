@@ -0,0 +1,245 @@
+package billing
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/types"
+)
+
+// CUR columns, in the snake_case form used by Parquet reports. CSV headers
+// such as lineItem/ResourceId are converted to this form.
+const (
+	curLineItemType      = "line_item_line_item_type"
+	curResourceID        = "line_item_resource_id"
+	curUsageStart        = "line_item_usage_start_date"
+	curUsageEnd          = "line_item_usage_end_date"
+	curUnblendedCost     = "line_item_unblended_cost"
+	curSavingsPlanCost   = "savings_plan_savings_plan_effective_cost"
+	curReservationCost   = "reservation_effective_cost"
+	parquetReadBatchSize = 10000
+)
+
+var curColumns = []string{curLineItemType, curResourceID, curUsageStart, curUsageEnd, curUnblendedCost, curSavingsPlanCost, curReservationCost}
+
+// awsLineItem converts one CUR row. Usage covered by a savings plan or a
+// reservation is charged at its effective cost, and the savings plan
+// negation rows that cancel its on-demand cost are dropped, so that the
+// sum of a resource's items is what was paid for it.
+func awsLineItem(get func(column string) string) (LineItem, bool, error) {
+	resourceID := get(curResourceID)
+	if resourceID == "" {
+		return LineItem{}, false, nil
+	}
+
+	costColumn := curUnblendedCost
+	switch get(curLineItemType) {
+	case "SavingsPlanNegation":
+		return LineItem{}, false, nil
+	case "SavingsPlanCoveredUsage":
+		if get(curSavingsPlanCost) != "" {
+			costColumn = curSavingsPlanCost
+		}
+	case "DiscountedUsage":
+		if get(curReservationCost) != "" {
+			costColumn = curReservationCost
+		}
+	}
+
+	cost, err := strconv.ParseFloat(get(costColumn), 64)
+	if err != nil {
+		return LineItem{}, false, fmt.Errorf("%s: invalid cost %q", costColumn, get(costColumn))
+	}
+	start, err := parseTime(get(curUsageStart))
+	if err != nil {
+		return LineItem{}, false, fmt.Errorf("%s: %v", curUsageStart, err)
+	}
+	end, err := parseTime(get(curUsageEnd))
+	if err != nil {
+		return LineItem{}, false, fmt.Errorf("%s: %v", curUsageEnd, err)
+	}
+
+	return LineItem{ResourceID: resourceID, Start: start, End: end, Cost: cost}, true, nil
+}
+
+func loadAWSCSV(r io.Reader) ([]LineItem, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %v", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[curColumnName(name)] = i
+	}
+	for _, column := range []string{curResourceID, curUsageStart, curUsageEnd, curUnblendedCost} {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("missing column %s", column)
+		}
+	}
+
+	var items []LineItem
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		item, ok, err := awsLineItem(func(column string) string {
+			if i, ok := index[column]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		})
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if ok {
+			items = append(items, item)
+		}
+	}
+}
+
+func loadAWSParquet(path string) ([]LineItem, error) {
+	file, err := local.NewLocalFileReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	pr, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	defer pr.ReadStop()
+
+	paths := make(map[string]string, len(curColumns))
+	elements := make(map[string]*parquet.SchemaElement, len(curColumns))
+	for _, inPath := range pr.SchemaHandler.ValueColumns {
+		exPath := pr.SchemaHandler.InPathToExPath[inPath]
+		name := curColumnName(exPath[strings.LastIndex(exPath, "\x01")+1:])
+		paths[name] = inPath
+		elements[name] = pr.SchemaHandler.SchemaElements[pr.SchemaHandler.MapIndex[inPath]]
+	}
+	for _, column := range []string{curResourceID, curUsageStart, curUsageEnd, curUnblendedCost} {
+		if _, ok := paths[column]; !ok {
+			return nil, fmt.Errorf("failed to read %s: missing column %s", path, column)
+		}
+	}
+
+	var items []LineItem
+	rows := pr.GetNumRows()
+	for read := int64(0); read < rows; read += parquetReadBatchSize {
+		batch := min(parquetReadBatchSize, rows-read)
+
+		columns := make(map[string][]any, len(paths))
+		for name, inPath := range paths {
+			values, _, _, err := pr.ReadColumnByPath(inPath, batch)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: column %s: %v", path, name, err)
+			}
+			columns[name] = values
+		}
+
+		for i := range int(batch) {
+			item, ok, err := awsLineItem(func(column string) string {
+				values := columns[column]
+				if i >= len(values) {
+					return ""
+				}
+				return parquetString(values[i], elements[column])
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: row %d: %v", path, read+int64(i)+1, err)
+			}
+			if ok {
+				items = append(items, item)
+			}
+		}
+	}
+	return items, nil
+}
+
+// parquetString formats a Parquet value the way it would appear in a CSV
+// report, so that both are parsed by awsLineItem.
+func parquetString(value any, element *parquet.SchemaElement) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		if element.GetType() == parquet.Type_INT96 {
+			return types.INT96ToTime(v).UTC().Format(time.RFC3339)
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case int64:
+		if t, ok := parquetTimestamp(v, element); ok {
+			return t.Format(time.RFC3339)
+		}
+		return strconv.FormatInt(v, 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func parquetTimestamp(v int64, element *parquet.SchemaElement) (time.Time, bool) {
+	if logical := element.GetLogicalType(); logical != nil && logical.IsSetTIMESTAMP() {
+		unit := logical.GetTIMESTAMP().GetUnit()
+		switch {
+		case unit.IsSetMICROS():
+			return types.TIMESTAMP_MICROSToTime(v, true).UTC(), true
+		case unit.IsSetNANOS():
+			return types.TIMESTAMP_NANOSToTime(v, true).UTC(), true
+		default:
+			return types.TIMESTAMP_MILLISToTime(v, true).UTC(), true
+		}
+	}
+
+	switch element.GetConvertedType() {
+	case parquet.ConvertedType_TIMESTAMP_MILLIS:
+		return types.TIMESTAMP_MILLISToTime(v, true).UTC(), true
+	case parquet.ConvertedType_TIMESTAMP_MICROS:
+		return types.TIMESTAMP_MICROSToTime(v, true).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// curColumnName converts a CSV header such as lineItem/UnblendedCost to
+// line_item_unblended_cost. Names already in snake_case are unchanged.
+func curColumnName(name string) string {
+	var b strings.Builder
+	runes := []rune(strings.TrimSpace(name))
+	for i, r := range runes {
+		switch {
+		case r == '/':
+			b.WriteByte('_')
+		case unicode.IsUpper(r):
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
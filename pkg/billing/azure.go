@@ -0,0 +1,82 @@
+package billing
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Azure cost details exports have renamed their columns over time, so
+// each field is looked up under every name it has had, in order.
+var (
+	azureResourceColumns = []string{"resourceid", "instanceid", "instancename"}
+	azureCostColumns     = []string{"costinbillingcurrency", "cost", "costinusd", "pretaxcost"}
+	azureDateColumns     = []string{"date", "usagedate", "usagedatetime"}
+)
+
+// loadAzure reads a cost details CSV. Rows are daily, so each line item
+// covers the 24 hours of its date.
+func loadAzure(r io.Reader) ([]LineItem, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %v", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	column := func(names []string) (int, error) {
+		for _, name := range names {
+			if i, ok := index[name]; ok {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("missing column %s", names[0])
+	}
+
+	resourceColumn, err := column(azureResourceColumns)
+	if err != nil {
+		return nil, err
+	}
+	costColumn, err := column(azureCostColumns)
+	if err != nil {
+		return nil, err
+	}
+	dateColumn, err := column(azureDateColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []LineItem
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if record[resourceColumn] == "" {
+			continue
+		}
+
+		cost, err := strconv.ParseFloat(record[costColumn], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid cost %q", line, record[costColumn])
+		}
+		date, err := parseTime(record[dateColumn])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		start := date.Truncate(24 * time.Hour)
+
+		items = append(items, LineItem{ResourceID: record[resourceColumn], Start: start, End: start.Add(24 * time.Hour), Cost: cost})
+	}
+}
@@ -0,0 +1,118 @@
+// Package billing reads cloud provider billing exports into line items
+// that can be matched against Kubernetes nodes and volumes.
+//
+// Supported formats are the AWS Cost and Usage Report (CSV, optionally
+// gzipped, or Parquet), the GCP billing export as JSON (an array or one
+// object per line) and Azure cost details CSV. Each line item carries the
+// cost actually paid after discounts, savings plans, reservations and
+// credits, as far as the export records them.
+package billing
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	FormatAWSCUR = "aws-cur"
+	FormatGCP    = "gcp"
+	FormatAzure  = "azure"
+)
+
+// Formats lists the supported billing export formats.
+var Formats = []string{FormatAWSCUR, FormatGCP, FormatAzure}
+
+var ErrUnknownFormat = errors.New("unknown billing format")
+
+// LineItem is the cost of one cloud resource over one usage interval.
+// ResourceID is as the provider reports it; use ResourceKey to compare it
+// with Kubernetes provider and volume IDs.
+type LineItem struct {
+	ResourceID string
+	Start      time.Time
+	End        time.Time
+	Cost       float64
+}
+
+// Load reads every line item with a resource ID from the file at path.
+func Load(path, format string) ([]LineItem, error) {
+	switch format {
+	case FormatAWSCUR:
+		if strings.EqualFold(filepath.Ext(path), ".parquet") {
+			return loadAWSParquet(path)
+		}
+		return withFile(path, loadAWSCSV)
+	case FormatGCP:
+		return withFile(path, loadGCP)
+	case FormatAzure:
+		return withFile(path, loadAzure)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// ResourceKey normalizes a cloud resource identifier so that the forms used
+// by billing exports and by Kubernetes compare equal:
+//
+//	aws:///us-east-1a/i-0abc, arn:...:instance/i-0abc, i-0abc   -> i-0abc
+//	aws://us-east-1a/vol-0abc, vol-0abc                         -> vol-0abc
+//	gce://project/zone/name, projects/project/zones/zone/disks/name -> project/zone/name
+//	azure:///subscriptions/..., /subscriptions/...              -> /subscriptions/... (lower case)
+func ResourceKey(id string) string {
+	id = strings.ToLower(strings.TrimSpace(id))
+
+	switch {
+	case strings.HasPrefix(id, "aws://"), strings.HasPrefix(id, "arn:aws:"):
+		return id[strings.LastIndex(id, "/")+1:]
+	case strings.HasPrefix(id, "gce://"):
+		return strings.TrimPrefix(id, "gce://")
+	case strings.HasPrefix(id, "projects/"):
+		parts := strings.Split(id, "/")
+		if len(parts) == 6 {
+			return parts[1] + "/" + parts[3] + "/" + parts[5]
+		}
+	case strings.HasPrefix(id, "azure://"):
+		return strings.TrimPrefix(id, "azure://")
+	}
+	return id
+}
+
+func withFile(path string, load func(r io.Reader) ([]LineItem, error)) ([]LineItem, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.EqualFold(filepath.Ext(path), ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %v", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	items, err := load(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return items, nil
+}
+
+// parseTime accepts the timestamp layouts used by the supported exports.
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05 MST", "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02", "01/02/2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
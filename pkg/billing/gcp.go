@@ -0,0 +1,115 @@
+package billing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// gcpRow is the subset of a GCP detailed billing export row that is needed
+// to attribute its cost to a resource.
+type gcpRow struct {
+	Resource struct {
+		Name string `json:"name"`
+	} `json:"resource"`
+	Project struct {
+		ID string `json:"id"`
+	} `json:"project"`
+	Location struct {
+		Zone   string `json:"zone"`
+		Region string `json:"region"`
+	} `json:"location"`
+	UsageStartTime string    `json:"usage_start_time"`
+	UsageEndTime   string    `json:"usage_end_time"`
+	Cost           flexFloat `json:"cost"`
+	Credits        []struct {
+		Amount flexFloat `json:"amount"`
+	} `json:"credits"`
+}
+
+// flexFloat accepts numbers encoded either as JSON numbers or as strings,
+// since BigQuery exports use both.
+type flexFloat float64
+
+func (f *flexFloat) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	value, err := strconv.ParseFloat(strings.Trim(string(data), `"`), 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %s", data)
+	}
+	*f = flexFloat(value)
+	return nil
+}
+
+// loadGCP reads a billing export as a JSON array or as newline-delimited
+// JSON. A row's cost is its cost plus its (negative) credits.
+func loadGCP(r io.Reader) ([]LineItem, error) {
+	br := bufio.NewReader(r)
+	decoder := json.NewDecoder(br)
+
+	first, err := br.Peek(1)
+	for err == nil && bytes.ContainsAny(first, " \t\r\n") {
+		br.ReadByte()
+		first, err = br.Peek(1)
+	}
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	array := first[0] == '['
+	if array {
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+	}
+
+	var items []LineItem
+	for row := 1; decoder.More(); row++ {
+		var r gcpRow
+		if err := decoder.Decode(&r); err != nil {
+			return nil, fmt.Errorf("row %d: %v", row, err)
+		}
+		if r.Resource.Name == "" {
+			continue
+		}
+
+		start, err := parseTime(r.UsageStartTime)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: usage_start_time: %v", row, err)
+		}
+		end, err := parseTime(r.UsageEndTime)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: usage_end_time: %v", row, err)
+		}
+
+		cost := float64(r.Cost)
+		for _, credit := range r.Credits {
+			cost += float64(credit.Amount)
+		}
+
+		items = append(items, LineItem{ResourceID: gcpResourceID(&r), Start: start, End: end, Cost: cost})
+	}
+	return items, nil
+}
+
+// gcpResourceID returns the resource in the gce://project/location/name
+// form of a GCE provider ID, unless the export already has a full path.
+func gcpResourceID(r *gcpRow) string {
+	if strings.Contains(r.Resource.Name, "/") {
+		return r.Resource.Name
+	}
+	location := r.Location.Zone
+	if location == "" {
+		location = r.Location.Region
+	}
+	return "gce://" + r.Project.ID + "/" + location + "/" + r.Resource.Name
+}
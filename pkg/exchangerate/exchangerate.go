@@ -0,0 +1,161 @@
+// Package exchangerate reads and validates dated exchange rates, which
+// convert costs from the base currency into the currencies they are
+// reported in.
+//
+// CSV files have a header row with the columns from, to, rate and
+// effective_from. A rate of 0.92 from USD to EUR means one US dollar is
+// 0.92 euros. Timestamps are RFC 3339 or YYYY-MM-DD, the latter taking
+// effect at midnight UTC.
+//
+// JSON files hold an array of objects with the fields of
+// internal.ExchangeRate, or one such object per line.
+package exchangerate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+)
+
+var ErrInvalidRate = errors.New("invalid exchange rate")
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ParseCSV reads rates from CSV. Rates are validated and normalized.
+func ParseCSV(r io.Reader) ([]internal.ExchangeRate, error) {
+	cr := csv.NewReader(r)
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %v", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.TrimSpace(name)] = i
+	}
+	for _, column := range []string{"from", "to", "rate", "effective_from"} {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("missing column %s", column)
+		}
+	}
+
+	var rates []internal.ExchangeRate
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		get := func(column string) string {
+			return strings.TrimSpace(record[index[column]])
+		}
+
+		rate := internal.ExchangeRate{
+			From: get("from"),
+			To:   get("to"),
+		}
+		if rate.Rate, err = strconv.ParseFloat(get("rate"), 64); err != nil {
+			return nil, fmt.Errorf("line %d: %w: rate %q is not a number", line, ErrInvalidRate, get("rate"))
+		}
+		if rate.EffectiveFrom, err = parseTime(get("effective_from")); err != nil {
+			return nil, fmt.Errorf("line %d: %w: effective_from: %v", line, ErrInvalidRate, err)
+		}
+
+		if err := Normalize(&rate); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		rates = append(rates, rate)
+	}
+}
+
+// ParseJSON reads rates from a JSON array or from one object per line.
+// Rates are validated and normalized.
+func ParseJSON(r io.Reader) ([]internal.ExchangeRate, error) {
+	br := bufio.NewReader(r)
+	first, err := br.Peek(1)
+	for err == nil && bytes.ContainsAny(first, " \t\r\n") {
+		br.ReadByte()
+		first, err = br.Peek(1)
+	}
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(br)
+	decoder.DisallowUnknownFields()
+	if first[0] == '[' {
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+	}
+
+	var rates []internal.ExchangeRate
+	for i := 1; decoder.More(); i++ {
+		var rate internal.ExchangeRate
+		if err := decoder.Decode(&rate); err != nil {
+			return nil, fmt.Errorf("rate %d: %v", i, err)
+		}
+		if err := Normalize(&rate); err != nil {
+			return nil, fmt.Errorf("rate %d: %v", i, err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+// Normalize upper-cases the currency codes of rate, moves its effective
+// time to UTC and validates it.
+func Normalize(rate *internal.ExchangeRate) error {
+	rate.From = strings.ToUpper(strings.TrimSpace(rate.From))
+	rate.To = strings.ToUpper(strings.TrimSpace(rate.To))
+	switch {
+	case !currencyPattern.MatchString(rate.From):
+		return fmt.Errorf("%w: from %q must be an ISO 4217 code such as USD", ErrInvalidRate, rate.From)
+	case !currencyPattern.MatchString(rate.To):
+		return fmt.Errorf("%w: to %q must be an ISO 4217 code such as EUR", ErrInvalidRate, rate.To)
+	case rate.From == rate.To:
+		return fmt.Errorf("%w: from and to must differ", ErrInvalidRate)
+	case math.IsNaN(rate.Rate) || math.IsInf(rate.Rate, 0) || rate.Rate <= 0:
+		return fmt.Errorf("%w: rate must be a positive number", ErrInvalidRate)
+	case rate.EffectiveFrom.IsZero():
+		return fmt.Errorf("%w: effective_from is required", ErrInvalidRate)
+	}
+
+	rate.EffectiveFrom = rate.EffectiveFrom.UTC()
+	return nil
+}
+
+// Key identifies the rate of a pair from one effective time. A rate with
+// the key of an existing one replaces it.
+func Key(rate internal.ExchangeRate) string {
+	return rate.From + "/" + rate.To + "/" + rate.EffectiveFrom.Format(time.RFC3339)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
@@ -0,0 +1,280 @@
+// Package export writes flattened allocation rows as CSV, NDJSON or
+// Parquet.
+//
+// Each row is the cost of one resource of one pod over one window. The
+// schema is stable: columns are only ever added, never renamed or removed.
+//
+//	column          type       description
+//	window_start    timestamp  start of the window the cost covers
+//	window_end      timestamp  end of the window the cost covers
+//	cluster         string     cluster name
+//	namespace       string     namespace
+//	controller_kind string     owning workload kind, e.g. Deployment; empty for bare pods
+//	controller      string     owning workload name; empty for bare pods
+//	pod             string     pod name
+//	node            string     node the pod runs on
+//	resource        string     cpu, memory, storage, network, ephemeral-storage or an extended resource such as nvidia.com/gpu
+//	usage           double     resource usage in usage_unit; 0 when not measured
+//	usage_unit      string     cores for cpu, bytes for memory and ephemeral-storage, empty otherwise
+//	cost            double     cost of the resource over the window
+//	pricing_period  string     pricing period the cost was incurred in, peak unless a pricing schedule applies
+//	currency        string     ISO 4217 code of the currency of cost
+//
+// A window that spans several pricing periods, such as peak and off-peak,
+// has one row per pod, resource and period.
+//
+// Timestamps are RFC 3339 strings in CSV and NDJSON and TIMESTAMP_MILLIS in
+// Parquet.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+const (
+	TypeString    = "string"
+	TypeDouble    = "double"
+	TypeTimestamp = "timestamp"
+)
+
+// Formats lists the supported export formats.
+var Formats = []string{FormatCSV, FormatNDJSON, FormatParquet}
+
+var (
+	ErrUnknownFormat = errors.New("unknown export format")
+	ErrUnknownColumn = errors.New("unknown export column")
+)
+
+// Column is one field of the export schema.
+type Column struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+
+	value func(row *internal.AllocationRow) any
+}
+
+// Columns is the full export schema in its canonical order.
+var Columns = []Column{
+	{Name: "window_start", Type: TypeTimestamp, Description: "start of the window the cost covers", value: func(r *internal.AllocationRow) any { return r.WindowStart }},
+	{Name: "window_end", Type: TypeTimestamp, Description: "end of the window the cost covers", value: func(r *internal.AllocationRow) any { return r.WindowEnd }},
+	{Name: "cluster", Type: TypeString, Description: "cluster name", value: func(r *internal.AllocationRow) any { return r.Cluster }},
+	{Name: "namespace", Type: TypeString, Description: "namespace", value: func(r *internal.AllocationRow) any { return r.Namespace }},
+	{Name: "controller_kind", Type: TypeString, Description: "owning workload kind, empty for bare pods", value: func(r *internal.AllocationRow) any { return r.ControllerKind }},
+	{Name: "controller", Type: TypeString, Description: "owning workload name, empty for bare pods", value: func(r *internal.AllocationRow) any { return r.Controller }},
+	{Name: "pod", Type: TypeString, Description: "pod name", value: func(r *internal.AllocationRow) any { return r.Pod }},
+	{Name: "node", Type: TypeString, Description: "node the pod runs on", value: func(r *internal.AllocationRow) any { return r.Node }},
+	{Name: "resource", Type: TypeString, Description: "cpu, memory, storage, network, ephemeral-storage or an extended resource such as nvidia.com/gpu", value: func(r *internal.AllocationRow) any { return r.Resource }},
+	{Name: "usage", Type: TypeDouble, Description: "resource usage in usage_unit, 0 when not measured", value: func(r *internal.AllocationRow) any { return r.Usage }},
+	{Name: "usage_unit", Type: TypeString, Description: "cores for cpu, bytes for memory and ephemeral-storage, empty otherwise", value: func(r *internal.AllocationRow) any { return r.UsageUnit }},
+	{Name: "cost", Type: TypeDouble, Description: "cost of the resource over the window", value: func(r *internal.AllocationRow) any { return r.Cost }},
+	{Name: "pricing_period", Type: TypeString, Description: "pricing period the cost was incurred in, peak unless a pricing schedule applies", value: func(r *internal.AllocationRow) any { return r.PricingPeriod }},
+	{Name: "currency", Type: TypeString, Description: "ISO 4217 code of the currency of cost", value: func(r *internal.AllocationRow) any { return r.Currency }},
+}
+
+// ParseColumns resolves a comma-separated list of column names, in the
+// order given. An empty spec selects every column.
+func ParseColumns(spec string) ([]Column, error) {
+	if strings.TrimSpace(spec) == "" {
+		return Columns, nil
+	}
+
+	byName := make(map[string]Column, len(Columns))
+	for _, column := range Columns {
+		byName[column.Name] = column
+	}
+
+	names := strings.Split(spec, ",")
+	columns := make([]Column, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		column, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownColumn, name)
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// ContentType returns the MIME type for format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/vnd.apache.parquet"
+	}
+}
+
+// Writer writes allocation rows in one format. Close must be called to
+// flush buffered rows and, for Parquet, write the footer.
+type Writer interface {
+	Write(row *internal.AllocationRow) error
+	Close() error
+}
+
+func NewWriter(format string, w io.Writer, columns []Column) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, columns)
+	case FormatNDJSON:
+		return &ndjsonWriter{w: w, columns: columns}, nil
+	case FormatParquet:
+		return newParquetWriter(w, columns)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+type csvWriter struct {
+	w       *csv.Writer
+	columns []Column
+	record  []string
+}
+
+func newCSVWriter(w io.Writer, columns []Column) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), columns: columns, record: make([]string, len(columns))}
+
+	for i, column := range columns {
+		cw.record[i] = column.Name
+	}
+	if err := cw.w.Write(cw.record); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) Write(row *internal.AllocationRow) error {
+	for i, column := range cw.columns {
+		switch value := column.value(row).(type) {
+		case string:
+			cw.record[i] = value
+		case float64:
+			cw.record[i] = strconv.FormatFloat(value, 'f', -1, 64)
+		case money.Amount:
+			cw.record[i] = value.String()
+		case time.Time:
+			cw.record[i] = value.UTC().Format(time.RFC3339)
+		}
+	}
+	return cw.w.Write(cw.record)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonWriter struct {
+	w       io.Writer
+	columns []Column
+	buf     []byte
+}
+
+// Write emits the selected columns in schema order, which encoding/json
+// cannot do for a map.
+func (nw *ndjsonWriter) Write(row *internal.AllocationRow) error {
+	nw.buf = append(nw.buf[:0], '{')
+	for i, column := range nw.columns {
+		if i > 0 {
+			nw.buf = append(nw.buf, ',')
+		}
+		nw.buf = strconv.AppendQuote(nw.buf, column.Name)
+		nw.buf = append(nw.buf, ':')
+
+		value := column.value(row)
+		if t, ok := value.(time.Time); ok {
+			value = t.UTC().Format(time.RFC3339)
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		nw.buf = append(nw.buf, encoded...)
+	}
+	nw.buf = append(nw.buf, '}', '\n')
+
+	_, err := nw.w.Write(nw.buf)
+	return err
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nil
+}
+
+// parquetRowGroupSize keeps row groups small so that rows are flushed to
+// the output as the export progresses instead of at the end.
+const parquetRowGroupSize = 8 * 1024 * 1024
+
+type parquetWriter struct {
+	pw      *writer.CSVWriter
+	columns []Column
+}
+
+func newParquetWriter(w io.Writer, columns []Column) (*parquetWriter, error) {
+	metadata := make([]string, len(columns))
+	for i, column := range columns {
+		switch column.Type {
+		case TypeString:
+			metadata[i] = "name=" + column.Name + ", type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"
+		case TypeDouble:
+			metadata[i] = "name=" + column.Name + ", type=DOUBLE"
+		case TypeTimestamp:
+			metadata[i] = "name=" + column.Name + ", type=INT64, convertedtype=TIMESTAMP_MILLIS"
+		}
+	}
+
+	pw, err := writer.NewCSVWriterFromWriter(metadata, w, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parquet writer: %v", err)
+	}
+	pw.RowGroupSize = parquetRowGroupSize
+
+	return &parquetWriter{pw: pw, columns: columns}, nil
+}
+
+func (pw *parquetWriter) Write(row *internal.AllocationRow) error {
+	record := make([]any, len(pw.columns))
+	for i, column := range pw.columns {
+		switch value := column.value(row).(type) {
+		case time.Time:
+			record[i] = value.UnixMilli()
+		case money.Amount:
+			// Parquet has no unbounded decimal type, so amounts are
+			// written as the nearest double.
+			record[i] = value.Float64()
+		default:
+			record[i] = value
+		}
+	}
+	return pw.pw.Write(record)
+}
+
+func (pw *parquetWriter) Close() error {
+	return pw.pw.WriteStop()
+}
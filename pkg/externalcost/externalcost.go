@@ -0,0 +1,195 @@
+// Package externalcost reads and validates cost line items incurred
+// outside the cluster, such as managed databases, object storage or SaaS
+// seats, which are attributed to teams through their labels.
+//
+// CSV files have a header row with the columns id (optional), source,
+// description, cost, start and end. Every column named label:<key> sets
+// the label <key>, so a team column is written label:team. Timestamps are
+// RFC 3339 or YYYY-MM-DD.
+//
+// JSON files hold an array of objects with the fields of
+// internal.ExternalCost, or one such object per line.
+package externalcost
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+)
+
+const labelPrefix = "label:"
+
+var ErrInvalidItem = errors.New("invalid external cost")
+
+// ParseCSV reads items from CSV. Items are validated and given IDs.
+func ParseCSV(r io.Reader) ([]internal.ExternalCost, error) {
+	cr := csv.NewReader(r)
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %v", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.TrimSpace(name)] = i
+	}
+	for _, column := range []string{"source", "cost", "start", "end"} {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("missing column %s", column)
+		}
+	}
+
+	var items []internal.ExternalCost
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		get := func(column string) string {
+			if i, ok := index[column]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		item := internal.ExternalCost{
+			ID:          get("id"),
+			Source:      get("source"),
+			Description: get("description"),
+			Labels:      make(map[string]string),
+		}
+		if item.Cost, err = money.Parse(get("cost")); err != nil {
+			return nil, fmt.Errorf("line %d: %w: cost %q is not a number", line, ErrInvalidItem, get("cost"))
+		}
+		if item.Start, err = parseTime(get("start")); err != nil {
+			return nil, fmt.Errorf("line %d: %w: start: %v", line, ErrInvalidItem, err)
+		}
+		if item.End, err = parseTime(get("end")); err != nil {
+			return nil, fmt.Errorf("line %d: %w: end: %v", line, ErrInvalidItem, err)
+		}
+		for column, i := range index {
+			if key, ok := strings.CutPrefix(column, labelPrefix); ok && record[i] != "" {
+				item.Labels[key] = strings.TrimSpace(record[i])
+			}
+		}
+
+		if err := Normalize(&item); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		items = append(items, item)
+	}
+}
+
+// ParseJSON reads items from a JSON array or from one object per line.
+// Items are validated and given IDs.
+func ParseJSON(r io.Reader) ([]internal.ExternalCost, error) {
+	br := bufio.NewReader(r)
+	first, err := br.Peek(1)
+	for err == nil && bytes.ContainsAny(first, " \t\r\n") {
+		br.ReadByte()
+		first, err = br.Peek(1)
+	}
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(br)
+	decoder.DisallowUnknownFields()
+	if first[0] == '[' {
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+	}
+
+	var items []internal.ExternalCost
+	for i := 1; decoder.More(); i++ {
+		var item internal.ExternalCost
+		if err := decoder.Decode(&item); err != nil {
+			return nil, fmt.Errorf("item %d: %v", i, err)
+		}
+		if err := Normalize(&item); err != nil {
+			return nil, fmt.Errorf("item %d: %v", i, err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Normalize validates item and, when it has no ID, sets one derived from
+// its contents so that ingesting the same item twice replaces it.
+func Normalize(item *internal.ExternalCost) error {
+	switch {
+	case item.Source == "":
+		return fmt.Errorf("%w: source is required", ErrInvalidItem)
+	case item.Start.IsZero() || item.End.IsZero():
+		return fmt.Errorf("%w: start and end are required", ErrInvalidItem)
+	case !item.End.After(item.Start):
+		return fmt.Errorf("%w: end must be after start", ErrInvalidItem)
+	}
+	for key := range item.Labels {
+		if key == "" {
+			return fmt.Errorf("%w: label keys must not be empty", ErrInvalidItem)
+		}
+	}
+
+	item.Start, item.End = item.Start.UTC(), item.End.UTC()
+	if item.Labels == nil {
+		item.Labels = make(map[string]string)
+	}
+	if item.ID == "" {
+		item.ID = contentID(item)
+	}
+	return nil
+}
+
+// HourlyCost spreads an item's cost evenly over its time range, in the
+// same unit as in-cluster allocations.
+func HourlyCost(item internal.ExternalCost) money.Amount {
+	return item.Cost.Div(item.End.Sub(item.Start).Hours())
+}
+
+func contentID(item *internal.ExternalCost) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00", item.Source, item.Description, item.Start.Format(time.RFC3339Nano), item.End.Format(time.RFC3339Nano))
+
+	keys := make([]string, 0, len(item.Labels))
+	for key := range item.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(h, "%s=%s\x00", key, item.Labels[key])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
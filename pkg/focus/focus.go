@@ -0,0 +1,140 @@
+// Package focus defines cost records in the FinOps Open Cost and Usage
+// Specification (FOCUS) 1.0 format and billing period helpers.
+package focus
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+)
+
+// Record is one FOCUS charge row. Field names follow the specification's
+// column names; nullable columns are pointers and are null when KuBudget
+// has no value for them.
+type Record struct {
+	AvailabilityZone    *string           `json:"AvailabilityZone"`
+	BilledCost          money.Amount      `json:"BilledCost"`
+	BillingAccountId    string            `json:"BillingAccountId"`
+	BillingAccountName  string            `json:"BillingAccountName"`
+	BillingCurrency     string            `json:"BillingCurrency"`
+	BillingPeriodEnd    time.Time         `json:"BillingPeriodEnd"`
+	BillingPeriodStart  time.Time         `json:"BillingPeriodStart"`
+	ChargeCategory      string            `json:"ChargeCategory"`
+	ChargeClass         *string           `json:"ChargeClass"`
+	ChargeDescription   string            `json:"ChargeDescription"`
+	ChargeFrequency     string            `json:"ChargeFrequency"`
+	ChargePeriodEnd     time.Time         `json:"ChargePeriodEnd"`
+	ChargePeriodStart   time.Time         `json:"ChargePeriodStart"`
+	ConsumedQuantity    *float64          `json:"ConsumedQuantity"`
+	ConsumedUnit        *string           `json:"ConsumedUnit"`
+	ContractedCost      money.Amount      `json:"ContractedCost"`
+	ContractedUnitPrice *money.Amount     `json:"ContractedUnitPrice"`
+	EffectiveCost       money.Amount      `json:"EffectiveCost"`
+	InvoiceIssuerName   string            `json:"InvoiceIssuerName"`
+	ListCost            money.Amount      `json:"ListCost"`
+	ListUnitPrice       *money.Amount     `json:"ListUnitPrice"`
+	PricingCategory     string            `json:"PricingCategory"`
+	PricingQuantity     *float64          `json:"PricingQuantity"`
+	PricingUnit         *string           `json:"PricingUnit"`
+	ProviderName        string            `json:"ProviderName"`
+	PublisherName       string            `json:"PublisherName"`
+	RegionId            *string           `json:"RegionId"`
+	RegionName          *string           `json:"RegionName"`
+	ResourceId          string            `json:"ResourceId"`
+	ResourceName        string            `json:"ResourceName"`
+	ResourceType        string            `json:"ResourceType"`
+	ServiceCategory     string            `json:"ServiceCategory"`
+	ServiceName         string            `json:"ServiceName"`
+	SkuId               string            `json:"SkuId"`
+	SkuPriceId          string            `json:"SkuPriceId"`
+	SubAccountId        string            `json:"SubAccountId"`
+	SubAccountName      string            `json:"SubAccountName"`
+	Tags                map[string]string `json:"Tags"`
+}
+
+// Columns is the CSV header, in the same order as Record.CSV.
+var Columns = []string{
+	"AvailabilityZone", "BilledCost", "BillingAccountId", "BillingAccountName", "BillingCurrency",
+	"BillingPeriodEnd", "BillingPeriodStart", "ChargeCategory", "ChargeClass", "ChargeDescription",
+	"ChargeFrequency", "ChargePeriodEnd", "ChargePeriodStart", "ConsumedQuantity", "ConsumedUnit",
+	"ContractedCost", "ContractedUnitPrice", "EffectiveCost", "InvoiceIssuerName", "ListCost",
+	"ListUnitPrice", "PricingCategory", "PricingQuantity", "PricingUnit", "ProviderName",
+	"PublisherName", "RegionId", "RegionName", "ResourceId", "ResourceName",
+	"ResourceType", "ServiceCategory", "ServiceName", "SkuId", "SkuPriceId",
+	"SubAccountId", "SubAccountName", "Tags",
+}
+
+// CSV renders r as a CSV record. Nulls are empty fields, timestamps are
+// RFC 3339 in UTC and Tags is a JSON object.
+func (r *Record) CSV() ([]string, error) {
+	tags, err := json.Marshal(r.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{
+		stringOrEmpty(r.AvailabilityZone), r.BilledCost.String(), r.BillingAccountId, r.BillingAccountName, r.BillingCurrency,
+		formatTime(r.BillingPeriodEnd), formatTime(r.BillingPeriodStart), r.ChargeCategory, stringOrEmpty(r.ChargeClass), r.ChargeDescription,
+		r.ChargeFrequency, formatTime(r.ChargePeriodEnd), formatTime(r.ChargePeriodStart), floatOrEmpty(r.ConsumedQuantity), stringOrEmpty(r.ConsumedUnit),
+		r.ContractedCost.String(), amountOrEmpty(r.ContractedUnitPrice), r.EffectiveCost.String(), r.InvoiceIssuerName, r.ListCost.String(),
+		amountOrEmpty(r.ListUnitPrice), r.PricingCategory, floatOrEmpty(r.PricingQuantity), stringOrEmpty(r.PricingUnit), r.ProviderName,
+		r.PublisherName, stringOrEmpty(r.RegionId), stringOrEmpty(r.RegionName), r.ResourceId, r.ResourceName,
+		r.ResourceType, r.ServiceCategory, r.ServiceName, r.SkuId, r.SkuPriceId,
+		r.SubAccountId, r.SubAccountName, string(tags),
+	}, nil
+}
+
+// BillingPeriod returns the calendar month, in UTC, that contains t. The
+// end is exclusive.
+func BillingPeriod(t time.Time) (start, end time.Time) {
+	t = t.UTC()
+	start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// PeriodName formats the billing period containing t as YYYY-MM.
+func PeriodName(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// ParsePeriod parses a YYYY-MM billing period name.
+func ParsePeriod(name string) (start, end time.Time, err error) {
+	t, err := time.Parse("2006-01", name)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid billing period %q, expected YYYY-MM", name)
+	}
+	start, end = BillingPeriod(t)
+	return start, end, nil
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func floatOrEmpty(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return formatFloat(*f)
+}
+
+func amountOrEmpty(a *money.Amount) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
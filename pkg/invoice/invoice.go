@@ -0,0 +1,163 @@
+// Package invoice defines chargeback invoices and renders them as JSON,
+// CSV, HTML and PDF.
+//
+// An invoice bills one cost center for one monthly billing period. Once
+// sealed it carries a SHA-256 hash of its contents, so that any change made
+// to a closed invoice after the fact is detected when it is read back.
+package invoice
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+)
+
+// Line item categories, in the order they are presented.
+const (
+	CategoryUsage      = "usage"
+	CategoryShared     = "shared"
+	CategoryIdle       = "idle"
+	CategoryDiscount   = "discount"
+	CategoryAdjustment = "adjustment"
+)
+
+var ErrHashMismatch = errors.New("invoice content hash mismatch")
+
+// Invoice is the chargeback of one cost center for one billing period.
+// Every amount is rounded to Precision decimal places, and the totals are
+// the sums of the line items of their category.
+type Invoice struct {
+	Number      string       `json:"number"`
+	CostCenter  string       `json:"cost_center"`
+	Period      string       `json:"period"`
+	PeriodStart time.Time    `json:"period_start"`
+	PeriodEnd   time.Time    `json:"period_end"`
+	Issuer      string       `json:"issuer"`
+	Account     string       `json:"account"`
+	Currency    string       `json:"currency"`
+	Precision   int32        `json:"precision"`
+	IssuedAt    time.Time    `json:"issued_at"`
+	LineItems   []LineItem   `json:"line_items"`
+	Subtotal    money.Amount `json:"subtotal"`
+	SharedCost  money.Amount `json:"shared_cost"`
+	IdleCost    money.Amount `json:"idle_cost"`
+	Discount    money.Amount `json:"discount"`
+	Adjustments money.Amount `json:"adjustments"`
+	Total       money.Amount `json:"total"`
+	ContentHash string       `json:"content_hash"`
+}
+
+// LineItem is one charge of an invoice. Usage items are the cost of one
+// resource of one workload; shared and idle items are the cost center's
+// share of a pool; discounts and adjustments are negative or positive
+// corrections.
+type LineItem struct {
+	Category    string       `json:"category"`
+	Namespace   string       `json:"namespace,omitempty"`
+	Workload    string       `json:"workload,omitempty"`
+	Resource    string       `json:"resource,omitempty"`
+	Description string       `json:"description"`
+	Amount      money.Amount `json:"amount"`
+}
+
+// Adjustment is a manual credit (negative) or charge (positive) to a cost
+// center, added to its invoice when the period is closed.
+type Adjustment struct {
+	ID          string       `json:"id"`
+	Period      string       `json:"period"`
+	CostCenter  string       `json:"cost_center"`
+	Description string       `json:"description"`
+	Amount      money.Amount `json:"amount"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// Summary identifies an invoice and its total, for listings.
+type Summary struct {
+	Number      string       `json:"number"`
+	CostCenter  string       `json:"cost_center"`
+	Period      string       `json:"period"`
+	Currency    string       `json:"currency"`
+	Total       money.Amount `json:"total"`
+	IssuedAt    time.Time    `json:"issued_at"`
+	ContentHash string       `json:"content_hash"`
+}
+
+// Number formats the seq-th invoice number of a YYYY-MM billing period,
+// e.g. INV-202609-0003.
+func Number(prefix, period string, seq int) string {
+	return fmt.Sprintf("%s-%s-%04d", prefix, strings.ReplaceAll(period, "-", ""), seq)
+}
+
+// Seal totals inv's line items by category and sets its content hash.
+func (inv *Invoice) Seal() error {
+	inv.Subtotal, inv.SharedCost, inv.IdleCost = money.Zero, money.Zero, money.Zero
+	inv.Discount, inv.Adjustments = money.Zero, money.Zero
+	for _, item := range inv.LineItems {
+		switch item.Category {
+		case CategoryUsage:
+			inv.Subtotal = inv.Subtotal.Add(item.Amount)
+		case CategoryShared:
+			inv.SharedCost = inv.SharedCost.Add(item.Amount)
+		case CategoryIdle:
+			inv.IdleCost = inv.IdleCost.Add(item.Amount)
+		case CategoryDiscount:
+			inv.Discount = inv.Discount.Add(item.Amount)
+		case CategoryAdjustment:
+			inv.Adjustments = inv.Adjustments.Add(item.Amount)
+		default:
+			return fmt.Errorf("unknown line item category %q", item.Category)
+		}
+	}
+	inv.Total = money.Sum(inv.Subtotal, inv.SharedCost, inv.IdleCost, inv.Discount, inv.Adjustments)
+
+	hash, err := Hash(inv)
+	if err != nil {
+		return err
+	}
+	inv.ContentHash = hash
+	return nil
+}
+
+// Hash returns the hex SHA-256 of inv's JSON encoding without its content
+// hash.
+func Hash(inv *Invoice) (string, error) {
+	unsealed := *inv
+	unsealed.ContentHash = ""
+	data, err := json.Marshal(unsealed)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// Verify checks that inv's contents still match its content hash.
+func Verify(inv *Invoice) error {
+	hash, err := Hash(inv)
+	if err != nil {
+		return err
+	}
+	if hash != inv.ContentHash {
+		return fmt.Errorf("%w: %s", ErrHashMismatch, inv.Number)
+	}
+	return nil
+}
+
+// Summary returns inv's listing entry.
+func (inv *Invoice) Summary() Summary {
+	return Summary{
+		Number:      inv.Number,
+		CostCenter:  inv.CostCenter,
+		Period:      inv.Period,
+		Currency:    inv.Currency,
+		Total:       inv.Total,
+		IssuedAt:    inv.IssuedAt,
+		ContentHash: inv.ContentHash,
+	}
+}
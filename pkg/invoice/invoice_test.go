@@ -0,0 +1,124 @@
+package invoice
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+)
+
+// TestVerifyRoundTrip checks that a sealed invoice still verifies after it
+// is written as JSON and read back, which is how closed invoices are
+// stored.
+func TestVerifyRoundTrip(t *testing.T) {
+	inv := testInvoice(t)
+	if err := Verify(inv); err != nil {
+		t.Fatalf("Verify after Seal: %v", err)
+	}
+
+	data, err := json.Marshal(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read Invoice
+	if err := json.Unmarshal(data, &read); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(&read); err != nil {
+		t.Fatalf("Verify after a JSON round trip: %v", err)
+	}
+	if !read.Total.Equal(inv.Total) {
+		t.Errorf("total after a JSON round trip = %s, want %s", read.Total, inv.Total)
+	}
+}
+
+// TestVerifyTampered checks that changing any field of any line item, or a
+// total, is detected.
+func TestVerifyTampered(t *testing.T) {
+	tamper := map[string]func(inv *Invoice){
+		"category":    func(inv *Invoice) { inv.LineItems[0].Category = CategoryShared },
+		"namespace":   func(inv *Invoice) { inv.LineItems[0].Namespace = "other" },
+		"workload":    func(inv *Invoice) { inv.LineItems[0].Workload = "Deployment/other" },
+		"resource":    func(inv *Invoice) { inv.LineItems[0].Resource = "memory" },
+		"description": func(inv *Invoice) { inv.LineItems[2].Description = "Share of nothing" },
+		"amount":      func(inv *Invoice) { inv.LineItems[1].Amount = inv.LineItems[1].Amount.Add(money.FromFloat(0.01)) },
+		"removed":     func(inv *Invoice) { inv.LineItems = inv.LineItems[1:] },
+		"added": func(inv *Invoice) {
+			inv.LineItems = append(inv.LineItems, LineItem{Category: CategoryAdjustment, Description: "credit", Amount: money.FromFloat(-1)})
+		},
+		"total":       func(inv *Invoice) { inv.Total = inv.Total.Sub(money.FromFloat(1)) },
+		"cost center": func(inv *Invoice) { inv.CostCenter = "finance" },
+	}
+
+	for name, change := range tamper {
+		inv := testInvoice(t)
+		change(inv)
+		if err := Verify(inv); !errors.Is(err, ErrHashMismatch) {
+			t.Errorf("%s: Verify = %v, want %v", name, err, ErrHashMismatch)
+		}
+	}
+}
+
+func TestSealTotals(t *testing.T) {
+	inv := testInvoice(t)
+	tests := []struct {
+		name string
+		got  money.Amount
+		want string
+	}{
+		{"subtotal", inv.Subtotal, "4.00"},
+		{"shared", inv.SharedCost, "0.75"},
+		{"idle", inv.IdleCost, "1.25"},
+		{"discount", inv.Discount, "-0.60"},
+		{"adjustments", inv.Adjustments, "-1.00"},
+		{"total", inv.Total, "4.40"},
+	}
+	for _, tt := range tests {
+		want, _ := money.Parse(tt.want)
+		if !tt.got.Equal(want) {
+			t.Errorf("%s = %s, want %s", tt.name, tt.got, tt.want)
+		}
+	}
+
+	inv.LineItems = append(inv.LineItems, LineItem{Category: "tax"})
+	if err := inv.Seal(); err == nil {
+		t.Error("Seal accepted an unknown category")
+	}
+}
+
+func testInvoice(t *testing.T) *Invoice {
+	t.Helper()
+	amount := func(s string) money.Amount {
+		a, err := money.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+
+	inv := &Invoice{
+		Number:      Number("INV", "2026-09", 1),
+		CostCenter:  "platform",
+		Period:      "2026-09",
+		PeriodStart: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:   time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		Issuer:      "Platform Engineering",
+		Currency:    "USD",
+		Precision:   2,
+		IssuedAt:    time.Date(2026, 10, 2, 3, 4, 5, 0, time.UTC),
+		LineItems: []LineItem{
+			{Category: CategoryUsage, Namespace: "api", Workload: "Deployment/api", Resource: "cpu", Description: "cpu of api/Deployment/api", Amount: amount("2.50")},
+			{Category: CategoryUsage, Namespace: "api", Workload: "Deployment/api", Resource: "memory", Description: "memory of api/Deployment/api", Amount: amount("1.50")},
+			{Category: CategoryShared, Description: "Share of shared namespace monitoring", Amount: amount("0.75")},
+			{Category: CategoryIdle, Description: "Share of idle node capacity", Amount: amount("1.25")},
+			{Category: CategoryDiscount, Description: "10% discount", Amount: amount("-0.60")},
+			{Category: CategoryAdjustment, Description: "Credit for the outage", Amount: amount("-1.00")},
+		},
+	}
+	if err := inv.Seal(); err != nil {
+		t.Fatal(err)
+	}
+	return inv
+}
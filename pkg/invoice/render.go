@@ -0,0 +1,239 @@
+package invoice
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Formats lists the supported invoice formats.
+var Formats = []string{"json", "csv", "html", "pdf"}
+
+// CSVColumns is the CSV header. There is one row per line item; the totals
+// follow as rows whose category is "total".
+var CSVColumns = []string{
+	"invoice_number", "cost_center", "period", "currency", "category",
+	"namespace", "workload", "resource", "description", "amount",
+}
+
+// ContentType returns the MIME type for format.
+func ContentType(format string) string {
+	switch format {
+	case "csv":
+		return "text/csv; charset=utf-8"
+	case "html":
+		return "text/html; charset=utf-8"
+	case "pdf":
+		return "application/pdf"
+	default:
+		return "application/json"
+	}
+}
+
+// Render writes inv to w in format, one of Formats.
+func Render(w io.Writer, format string, inv *Invoice) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(inv)
+	case "csv":
+		return writeCSV(w, inv)
+	case "html":
+		return htmlTemplate.Execute(w, struct {
+			*Invoice
+			Totals []total
+		}{inv, inv.totals()})
+	case "pdf":
+		return writePDF(w, inv)
+	default:
+		return fmt.Errorf("unsupported invoice format %q", format)
+	}
+}
+
+// total is one row of an invoice's totals.
+type total struct {
+	Label  string
+	Amount money.Amount
+}
+
+func (inv *Invoice) totals() []total {
+	return []total{
+		{"Subtotal", inv.Subtotal},
+		{"Shared costs", inv.SharedCost},
+		{"Idle capacity", inv.IdleCost},
+		{"Discount", inv.Discount},
+		{"Adjustments", inv.Adjustments},
+		{"Total", inv.Total},
+	}
+}
+
+// format presents amount with exactly the invoice's decimal places. Amounts
+// are already rounded, so this only pads them.
+func (inv *Invoice) format(amount money.Amount) string {
+	return amount.StringFixed(inv.Precision)
+}
+
+func writeCSV(w io.Writer, inv *Invoice) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CSVColumns); err != nil {
+		return err
+	}
+	for _, item := range inv.LineItems {
+		if err := cw.Write([]string{
+			inv.Number, inv.CostCenter, inv.Period, inv.Currency, item.Category,
+			item.Namespace, item.Workload, item.Resource, item.Description, inv.format(item.Amount),
+		}); err != nil {
+			return err
+		}
+	}
+	for _, t := range inv.totals() {
+		if err := cw.Write([]string{
+			inv.Number, inv.CostCenter, inv.Period, inv.Currency, "total",
+			"", "", "", t.Label, inv.format(t.Amount),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+var htmlTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"date":  func(t time.Time) string { return t.UTC().Format("2006-01-02") },
+	"fixed": func(amount money.Amount, places int32) string { return amount.StringFixed(places) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Number}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-top: 1em; }
+th, td { border-bottom: 1px solid #ddd; padding: 4px 8px; text-align: left; }
+td.amount, th.amount { text-align: right; font-variant-numeric: tabular-nums; }
+tr.total td { font-weight: bold; border-top: 2px solid #222; }
+.hash { font-family: monospace; font-size: 0.8em; color: #666; }
+</style>
+</head>
+<body>
+<h1>Invoice {{.Number}}</h1>
+<p>
+Cost center: <strong>{{.CostCenter}}</strong><br>
+Billing period: {{.Period}} ({{date .PeriodStart}} to {{date .PeriodEnd}}, end exclusive)<br>
+{{if .Account}}Account: {{.Account}}<br>{{end}}
+Issued by {{.Issuer}} on {{date .IssuedAt}}<br>
+Currency: {{.Currency}}
+</p>
+<table>
+<thead><tr><th>Category</th><th>Namespace</th><th>Workload</th><th>Resource</th><th class="amount">Amount</th></tr></thead>
+<tbody>
+{{range .LineItems}}{{if eq .Category "usage"}}<tr><td>{{.Category}}</td><td>{{.Namespace}}</td><td>{{.Workload}}</td><td>{{.Resource}}</td>{{else}}<tr><td>{{.Category}}</td><td colspan="3">{{.Description}}</td>{{end}}<td class="amount">{{fixed .Amount $.Precision}}</td></tr>
+{{end}}</tbody>
+</table>
+<table>
+{{range .Totals}}<tr{{if eq .Label "Total"}} class="total"{{end}}><td>{{.Label}}</td><td class="amount">{{fixed .Amount $.Precision}}</td></tr>
+{{end}}</table>
+<p class="hash">Content hash (SHA-256): {{.ContentHash}}</p>
+</body>
+</html>
+`))
+
+// PDF column widths in millimetres; the page body of A4 with 15mm margins
+// is 180mm wide.
+var pdfColumns = []struct {
+	title string
+	width float64
+}{
+	{"Category", 24}, {"Namespace", 36}, {"Workload", 56}, {"Resource", 32}, {"Amount", 32},
+}
+
+func writePDF(w io.Writer, inv *Invoice) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetTitle("Invoice "+inv.Number, true)
+	pdf.SetCreator(inv.Issuer, true)
+	// Pin the document dates so that rendering an invoice twice gives the
+	// same file.
+	pdf.SetCreationDate(inv.IssuedAt)
+	pdf.SetModificationDate(inv.IssuedAt)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	text := func(width float64, s string) string {
+		s = tr(s)
+		for len(s) > 0 && pdf.GetStringWidth(s) > width-2 {
+			s = s[:len(s)-1]
+		}
+		return s
+	}
+
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, tr("Invoice "+inv.Number), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	header := []string{
+		"Cost center: " + inv.CostCenter,
+		fmt.Sprintf("Billing period: %s (%s to %s, end exclusive)", inv.Period,
+			inv.PeriodStart.UTC().Format("2006-01-02"), inv.PeriodEnd.UTC().Format("2006-01-02")),
+	}
+	if inv.Account != "" {
+		header = append(header, "Account: "+inv.Account)
+	}
+	header = append(header,
+		fmt.Sprintf("Issued by %s on %s", inv.Issuer, inv.IssuedAt.UTC().Format("2006-01-02")),
+		"Currency: "+inv.Currency,
+	)
+	for _, line := range header {
+		pdf.CellFormat(0, 5, tr(line), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	pdf.SetFont("Helvetica", "B", 9)
+	for i, column := range pdfColumns {
+		align := "L"
+		if i == len(pdfColumns)-1 {
+			align = "R"
+		}
+		pdf.CellFormat(column.width, 6, column.title, "B", 0, align, false, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 8)
+	described := pdfColumns[1].width + pdfColumns[2].width + pdfColumns[3].width
+	for _, item := range inv.LineItems {
+		pdf.CellFormat(pdfColumns[0].width, 5, item.Category, "", 0, "L", false, 0, "")
+		if item.Category == CategoryUsage {
+			pdf.CellFormat(pdfColumns[1].width, 5, text(pdfColumns[1].width, item.Namespace), "", 0, "L", false, 0, "")
+			pdf.CellFormat(pdfColumns[2].width, 5, text(pdfColumns[2].width, item.Workload), "", 0, "L", false, 0, "")
+			pdf.CellFormat(pdfColumns[3].width, 5, text(pdfColumns[3].width, item.Resource), "", 0, "L", false, 0, "")
+		} else {
+			pdf.CellFormat(described, 5, text(described, item.Description), "", 0, "L", false, 0, "")
+		}
+		pdf.CellFormat(pdfColumns[4].width, 5, inv.format(item.Amount), "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	label := 180 - pdfColumns[4].width
+	for _, t := range inv.totals() {
+		border := ""
+		if t.Label == "Total" {
+			pdf.SetFont("Helvetica", "B", 10)
+			border = "T"
+		} else {
+			pdf.SetFont("Helvetica", "", 9)
+		}
+		pdf.CellFormat(label, 6, t.Label, border, 0, "R", false, 0, "")
+		pdf.CellFormat(pdfColumns[4].width, 6, inv.format(t.Amount), border, 1, "R", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	pdf.SetFont("Courier", "", 7)
+	pdf.CellFormat(0, 4, "Content hash (SHA-256): "+inv.ContentHash, "", 1, "L", false, 0, "")
+
+	return pdf.Output(w)
+}
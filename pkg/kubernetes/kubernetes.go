@@ -2,9 +2,17 @@ package kubernetes
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"path/filepath"
+	"time"
 
+	"github.com/SinghaAnirban005/KuBudget/pkg/observability"
+	"go.opentelemetry.io/otel/attribute"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -15,26 +23,49 @@ import (
 	metricsclientset "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
+// ErrUnauthenticated is returned by ReviewToken when the API server rejects
+// the token, as opposed to failing to review it.
+var ErrUnauthenticated = errors.New("token not authenticated")
+
 type Client struct {
 	clientset        *kubernetes.Clientset
 	metricsClientset *metricsclientset.Clientset
+	logger           *slog.Logger
+}
+
+// Options selects how to connect to a cluster. With InCluster set the pod's
+// service account is used. Otherwise KubeConfigPath (default ~/.kube/config)
+// is loaded and Context, if set, picks the context within it; when no context
+// is requested and the kubeconfig cannot be loaded, in-cluster config is
+// tried as a fallback.
+type Options struct {
+	KubeConfigPath string
+	Context        string
+	InCluster      bool
 }
 
-func NewClient(kubeConfigPath string) (*Client, error) {
+func NewClient(opts Options, logger *slog.Logger) (*Client, error) {
 	var config *rest.Config
 	var err error
 
-	if kubeConfigPath == "" {
+	kubeConfigPath := opts.KubeConfigPath
+	if kubeConfigPath == "" && !opts.InCluster {
 		home := homedir.HomeDir()
 		if home != "" {
 			kubeConfigPath = filepath.Join(home, ".kube", "config")
 		}
 	}
 
-	if kubeConfigPath != "" {
-		config, err = clientcmd.BuildConfigFromFlags("", kubeConfigPath)
+	if kubeConfigPath != "" && !opts.InCluster {
+		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeConfigPath},
+			&clientcmd.ConfigOverrides{CurrentContext: opts.Context},
+		).ClientConfig()
 		if err != nil {
-			fmt.Printf("Failed to load kubeconfig at %s: %v\n", kubeConfigPath, err)
+			if opts.Context != "" {
+				return nil, fmt.Errorf("failed to load context %q from kubeconfig %s: %v", opts.Context, kubeConfigPath, err)
+			}
+			logger.Warn("failed to load kubeconfig, falling back to in-cluster config", "path", kubeConfigPath, "error", err)
 		}
 	}
 
@@ -45,7 +76,7 @@ func NewClient(kubeConfigPath string) (*Client, error) {
 		}
 	}
 
-	fmt.Printf("Using Kubernetes API server at: %s\n", config.Host)
+	logger.Info("using Kubernetes API server", "host", config.Host)
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -60,37 +91,141 @@ func NewClient(kubeConfigPath string) (*Client, error) {
 	return &Client{
 		clientset:        clientset,
 		metricsClientset: metricsClientset,
+		logger:           logger,
 	}, nil
 }
 
-func (c *Client) GetPods(namespace string) (*corev1.PodList, error) {
-	return c.clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+func (c *Client) GetPods(ctx context.Context, namespace string) (*corev1.PodList, error) {
+	return observe(ctx, "pods", "list", func(ctx context.Context) (*corev1.PodList, error) {
+		return c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	})
 }
 
-func (c *Client) GetNodes() (*corev1.NodeList, error) {
-	return c.clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+func (c *Client) GetNodes(ctx context.Context) (*corev1.NodeList, error) {
+	return observe(ctx, "nodes", "list", func(ctx context.Context) (*corev1.NodeList, error) {
+		return c.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	})
 }
 
-func (c *Client) GetNamespaces() (*corev1.NamespaceList, error) {
-	return c.clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+func (c *Client) GetNamespaces(ctx context.Context) (*corev1.NamespaceList, error) {
+	return observe(ctx, "namespaces", "list", func(ctx context.Context) (*corev1.NamespaceList, error) {
+		return c.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	})
 }
 
-func (c *Client) GetPodMetrics(namespace string) (*metricsv1beta1.PodMetricsList, error) {
-	return c.metricsClientset.MetricsV1beta1().PodMetricses(namespace).List(context.TODO(), metav1.ListOptions{})
+func (c *Client) GetPodMetrics(ctx context.Context, namespace string) (*metricsv1beta1.PodMetricsList, error) {
+	return observe(ctx, "pods.metrics.k8s.io", "list", func(ctx context.Context) (*metricsv1beta1.PodMetricsList, error) {
+		return c.metricsClientset.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{})
+	})
 }
 
-func (c *Client) GetNodeMetrics() (*metricsv1beta1.NodeMetricsList, error) {
-	return c.metricsClientset.MetricsV1beta1().NodeMetricses().List(context.TODO(), metav1.ListOptions{})
+func (c *Client) GetNodeMetrics(ctx context.Context) (*metricsv1beta1.NodeMetricsList, error) {
+	return observe(ctx, "nodes.metrics.k8s.io", "list", func(ctx context.Context) (*metricsv1beta1.NodeMetricsList, error) {
+		return c.metricsClientset.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{})
+	})
+}
+
+func (c *Client) GetServices(ctx context.Context, namespace string) (*corev1.ServiceList, error) {
+	return observe(ctx, "services", "list", func(ctx context.Context) (*corev1.ServiceList, error) {
+		return c.clientset.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	})
+}
+
+func (c *Client) GetPersistentVolumes(ctx context.Context) (*corev1.PersistentVolumeList, error) {
+	return observe(ctx, "persistentvolumes", "list", func(ctx context.Context) (*corev1.PersistentVolumeList, error) {
+		return c.clientset.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	})
+}
+
+func (c *Client) GetPersistentVolumeClaims(ctx context.Context, namespace string) (*corev1.PersistentVolumeClaimList, error) {
+	return observe(ctx, "persistentvolumeclaims", "list", func(ctx context.Context) (*corev1.PersistentVolumeClaimList, error) {
+		return c.clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	})
+}
+
+// ReviewToken validates a bearer token with a TokenReview and returns the
+// user it belongs to. An invalid token is an error.
+func (c *Client) ReviewToken(ctx context.Context, token string, audiences []string) (*authenticationv1.UserInfo, error) {
+	review, err := observe(ctx, "tokenreviews", "create", func(ctx context.Context) (*authenticationv1.TokenReview, error) {
+		return c.clientset.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+			Spec: authenticationv1.TokenReviewSpec{
+				Token:     token,
+				Audiences: audiences,
+			},
+		}, metav1.CreateOptions{})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !review.Status.Authenticated {
+		if review.Status.Error != "" {
+			return nil, fmt.Errorf("%w: %s", ErrUnauthenticated, review.Status.Error)
+		}
+		return nil, ErrUnauthenticated
+	}
+
+	return &review.Status.User, nil
+}
+
+// CanListPods asks the API server, with a SubjectAccessReview, whether user
+// may list pods in namespace. An empty namespace asks about all namespaces.
+func (c *Client) CanListPods(ctx context.Context, user *authenticationv1.UserInfo, namespace string) (bool, error) {
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for key, values := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(values)
+	}
+
+	review, err := observe(ctx, "subjectaccessreviews", "create", func(ctx context.Context) (*authorizationv1.SubjectAccessReview, error) {
+		return c.clientset.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:   user.Username,
+				UID:    user.UID,
+				Groups: user.Groups,
+				Extra:  extra,
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: namespace,
+					Verb:      "list",
+					Resource:  "pods",
+				},
+			},
+		}, metav1.CreateOptions{})
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return review.Status.Allowed, nil
 }
 
-func (c *Client) GetServices(namespace string) (*corev1.ServiceList, error) {
-	return c.clientset.CoreV1().Services(namespace).List(context.TODO(), metav1.ListOptions{})
+// ServerVersion probes the API server's discovery endpoint.
+func (c *Client) ServerVersion(ctx context.Context) error {
+	_, err := observe(ctx, "version", "get", func(ctx context.Context) ([]byte, error) {
+		return c.clientset.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Raw()
+	})
+	return err
 }
 
-func (c *Client) GetPersistentVolumes() (*corev1.PersistentVolumeList, error) {
-	return c.clientset.CoreV1().PersistentVolumes().List(context.TODO(), metav1.ListOptions{})
+// MetricsAPIAvailable reports whether metrics-server is registered and
+// serving the metrics.k8s.io API.
+func (c *Client) MetricsAPIAvailable(ctx context.Context) error {
+	_, err := observe(ctx, "metrics.k8s.io", "get", func(ctx context.Context) ([]byte, error) {
+		return c.clientset.Discovery().RESTClient().Get().AbsPath("/apis/metrics.k8s.io/v1beta1").Do(ctx).Raw()
+	})
+	return err
 }
 
-func (c *Client) GetPersistentVolumeClaims(namespace string) (*corev1.PersistentVolumeClaimList, error) {
-	return c.clientset.CoreV1().PersistentVolumeClaims(namespace).List(context.TODO(), metav1.ListOptions{})
+// observe traces a single Kubernetes API call and records its latency and
+// outcome.
+func observe[T any](ctx context.Context, resource, verb string, call func(ctx context.Context) (T, error)) (result T, err error) {
+	ctx, span := observability.StartSpan(ctx, "kubernetes."+verb+" "+resource,
+		attribute.String("k8s.resource", resource),
+		attribute.String("k8s.verb", verb),
+	)
+	defer func(start time.Time) {
+		observability.ObserveKubernetesRequest(resource, verb, start, err)
+		observability.EndSpan(span, err)
+	}(time.Now())
+
+	return call(ctx)
 }
@@ -0,0 +1,246 @@
+// Package money represents costs as exact decimal amounts. Summing and
+// scaling amounts never introduces the rounding error of binary floating
+// point, so a total always equals the sum of its line items. Amounts keep
+// their full precision until they are presented, when a Rounding reduces
+// them to a fixed number of decimal places.
+//
+// Amounts marshal to JSON numbers and unmarshal from numbers or strings.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// Rounding modes.
+const (
+	HalfEven = "half_even"
+	HalfUp   = "half_up"
+	Down     = "down"
+	Up       = "up"
+)
+
+// Modes lists the supported rounding modes.
+var Modes = []string{HalfEven, HalfUp, Down, Up}
+
+// Amount is an exact decimal amount of money. The zero value is zero.
+type Amount struct {
+	d decimal.Decimal
+}
+
+var Zero Amount
+
+// FromFloat returns the shortest decimal that represents f. NaN and
+// infinities, which queries return for empty series, are zero.
+func FromFloat(f float64) Amount {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Zero
+	}
+	return Amount{decimal.NewFromFloat(f)}
+}
+
+// Parse reads a decimal amount such as "12.50" or "1e-3".
+func Parse(s string) (Amount, error) {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return Zero, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	return Amount{d}, nil
+}
+
+// Sum adds amounts.
+func Sum(amounts ...Amount) Amount {
+	var total Amount
+	for _, a := range amounts {
+		total = total.Add(a)
+	}
+	return total
+}
+
+func (a Amount) Add(b Amount) Amount {
+	return Amount{a.d.Add(b.d)}
+}
+
+func (a Amount) Sub(b Amount) Amount {
+	return Amount{a.d.Sub(b.d)}
+}
+
+func (a Amount) Neg() Amount {
+	return Amount{a.d.Neg()}
+}
+
+// Mul multiplies a by a quantity, rate or factor, such as a number of
+// hours or an exchange rate.
+func (a Amount) Mul(factor float64) Amount {
+	return a.MulAmount(FromFloat(factor))
+}
+
+// MulAmount multiplies a by b, such as a unit price by another price's
+// multiplier.
+func (a Amount) MulAmount(b Amount) Amount {
+	return Amount{a.d.Mul(b.d)}
+}
+
+// Div divides a by divisor to 16 decimal places beyond the integer part.
+// Dividing by zero is zero.
+func (a Amount) Div(divisor float64) Amount {
+	d := FromFloat(divisor)
+	if d.IsZero() {
+		return Zero
+	}
+	return Amount{a.d.Div(d.d)}
+}
+
+// Ratio returns a as a fraction of b, or zero when b is zero.
+func (a Amount) Ratio(b Amount) float64 {
+	if b.IsZero() {
+		return 0
+	}
+	return a.d.Div(b.d).InexactFloat64()
+}
+
+// Float64 returns the nearest float64, for metrics and ratios.
+func (a Amount) Float64() float64 {
+	return a.d.InexactFloat64()
+}
+
+func (a Amount) Sign() int {
+	return a.d.Sign()
+}
+
+func (a Amount) IsZero() bool {
+	return a.d.IsZero()
+}
+
+func (a Amount) IsPositive() bool {
+	return a.d.IsPositive()
+}
+
+func (a Amount) IsNegative() bool {
+	return a.d.IsNegative()
+}
+
+// Cmp returns -1, 0 or 1 as a is less than, equal to or greater than b.
+func (a Amount) Cmp(b Amount) int {
+	return a.d.Cmp(b.d)
+}
+
+func (a Amount) Equal(b Amount) bool {
+	return a.d.Equal(b.d)
+}
+
+// String formats a without an exponent and without trailing zeros.
+func (a Amount) String() string {
+	return a.d.String()
+}
+
+// StringFixed formats a with exactly places decimal places, rounding half
+// away from zero.
+func (a Amount) StringFixed(places int32) string {
+	return a.d.StringFixed(places)
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.d.String()), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*a = Zero
+		return nil
+	}
+	if err := a.d.UnmarshalJSON(data); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAmount, data)
+	}
+	return nil
+}
+
+// Rounding reduces amounts to Places decimal places under Mode.
+type Rounding struct {
+	Places int32
+	Mode   string
+}
+
+// Valid reports whether r's mode is supported and its places not negative.
+func (r Rounding) Valid() bool {
+	return r.Places >= 0 && slices.Contains(Modes, r.Mode)
+}
+
+// Round rounds a. An unknown mode rounds half to even.
+func (r Rounding) Round(a Amount) Amount {
+	switch r.Mode {
+	case HalfUp:
+		return Amount{a.d.Round(r.Places)}
+	case Down:
+		return Amount{a.d.RoundDown(r.Places)}
+	case Up:
+		return Amount{a.d.RoundUp(r.Places)}
+	default:
+		return Amount{a.d.RoundBank(r.Places)}
+	}
+}
+
+// Allocate splits total, rounded, into shares proportional to weights,
+// each rounded, that add up to exactly the rounded total. Units of the
+// last decimal place left over from rounding the shares toward zero go to
+// the shares with the largest remainders, earlier shares winning ties.
+// Weights must not be negative; when they add up to zero, Allocate returns
+// nil.
+func (r Rounding) Allocate(total Amount, weights []Amount) []Amount {
+	sum := Sum(weights...)
+	if !sum.IsPositive() {
+		return nil
+	}
+
+	total = r.Round(total)
+	shares := make([]Amount, len(weights))
+	remainders := make([]decimal.Decimal, len(weights))
+	left := total
+	for i, weight := range weights {
+		exact := total.d.Mul(weight.d).DivRound(sum.d, r.Places+16)
+		shares[i] = Amount{exact.Truncate(r.Places)}
+		remainders[i] = exact.Sub(shares[i].d).Abs()
+		left = left.Sub(shares[i])
+	}
+
+	unit := decimal.New(1, -r.Places)
+	if left.IsNegative() {
+		unit = unit.Neg()
+	}
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return remainders[b].Cmp(remainders[a])
+	})
+	for _, i := range order {
+		if left.IsZero() {
+			break
+		}
+		shares[i] = Amount{shares[i].d.Add(unit)}
+		left = Amount{left.d.Sub(unit)}
+	}
+	return shares
+}
+
+// RoundMap rounds every amount of costs into a new map, and returns it
+// with the sum of the rounded amounts.
+func (r Rounding) RoundMap(costs map[string]Amount) (map[string]Amount, Amount) {
+	if costs == nil {
+		return nil, Zero
+	}
+	rounded := make(map[string]Amount, len(costs))
+	var total Amount
+	for key, cost := range costs {
+		rounded[key] = r.Round(cost)
+		total = total.Add(rounded[key])
+	}
+	return rounded, total
+}
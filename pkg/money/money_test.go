@@ -0,0 +1,120 @@
+package money
+
+import "testing"
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		mode   string
+		places int32
+		amount string
+		want   string
+	}{
+		{HalfEven, 2, "1.005", "1.00"},
+		{HalfEven, 2, "1.015", "1.02"},
+		{HalfEven, 2, "-1.005", "-1.00"},
+		{HalfEven, 2, "1.0051", "1.01"},
+		{HalfEven, 0, "2.5", "2"},
+		{HalfEven, 0, "3.5", "4"},
+		{HalfUp, 2, "1.005", "1.01"},
+		{HalfUp, 2, "1.015", "1.02"},
+		{HalfUp, 2, "-1.005", "-1.01"},
+		{HalfUp, 2, "1.0049", "1.00"},
+		{HalfUp, 0, "2.5", "3"},
+		{Down, 2, "1.009", "1.00"},
+		{Down, 2, "-1.009", "-1.00"},
+		{Down, 0, "2.99", "2"},
+		{Up, 2, "1.001", "1.01"},
+		{Up, 2, "-1.001", "-1.01"},
+		{Up, 2, "1.00", "1.00"},
+		{Up, 0, "2.01", "3"},
+		{"", 2, "1.005", "1.00"},
+	}
+
+	for _, tt := range tests {
+		r := Rounding{Places: tt.places, Mode: tt.mode}
+		if got := r.Round(mustParse(t, tt.amount)); !got.Equal(mustParse(t, tt.want)) {
+			t.Errorf("Rounding{%d, %q}.Round(%s) = %s, want %s", tt.places, tt.mode, tt.amount, got, tt.want)
+		}
+	}
+}
+
+// TestAllocate checks the shares themselves and that they always add up to
+// the rounded total, whatever the mode.
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   string
+		weights []string
+		want    []string
+	}{
+		{"even split", "9", []string{"1", "1", "1"}, []string{"3.00", "3.00", "3.00"}},
+		{"remainder to earliest tie", "10", []string{"1", "1", "1"}, []string{"3.34", "3.33", "3.33"}},
+		{"remainder to largest remainder", "1", []string{"1", "2", "4"}, []string{"0.14", "0.29", "0.57"}},
+		{"negative pool", "-1", []string{"1", "1", "1"}, []string{"-0.34", "-0.33", "-0.33"}},
+		{"negative pool by weight", "-10", []string{"1", "2"}, []string{"-3.33", "-6.67"}},
+		{"zero weight gets nothing", "5", []string{"0", "1", "0"}, []string{"0", "5.00", "0"}},
+		{"zero total", "0", []string{"1", "3"}, []string{"0", "0"}},
+		{"unrounded total", "1.005", []string{"1", "1"}, []string{"0.50", "0.50"}},
+		{"all zero weights", "5", []string{"0", "0"}, nil},
+		{"no weights", "5", nil, nil},
+	}
+
+	for _, tt := range tests {
+		for _, mode := range Modes {
+			r := Rounding{Places: 2, Mode: mode}
+			weights := make([]Amount, len(tt.weights))
+			for i, weight := range tt.weights {
+				weights[i] = mustParse(t, weight)
+			}
+
+			shares := r.Allocate(mustParse(t, tt.total), weights)
+			if tt.want == nil {
+				if shares != nil {
+					t.Errorf("%s (%s): Allocate = %v, want nil", tt.name, mode, shares)
+				}
+				continue
+			}
+			if len(shares) != len(tt.want) {
+				t.Fatalf("%s (%s): Allocate returned %d shares, want %d", tt.name, mode, len(shares), len(tt.want))
+			}
+
+			// Halves only arise from unrounded totals, which are tested
+			// under the default mode.
+			if mode == HalfEven || tt.name != "unrounded total" {
+				for i, share := range shares {
+					if !share.Equal(mustParse(t, tt.want[i])) {
+						t.Errorf("%s (%s): share %d = %s, want %s", tt.name, mode, i, share, tt.want[i])
+					}
+				}
+			}
+			if sum, total := Sum(shares...), r.Round(mustParse(t, tt.total)); !sum.Equal(total) {
+				t.Errorf("%s (%s): shares add up to %s, want %s", tt.name, mode, sum, total)
+			}
+		}
+	}
+}
+
+func TestRoundMap(t *testing.T) {
+	r := Rounding{Places: 2, Mode: HalfEven}
+	costs := map[string]Amount{"a": mustParse(t, "1.004"), "b": mustParse(t, "2.006")}
+
+	rounded, total := r.RoundMap(costs)
+	if !rounded["a"].Equal(mustParse(t, "1.00")) || !rounded["b"].Equal(mustParse(t, "2.01")) {
+		t.Errorf("RoundMap = %v", rounded)
+	}
+	if !total.Equal(mustParse(t, "3.01")) {
+		t.Errorf("RoundMap total = %s, want 3.01", total)
+	}
+	if !costs["a"].Equal(mustParse(t, "1.004")) {
+		t.Errorf("RoundMap changed its input to %v", costs)
+	}
+}
+
+func mustParse(t *testing.T, s string) Amount {
+	t.Helper()
+	a, err := Parse(s)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", s, err)
+	}
+	return a
+}
@@ -0,0 +1,145 @@
+package observability
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds KuBudget's own operational metrics. It is kept separate from
+// the cost metrics registry so that internal metrics are never exposed on the
+// public port.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kubudget_http_request_duration_seconds",
+		Help:    "Duration of HTTP requests served by KuBudget.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	prometheusQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kubudget_prometheus_query_duration_seconds",
+		Help:    "Latency of queries sent to Prometheus.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"query_type"})
+
+	prometheusQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubudget_prometheus_query_errors_total",
+		Help: "Number of failed queries sent to Prometheus.",
+	}, []string{"query_type"})
+
+	kubernetesRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubudget_kubernetes_requests_total",
+		Help: "Number of calls made to the Kubernetes API.",
+	}, []string{"resource", "verb", "result"})
+
+	kubernetesRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kubudget_kubernetes_request_duration_seconds",
+		Help:    "Latency of calls made to the Kubernetes API.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"resource", "verb"})
+
+	collectorRunDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kubudget_collector_run_duration_seconds",
+		Help:    "Duration of background collector runs.",
+		Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"collector", "result"})
+
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubudget_cache_requests_total",
+		Help: "Number of cache lookups by outcome.",
+	}, []string{"cache", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestDuration,
+		prometheusQueryDuration,
+		prometheusQueryErrors,
+		kubernetesRequests,
+		kubernetesRequestDuration,
+		collectorRunDuration,
+		cacheRequests,
+	)
+}
+
+// Middleware records the duration and status of every request handled by
+// the fiber app. Requests are labelled by route pattern rather than raw path
+// to keep cardinality bounded.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			if e, ok := err.(*fiber.Error); ok {
+				status = e.Code
+			}
+		}
+
+		httpRequestDuration.
+			WithLabelValues(c.Method(), c.Route().Path, strconv.Itoa(status)).
+			Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+func ObservePrometheusQuery(queryType string, start time.Time, err error) {
+	prometheusQueryDuration.WithLabelValues(queryType).Observe(time.Since(start).Seconds())
+	if err != nil {
+		prometheusQueryErrors.WithLabelValues(queryType).Inc()
+	}
+}
+
+func ObserveKubernetesRequest(resource, verb string, start time.Time, err error) {
+	kubernetesRequestDuration.WithLabelValues(resource, verb).Observe(time.Since(start).Seconds())
+	kubernetesRequests.WithLabelValues(resource, verb, resultLabel(err)).Inc()
+}
+
+func ObserveCollectorRun(collector string, start time.Time, err error) {
+	collectorRunDuration.WithLabelValues(collector, resultLabel(err)).Observe(time.Since(start).Seconds())
+}
+
+func ObserveCacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequests.WithLabelValues(cache, result).Inc()
+}
+
+// Handler serves the internal metrics and, when enabled, the net/http/pprof
+// endpoints under /debug/pprof/.
+func Handler(enablePprof bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+
+	if enablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+
+	return mux
+}
+
+func resultLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
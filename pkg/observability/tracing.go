@@ -0,0 +1,123 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/SinghaAnirban005/KuBudget"
+
+// InitTracing installs the global tracer provider and W3C trace context
+// propagator. Export is enabled only when an OTLP endpoint is configured
+// through the standard OTEL_EXPORTER_OTLP_* environment variables; all other
+// exporter and sampler settings are read by the SDK from OTEL_* variables as
+// well. The returned function flushes and stops the exporter.
+func InitTracing(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %v", err)
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewSchemaless(semconv.ServiceName("kubudget")),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %v", err)
+	}
+	// Let OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the default.
+	res, err = resource.Merge(res, resource.Environment())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// StartSpan starts a span as a child of any span already in ctx.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan records err on span, if any, and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TracingMiddleware starts a server span for every request, continuing any
+// trace context sent by the caller. The span's context is stored as the
+// request's user context so handlers pass it on via c.UserContext().
+func TracingMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		carrier := propagation.HeaderCarrier{}
+		for key, values := range c.GetReqHeaders() {
+			for _, value := range values {
+				carrier.Set(key, value)
+			}
+		}
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), carrier)
+
+		ctx, span := otel.Tracer(tracerName).Start(ctx, c.Method()+" "+c.Path(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Method()),
+				semconv.URLPath(c.Path()),
+			),
+		)
+		defer span.End()
+
+		c.SetUserContext(ctx)
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			if e, ok := err.(*fiber.Error); ok {
+				status = e.Code
+			}
+			span.RecordError(err)
+		}
+
+		span.SetName(c.Method() + " " + c.Route().Path)
+		span.SetAttributes(
+			semconv.HTTPRoute(c.Route().Path),
+			semconv.HTTPResponseStatusCode(status),
+		)
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+		}
+
+		return err
+	}
+}
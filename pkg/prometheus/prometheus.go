@@ -4,17 +4,23 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strconv"
 	"time"
 
 	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/observability"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 type Client struct {
 	baseURL string
 	client  *http.Client
+	logger  *slog.Logger
 }
 
 type QueryResult struct {
@@ -38,16 +44,49 @@ type MatrixValue struct {
 	Values []internal.SamplePair `json:"values"`
 }
 
-func NewClient(baseURL string) *Client {
+func NewClient(baseURL string, logger *slog.Logger) *Client {
 	return &Client{
 		baseURL: baseURL,
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		logger: logger,
 	}
 }
 
 func (c *Client) Query(ctx context.Context, query string) (*QueryResult, error) {
+	vector, err := c.QueryVector(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &QueryResult{Status: "success"}
+	result.Data.ResultType = "vector"
+	for _, v := range vector {
+		result.Data.Result = append(result.Data.Result, internal.SamplePair{Timestamp: v.Timestamp, Value: v.Value})
+	}
+	return result, nil
+}
+
+// VectorSample is one labeled sample of an instant query.
+type VectorSample struct {
+	Labels    map[string]string
+	Timestamp time.Time
+	Value     float64
+}
+
+// QueryVector runs an instant query and returns every sample with its
+// labels.
+func (c *Client) QueryVector(ctx context.Context, query string) (vector []VectorSample, err error) {
+	ctx, span := observability.StartSpan(ctx, "prometheus.query", attribute.String("db.query.text", query))
+	defer func(start time.Time) {
+		observability.ObservePrometheusQuery("instant", start, err)
+		observability.EndSpan(span, err)
+		if err != nil {
+			c.logger.DebugContext(ctx, "prometheus query failed", "query", query, "error", err)
+		}
+	}(time.Now())
+
 	u, err := url.Parse(c.baseURL + "/api/v1/query")
 	if err != nil {
 		return nil, err
@@ -62,6 +101,8 @@ func (c *Client) Query(ctx context.Context, query string) (*QueryResult, error)
 		return nil, err
 	}
 
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, err
@@ -72,20 +113,124 @@ func (c *Client) Query(ctx context.Context, query string) (*QueryResult, error)
 		return nil, fmt.Errorf("prometheus query failed with status %d", resp.StatusCode)
 	}
 
-	var result QueryResult
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	var raw struct {
+		Status string `json:"status"`
+		Data   struct {
+			Result []struct {
+				Metric map[string]string `json:"metric"`
+				Value  sample            `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
 		return nil, err
 	}
+	if raw.Status != "success" {
+		return nil, fmt.Errorf("prometheus query returned status %q", raw.Status)
+	}
 
-	return &result, nil
+	vector = make([]VectorSample, 0, len(raw.Data.Result))
+	for _, r := range raw.Data.Result {
+		vector = append(vector, VectorSample{Labels: r.Metric, Timestamp: r.Value.Timestamp, Value: r.Value.Value})
+	}
+	return vector, nil
 }
 
 func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (*RangeQueryResult, error) {
-	u, err := url.Parse(c.baseURL + "/api/v1/query_range")
+	series, err := c.QueryRangeSeries(ctx, query, start, end, step)
 	if err != nil {
 		return nil, err
 	}
 
+	result := &RangeQueryResult{Status: "success"}
+	result.Data.ResultType = "matrix"
+	for _, s := range series {
+		result.Data.Result = append(result.Data.Result, MatrixValue{
+			Metric: internal.MetricPoint{Labels: s.Labels},
+			Values: s.Samples,
+		})
+	}
+	return result, nil
+}
+
+// Series is one labeled time series of a range query.
+type Series struct {
+	Labels  map[string]string
+	Samples []internal.SamplePair
+}
+
+// QueryRangeSeries runs a range query and returns every series with its
+// labels and samples.
+func (c *Client) QueryRangeSeries(ctx context.Context, query string, start, end time.Time, step time.Duration) ([]Series, error) {
+	var raw struct {
+		Status string `json:"status"`
+		Data   struct {
+			Result []struct {
+				Metric map[string]string `json:"metric"`
+				Values []sample          `json:"values"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := c.queryRange(ctx, query, start, end, step, &raw); err != nil {
+		return nil, err
+	}
+	if raw.Status != "success" {
+		return nil, fmt.Errorf("prometheus range query returned status %q", raw.Status)
+	}
+
+	series := make([]Series, 0, len(raw.Data.Result))
+	for _, result := range raw.Data.Result {
+		s := Series{Labels: result.Metric, Samples: make([]internal.SamplePair, 0, len(result.Values))}
+		for _, value := range result.Values {
+			s.Samples = append(s.Samples, internal.SamplePair(value))
+		}
+		series = append(series, s)
+	}
+	return series, nil
+}
+
+// sample decodes a sample as the Prometheus API encodes it, a
+// [unix seconds, "value"] pair.
+type sample internal.SamplePair
+
+func (s *sample) UnmarshalJSON(data []byte) error {
+	var pair [2]any
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return err
+	}
+	timestamp, ok := pair[0].(float64)
+	text, isString := pair[1].(string)
+	if !ok || !isString {
+		return fmt.Errorf("malformed sample %s", data)
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return fmt.Errorf("malformed sample value %q", text)
+	}
+	s.Timestamp = time.Unix(0, int64(timestamp*float64(time.Second))).UTC()
+	s.Value = value
+	return nil
+}
+
+func (c *Client) queryRange(ctx context.Context, query string, start, end time.Time, step time.Duration, out any) (err error) {
+	ctx, span := observability.StartSpan(ctx, "prometheus.query_range",
+		attribute.String("db.query.text", query),
+		attribute.String("prometheus.step", step.String()),
+		attribute.String("prometheus.range", end.Sub(start).String()),
+	)
+	defer func(begin time.Time) {
+		observability.ObservePrometheusQuery("range", begin, err)
+		observability.EndSpan(span, err)
+		if err != nil {
+			c.logger.DebugContext(ctx, "prometheus range query failed", "query", query, "error", err)
+		}
+	}(time.Now())
+
+	u, err := url.Parse(c.baseURL + "/api/v1/query_range")
+	if err != nil {
+		return err
+	}
+
 	params := url.Values{}
 	params.Add("query", query)
 	params.Add("start", strconv.FormatInt(start.Unix(), 10))
@@ -96,24 +241,55 @@ func (c *Client) QueryRange(ctx context.Context, query string, start, end time.T
 
 	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
 	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("prometheus range query failed with status %d", resp.StatusCode)
+		return fmt.Errorf("prometheus range query failed with status %d", resp.StatusCode)
 	}
 
-	var result RangeQueryResult
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Ready checks Prometheus' readiness endpoint and that it can evaluate a
+// trivial query.
+func (c *Client) Ready(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/-/ready", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("prometheus is not ready, status %d", resp.StatusCode)
+	}
+
+	result, err := c.Query(ctx, "vector(1)")
+	if err != nil {
+		return err
+	}
+	if result.Status != "success" {
+		return fmt.Errorf("prometheus query returned status %q", result.Status)
 	}
 
-	return &result, err
+	return nil
 }
 
 func (c *Client) GetCPUUsage(ctx context.Context, namespace string, pod string) (float64, error) {
-	query := fmt.Sprintf(`rate(container_cpu_usage_seconds_total{namespace="%s",pod="%s"}[5m])`, namespace, pod)
+	query := Rate(NewSelector("container_cpu_usage_seconds_total").Eq("namespace", namespace).Eq("pod", pod), 5*time.Minute)
 
 	result, err := c.Query(ctx, query)
 	if err != nil {
@@ -127,12 +303,60 @@ func (c *Client) GetCPUUsage(ctx context.Context, namespace string, pod string)
 }
 
 func (c *Client) GetMemoryUsage(ctx context.Context, namespace, pod string) (float64, error) {
-	query := fmt.Sprintf(`container_memory_usage_bytes{namespace="%s",pod="%s"}`, namespace, pod)
+	query := NewSelector("container_memory_usage_bytes").Eq("namespace", namespace).Eq("pod", pod).String()
+	result, err := c.Query(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(result.Data.Result) == 0 {
+		return 0, nil
+	}
+
+	return float64(result.Data.Result[0].Value), nil
+}
+
+// GetNodeCPUUsage returns the cores a node uses, from the cAdvisor series
+// of its root cgroup.
+func (c *Client) GetNodeCPUUsage(ctx context.Context, node string) (float64, error) {
+	query := "sum(" + Rate(NewSelector("container_cpu_usage_seconds_total").Eq("id", "/").Eq("node", node), 5*time.Minute) + ")"
 	result, err := c.Query(ctx, query)
 	if err != nil {
+		return 0, err
+	}
+
+	if len(result.Data.Result) == 0 {
+		return 0, nil
+	}
+
+	return float64(result.Data.Result[0].Value), nil
+}
+
+// GetNodeMemoryUsage returns the bytes of memory a node uses, from the
+// cAdvisor series of its root cgroup.
+func (c *Client) GetNodeMemoryUsage(ctx context.Context, node string) (float64, error) {
+	query := "sum(" + NewSelector("container_memory_usage_bytes").Eq("id", "/").Eq("node", node).String() + ")"
+	result, err := c.Query(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(result.Data.Result) == 0 {
 		return 0, nil
 	}
 
+	return float64(result.Data.Result[0].Value), nil
+}
+
+// GetEphemeralStorageUsage returns the bytes a pod's containers have
+// written to their writable layers and logs, summed over its containers.
+func (c *Client) GetEphemeralStorageUsage(ctx context.Context, namespace, pod string) (float64, error) {
+	query := "sum(" + NewSelector("container_fs_usage_bytes").Eq("namespace", namespace).Eq("pod", pod).String() + ")"
+	result, err := c.Query(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
 	if len(result.Data.Result) == 0 {
 		return 0, nil
 	}
@@ -141,13 +365,13 @@ func (c *Client) GetMemoryUsage(ctx context.Context, namespace, pod string) (flo
 }
 
 func (c *Client) GetNetworkIO(ctx context.Context, namespace, pod string) (float64, float64, error) {
-	rxQuery := fmt.Sprintf(`rate(container_network_receive_bytes_total{namespace="%s",pod="%s"}[5m])`, namespace, pod)
+	rxQuery := Rate(NewSelector("container_network_receive_bytes_total").Eq("namespace", namespace).Eq("pod", pod), 5*time.Minute)
 	rxResult, err := c.Query(ctx, rxQuery)
 	if err != nil {
 		return 0, 0, err
 	}
 
-	txQuery := fmt.Sprintf(`rate(container_network_transmit_bytes_total{namespace="%s",pod="%s"}[5m])`, namespace, pod)
+	txQuery := Rate(NewSelector("container_network_transmit_bytes_total").Eq("namespace", namespace).Eq("pod", pod), 5*time.Minute)
 	txResult, err := c.Query(ctx, txQuery)
 	if err != nil {
 		return 0, 0, err
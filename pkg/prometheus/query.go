@@ -0,0 +1,82 @@
+package prometheus
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNamePattern  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Selector builds a PromQL instant vector selector. Label values are
+// always emitted as escaped string literals, so caller-supplied values
+// cannot change the structure of the query.
+type Selector struct {
+	metric   string
+	matchers []string
+}
+
+// NewSelector starts a selector for metric. Metric and label names come
+// from code rather than user input, so invalid names panic.
+func NewSelector(metric string) *Selector {
+	if !metricNamePattern.MatchString(metric) {
+		panic(fmt.Sprintf("prometheus: invalid metric name %q", metric))
+	}
+	return &Selector{metric: metric}
+}
+
+// Eq adds a label="value" matcher.
+func (s *Selector) Eq(label, value string) *Selector {
+	if !labelNamePattern.MatchString(label) {
+		panic(fmt.Sprintf("prometheus: invalid label name %q", label))
+	}
+	s.matchers = append(s.matchers, label+"="+QuoteLabelValue(value))
+	return s
+}
+
+// EqIfSet adds a label="value" matcher unless value is empty, for optional
+// filters.
+func (s *Selector) EqIfSet(label, value string) *Selector {
+	if value == "" {
+		return s
+	}
+	return s.Eq(label, value)
+}
+
+func (s *Selector) String() string {
+	if len(s.matchers) == 0 {
+		return s.metric
+	}
+	return s.metric + "{" + strings.Join(s.matchers, ",") + "}"
+}
+
+// Rate wraps selector in rate() over window.
+func Rate(selector *Selector, window time.Duration) string {
+	return "rate(" + selector.String() + "[" + formatDuration(window) + "])"
+}
+
+// QuoteLabelValue renders value as a PromQL string literal. PromQL strings
+// use Go escaping rules, so quotes, backslashes and control characters are
+// all escaped.
+func QuoteLabelValue(value string) string {
+	return strconv.Quote(value)
+}
+
+// formatDuration renders d in PromQL duration syntax, e.g. 5m or 90s.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return strconv.FormatInt(int64(d/time.Hour), 10) + "h"
+	case d%time.Minute == 0:
+		return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+	case d%time.Second == 0:
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	default:
+		return strconv.FormatInt(int64(d/time.Millisecond), 10) + "ms"
+	}
+}
@@ -0,0 +1,76 @@
+package prometheus
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// FuzzSelector checks that no label value can escape its string literal:
+// the built selector must parse back into exactly the matchers that were
+// added, with the original values.
+func FuzzSelector(f *testing.F) {
+	f.Add("default", "api-7d9f")
+	f.Add(`x"} or vector(1) #`, "")
+	f.Add(`\`, `\"`)
+	f.Add("a\nb", "}{,=~")
+	f.Add("\xff\x00", "日本")
+
+	f.Fuzz(func(t *testing.T, namespace, pod string) {
+		query := NewSelector("container_memory_usage_bytes").Eq("namespace", namespace).Eq("pod", pod).String()
+
+		rest, ok := strings.CutPrefix(query, "container_memory_usage_bytes{namespace=")
+		if !ok {
+			t.Fatalf("unexpected prefix in %q", query)
+		}
+		rest = expectLiteral(t, query, rest, namespace)
+
+		rest, ok = strings.CutPrefix(rest, ",pod=")
+		if !ok {
+			t.Fatalf("namespace literal did not end before the pod matcher in %q", query)
+		}
+		rest = expectLiteral(t, query, rest, pod)
+
+		if rest != "}" {
+			t.Fatalf("unexpected trailing %q in %q", rest, query)
+		}
+	})
+}
+
+func FuzzRate(f *testing.F) {
+	f.Add(`x"}[5m]) or vector(1) #`)
+
+	f.Fuzz(func(t *testing.T, namespace string) {
+		query := Rate(NewSelector("container_cpu_usage_seconds_total").Eq("namespace", namespace), 5*time.Minute)
+
+		rest, ok := strings.CutPrefix(query, "rate(container_cpu_usage_seconds_total{namespace=")
+		if !ok {
+			t.Fatalf("unexpected prefix in %q", query)
+		}
+		rest = expectLiteral(t, query, rest, namespace)
+
+		if rest != "}[5m])" {
+			t.Fatalf("unexpected trailing %q in %q", rest, query)
+		}
+	})
+}
+
+// expectLiteral consumes a quoted string at the start of rest, checks that
+// it decodes to want and returns what follows it.
+func expectLiteral(t *testing.T, query, rest, want string) string {
+	t.Helper()
+
+	literal, err := strconv.QuotedPrefix(rest)
+	if err != nil {
+		t.Fatalf("no string literal at %q in %q: %v", rest, query, err)
+	}
+	got, err := strconv.Unquote(literal)
+	if err != nil {
+		t.Fatalf("invalid literal %s in %q: %v", literal, query, err)
+	}
+	if got != want {
+		t.Fatalf("literal decoded to %q, want %q", got, want)
+	}
+	return rest[len(literal):]
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+)
+
+type nodeRef struct{ cluster, node string }
+
+// nodeRate is the factor between a node's effective and list cost and the
+// pricing model that produced it.
+type nodeRate struct {
+	factor float64
+	model  string
+}
+
+// Adjustments holds the factors that turn list-price costs into what is
+// paid: per node for CPU and memory and per cluster for storage.
+// Reconciliation factors come from billing data, which already includes
+// every discount, so they take precedence over the modeled discount rates.
+// A missing factor is 1, so costs are at list price until a resource is
+// reconciled or discounted.
+type Adjustments struct {
+	mu        sync.RWMutex
+	nodes     map[nodeRef]float64
+	storage   map[string]float64
+	discounts map[nodeRef]nodeRate
+}
+
+func NewAdjustments() *Adjustments {
+	return &Adjustments{
+		nodes:     make(map[nodeRef]float64),
+		storage:   make(map[string]float64),
+		discounts: make(map[nodeRef]nodeRate),
+	}
+}
+
+// Set replaces every reconciliation factor.
+func (a *Adjustments) Set(nodes map[nodeRef]float64, storage map[string]float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.nodes = nodes
+	a.storage = storage
+}
+
+// SetDiscounts replaces every modeled discount rate.
+func (a *Adjustments) SetDiscounts(discounts map[nodeRef]nodeRate) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.discounts = discounts
+}
+
+func (a *Adjustments) Node(cluster, node string) float64 {
+	factor, _ := a.nodeRate(cluster, node)
+	return factor
+}
+
+// nodeRate returns a node's factor and pricing model, which is reconciled
+// when the factor comes from billing data.
+func (a *Adjustments) nodeRate(cluster, node string) (float64, string) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if factor, ok := a.nodes[nodeRef{cluster, node}]; ok {
+		return factor, "reconciled"
+	}
+	if rate, ok := a.discounts[nodeRef{cluster, node}]; ok {
+		return rate.factor, rate.model
+	}
+	return 1, "on_demand"
+}
+
+func (a *Adjustments) Storage(cluster string) float64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if factor, ok := a.storage[cluster]; ok {
+		return factor
+	}
+	return 1
+}
+
+// ApplyPod scales a pod's CPU, memory and extended resources by its node's
+// factor and its storage by its cluster's factor, keeping the unscaled
+// total as its list cost and the factors as its rates. The node's factor is
+// for the instance, so the root disk, billed separately, is not scaled.
+func (a *Adjustments) ApplyPod(pod *internal.PodCost) {
+	pod.ListCost = money.Sum(pod.CPUCost, pod.MemoryCost, pod.StorageCost, pod.NetworkCost, pod.EphemeralStorageCost, pod.ExtendedCost)
+
+	node := a.Node(pod.Cluster, pod.Node)
+	pod.NodeRate = node
+	pod.StorageRate = a.Storage(pod.Cluster)
+	pod.CPUCost = pod.CPUCost.Mul(node)
+	pod.MemoryCost = pod.MemoryCost.Mul(node)
+	pod.ExtendedCost = scaleCosts(pod.ExtendedResources, node)
+	pod.StorageCost = pod.StorageCost.Mul(pod.StorageRate)
+	pod.TotalCost = money.Sum(pod.CPUCost, pod.MemoryCost, pod.StorageCost, pod.NetworkCost, pod.EphemeralStorageCost, pod.ExtendedCost)
+	pod.Savings = pod.ListCost.Sub(pod.TotalCost)
+}
+
+// ApplyNode scales a node's costs by its factor, keeping the unscaled total
+// as its list cost and the factor as its rate.
+func (a *Adjustments) ApplyNode(node *internal.NodeCost) {
+	node.ListCost = money.Sum(node.CPUCost, node.MemoryCost, node.StorageCost, node.BootDiskCost, node.ExtendedCost)
+
+	factor, model := a.nodeRate(node.Cluster, node.Name)
+	node.Rate = factor
+	node.PricingModel = model
+	node.CPUCost = node.CPUCost.Mul(factor)
+	node.MemoryCost = node.MemoryCost.Mul(factor)
+	node.ExtendedCost = scaleCosts(node.ExtendedResources, factor)
+	node.ExtendedIdleCost = node.ExtendedIdleCost.Mul(factor)
+	node.GPUIdleCost = node.GPUIdleCost.Mul(factor)
+	node.StorageCost = node.StorageCost.Mul(a.Storage(node.Cluster))
+	node.TotalCost = money.Sum(node.CPUCost, node.MemoryCost, node.StorageCost, node.BootDiskCost, node.ExtendedCost)
+	node.Savings = node.ListCost.Sub(node.TotalCost)
+}
+
+// scaleCosts scales every cost in place and returns the new total.
+func scaleCosts(costs map[string]money.Amount, factor float64) money.Amount {
+	var total money.Amount
+	for resource, cost := range costs {
+		costs[resource] = cost.Mul(factor)
+		total = total.Add(costs[resource])
+	}
+	return total
+}
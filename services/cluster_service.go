@@ -0,0 +1,556 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/externalcost"
+	"github.com/SinghaAnirban005/KuBudget/pkg/kubernetes"
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+	"github.com/SinghaAnirban005/KuBudget/pkg/observability"
+	"github.com/SinghaAnirban005/KuBudget/pkg/prometheus"
+)
+
+var (
+	ErrUnknownCluster   = errors.New("unknown cluster")
+	ErrInvalidAggregate = errors.New("invalid aggregate, expected namespace, cluster, controller or label:<key>")
+)
+
+// unallocatedKey groups pods that lack the label being aggregated on.
+const unallocatedKey = "__unallocated__"
+
+// Cluster bundles the clients and per-cluster services for one configured
+// cluster.
+type Cluster struct {
+	Name       string
+	K8sClient  *kubernetes.Client
+	PromClient *prometheus.Client
+	Cost       *CostService
+	Metrics    *MetricsService
+}
+
+// ClusterService fans requests out to every configured cluster and combines
+// the results into cluster rollups and cross-cluster aggregations.
+type ClusterService struct {
+	config      *internal.ConfigStore
+	clusters    []*Cluster
+	byName      map[string]*Cluster
+	adjustments *Adjustments
+	prices      *PriceHistory
+	rates       *ExchangeRates
+	logger      *slog.Logger
+}
+
+func NewClusterService(config *internal.ConfigStore, logger *slog.Logger) (*ClusterService, error) {
+	clusterConfigs := config.Current().ClusterConfigs()
+	s := &ClusterService{
+		config:      config,
+		clusters:    make([]*Cluster, 0, len(clusterConfigs)),
+		byName:      make(map[string]*Cluster, len(clusterConfigs)),
+		adjustments: NewAdjustments(),
+		prices:      NewPriceHistory(),
+		rates:       NewExchangeRates(),
+		logger:      logger,
+	}
+
+	for _, cc := range clusterConfigs {
+		clusterLogger := logger.With("cluster", cc.Name)
+
+		k8sClient, err := kubernetes.NewClient(kubernetes.Options{
+			KubeConfigPath: cc.KubeConfig,
+			Context:        cc.Context,
+			InCluster:      cc.InCluster,
+		}, clusterLogger)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %v", cc.Name, err)
+		}
+
+		promClient := prometheus.NewClient(cc.PrometheusURL, clusterLogger)
+
+		cluster := &Cluster{
+			Name:       cc.Name,
+			K8sClient:  k8sClient,
+			PromClient: promClient,
+			Cost:       NewCostService(cc.Name, k8sClient, promClient, config, s.adjustments, s.prices, clusterLogger),
+			Metrics:    NewMetricsService(cc.Name, k8sClient, promClient, clusterLogger),
+		}
+		s.clusters = append(s.clusters, cluster)
+		s.byName[cc.Name] = cluster
+	}
+
+	return s, nil
+}
+
+func (s *ClusterService) Clusters() []*Cluster {
+	return s.clusters
+}
+
+// Adjustments returns the reconciliation factors applied to the costs of
+// every cluster.
+func (s *ClusterService) Adjustments() *Adjustments {
+	return s.adjustments
+}
+
+// PriceHistory returns the price versions the cost history of every
+// cluster is priced with.
+func (s *ClusterService) PriceHistory() *PriceHistory {
+	return s.prices
+}
+
+// ExchangeRates returns the rates costs are converted into other
+// currencies with.
+func (s *ClusterService) ExchangeRates() *ExchangeRates {
+	return s.rates
+}
+
+// PricingFor returns the current pricing of the named cluster.
+func (s *ClusterService) PricingFor(cluster string) internal.PricingConfig {
+	return s.config.Current().PricingFor(cluster)
+}
+
+func (s *ClusterService) Get(name string) (*Cluster, error) {
+	cluster, exists := s.byName[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCluster, name)
+	}
+	return cluster, nil
+}
+
+// Select returns the named cluster, or every cluster when name is empty.
+func (s *ClusterService) Select(name string) ([]*Cluster, error) {
+	if name == "" {
+		return s.clusters, nil
+	}
+
+	cluster, err := s.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return []*Cluster{cluster}, nil
+}
+
+type clusterResult[T any] struct {
+	cluster *Cluster
+	value   T
+	err     error
+}
+
+// fanOut calls fn for every cluster concurrently and returns the results in
+// cluster order.
+func fanOut[T any](ctx context.Context, clusters []*Cluster, fn func(ctx context.Context, cluster *Cluster) (T, error)) []clusterResult[T] {
+	results := make([]clusterResult[T], len(clusters))
+
+	var wg sync.WaitGroup
+	for i, cluster := range clusters {
+		wg.Add(1)
+		go func(i int, cluster *Cluster) {
+			defer wg.Done()
+			value, err := fn(ctx, cluster)
+			results[i] = clusterResult[T]{cluster: cluster, value: value, err: err}
+		}(i, cluster)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// collect flattens per-cluster results. Failed clusters are logged and
+// skipped; an error is returned only if every cluster failed.
+func collect[T any](ctx context.Context, logger *slog.Logger, results []clusterResult[T]) ([]T, error) {
+	values := make([]T, 0, len(results))
+	var errs []error
+
+	for _, result := range results {
+		if result.err != nil {
+			logger.WarnContext(ctx, "skipping cluster", "cluster", result.cluster.Name, "error", result.err)
+			errs = append(errs, fmt.Errorf("cluster %s: %v", result.cluster.Name, result.err))
+			continue
+		}
+		values = append(values, result.value)
+	}
+
+	if len(values) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return values, nil
+}
+
+func (s *ClusterService) GetCostOverview(ctx context.Context, clusters []*Cluster) (*internal.CostOverview, error) {
+	ctx, span := observability.StartSpan(ctx, "ClusterService.GetCostOverview")
+	defer span.End()
+
+	results := fanOut(ctx, clusters, func(ctx context.Context, cluster *Cluster) (*internal.CostOverview, error) {
+		return cluster.Cost.GetCostOverview(ctx)
+	})
+
+	if len(clusters) == 1 {
+		return results[0].value, results[0].err
+	}
+
+	overview := &internal.CostOverview{
+		NamespacesCost: make([]internal.NamespaceCost, 0),
+		Clusters:       make([]internal.ClusterCost, 0, len(results)),
+		Timestamp:      time.Now(),
+	}
+
+	overviews, err := collect(ctx, s.logger, results)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range overviews {
+		overview.TotalCost.CPUCost = overview.TotalCost.CPUCost.Add(o.TotalCost.CPUCost)
+		overview.TotalCost.MemoryCost = overview.TotalCost.MemoryCost.Add(o.TotalCost.MemoryCost)
+		overview.TotalCost.StorageCost = overview.TotalCost.StorageCost.Add(o.TotalCost.StorageCost)
+		overview.TotalCost.NetworkCost = overview.TotalCost.NetworkCost.Add(o.TotalCost.NetworkCost)
+		overview.TotalCost.TotalCost = overview.TotalCost.TotalCost.Add(o.TotalCost.TotalCost)
+		overview.NamespacesCost = append(overview.NamespacesCost, o.NamespacesCost...)
+	}
+
+	for _, result := range results {
+		overview.Clusters = append(overview.Clusters, rollupCluster(result.cluster.Name, result.value, result.err))
+	}
+
+	return overview, nil
+}
+
+// GetClusterCosts returns one rollup per cluster. Unreachable clusters are
+// included with their error so that a missing cluster is visible rather
+// than silently absent.
+func (s *ClusterService) GetClusterCosts(ctx context.Context, clusters []*Cluster) []internal.ClusterCost {
+	ctx, span := observability.StartSpan(ctx, "ClusterService.GetClusterCosts")
+	defer span.End()
+
+	results := fanOut(ctx, clusters, func(ctx context.Context, cluster *Cluster) (*internal.CostOverview, error) {
+		return cluster.Cost.GetCostOverview(ctx)
+	})
+
+	costs := make([]internal.ClusterCost, 0, len(results))
+	for _, result := range results {
+		if result.err != nil {
+			s.logger.WarnContext(ctx, "failed to get cluster costs", "cluster", result.cluster.Name, "error", result.err)
+		}
+		costs = append(costs, rollupCluster(result.cluster.Name, result.value, result.err))
+	}
+
+	return costs
+}
+
+func (s *ClusterService) GetNamespaceCosts(ctx context.Context, clusters []*Cluster) ([]internal.NamespaceCost, error) {
+	ctx, span := observability.StartSpan(ctx, "ClusterService.GetNamespaceCosts")
+	defer span.End()
+
+	results := fanOut(ctx, clusters, func(ctx context.Context, cluster *Cluster) ([]internal.NamespaceCost, error) {
+		return cluster.Cost.GetNamespaceCosts(ctx)
+	})
+
+	perCluster, err := collect(ctx, s.logger, results)
+	if err != nil {
+		return nil, err
+	}
+
+	costs := make([]internal.NamespaceCost, 0)
+	for _, c := range perCluster {
+		costs = append(costs, c...)
+	}
+	return costs, nil
+}
+
+// AggregateNamespaces groups the pod costs of namespaces by aggregate,
+// which is "namespace", "cluster", "controller" or "label:<key>".
+// Controller aggregation groups pods by workload, keyed
+// namespace/kind/name, and bare pods by themselves. Label aggregation uses
+// the pod's label, falling back to its namespace's label, and groups pods
+// with neither under "__unallocated__".
+//
+// External costs are added at their hourly rate to the group named by
+// their "namespace", "cluster" or "controller" label, or by the aggregated
+// label.
+func AggregateNamespaces(namespaces []internal.NamespaceCost, external []internal.ExternalCost, aggregate string) ([]internal.AggregatedCost, error) {
+	keyFn, err := aggregationKey(aggregate)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*internal.AggregatedCost)
+	groupClusters := make(map[string]map[string]bool)
+	groupFor := func(key string) *internal.AggregatedCost {
+		group, exists := groups[key]
+		if !exists {
+			group = &internal.AggregatedCost{Key: key, Timestamp: time.Now()}
+			groups[key] = group
+			groupClusters[key] = make(map[string]bool)
+		}
+		return group
+	}
+
+	for _, ns := range namespaces {
+		for _, pod := range ns.Pods {
+			key := keyFn(ns, pod)
+
+			group := groupFor(key)
+			group.CPUCost = group.CPUCost.Add(pod.CPUCost)
+			group.MemoryCost = group.MemoryCost.Add(pod.MemoryCost)
+			group.StorageCost = group.StorageCost.Add(pod.StorageCost)
+			group.NetworkCost = group.NetworkCost.Add(pod.NetworkCost)
+			for class, cost := range pod.NetworkEgress {
+				if group.NetworkEgress == nil {
+					group.NetworkEgress = make(map[string]money.Amount)
+				}
+				group.NetworkEgress[class] = group.NetworkEgress[class].Add(cost)
+			}
+			group.TotalCost = group.TotalCost.Add(pod.TotalCost)
+			group.PodCount++
+			groupClusters[key][ns.Cluster] = true
+		}
+	}
+
+	label := externalAggregationLabel(aggregate)
+	for _, item := range external {
+		key, ok := item.Labels[label]
+		if !ok {
+			key = unallocatedKey
+		}
+
+		hourly := externalcost.HourlyCost(item)
+		group := groupFor(key)
+		group.ExternalCost = group.ExternalCost.Add(hourly)
+		group.TotalCost = group.TotalCost.Add(hourly)
+		group.ExternalCount++
+		if cluster, ok := item.Labels["cluster"]; ok {
+			groupClusters[key][cluster] = true
+		}
+	}
+
+	costs := make([]internal.AggregatedCost, 0, len(groups))
+	for key, group := range groups {
+		group.Clusters = make([]string, 0, len(groupClusters[key]))
+		for cluster := range groupClusters[key] {
+			group.Clusters = append(group.Clusters, cluster)
+		}
+		sort.Strings(group.Clusters)
+		costs = append(costs, *group)
+	}
+	sort.Slice(costs, func(i, j int) bool {
+		return costs[i].TotalCost.Cmp(costs[j].TotalCost) > 0
+	})
+
+	return costs, nil
+}
+
+func (s *ClusterService) GetPodCosts(ctx context.Context, clusters []*Cluster, namespace string) ([]internal.PodCost, error) {
+	ctx, span := observability.StartSpan(ctx, "ClusterService.GetPodCosts")
+	defer span.End()
+
+	results := fanOut(ctx, clusters, func(ctx context.Context, cluster *Cluster) ([]internal.PodCost, error) {
+		return cluster.Cost.GetPodCosts(ctx, namespace)
+	})
+
+	perCluster, err := collect(ctx, s.logger, results)
+	if err != nil {
+		return nil, err
+	}
+
+	costs := make([]internal.PodCost, 0)
+	for _, c := range perCluster {
+		costs = append(costs, c...)
+	}
+	return costs, nil
+}
+
+func (s *ClusterService) GetNodeCosts(ctx context.Context, clusters []*Cluster) ([]internal.NodeCost, error) {
+	ctx, span := observability.StartSpan(ctx, "ClusterService.GetNodeCosts")
+	defer span.End()
+
+	results := fanOut(ctx, clusters, func(ctx context.Context, cluster *Cluster) ([]internal.NodeCost, error) {
+		return cluster.Cost.GetNodeCosts(ctx)
+	})
+
+	perCluster, err := collect(ctx, s.logger, results)
+	if err != nil {
+		return nil, err
+	}
+
+	costs := make([]internal.NodeCost, 0)
+	for _, c := range perCluster {
+		costs = append(costs, c...)
+	}
+	return costs, nil
+}
+
+// GetCostHistory sums the history of every selected cluster per timestamp,
+// converted by converter.
+func (s *ClusterService) GetCostHistory(ctx context.Context, clusters []*Cluster, duration, step time.Duration, namespace string, converter *CurrencyConverter) (*internal.CostHistory, error) {
+	ctx, span := observability.StartSpan(ctx, "ClusterService.GetCostHistory")
+	defer span.End()
+
+	results := fanOut(ctx, clusters, func(ctx context.Context, cluster *Cluster) (*internal.CostHistory, error) {
+		return cluster.Cost.GetCostHistory(ctx, duration, step, namespace)
+	})
+
+	var histories []*internal.CostHistory
+	if len(clusters) == 1 {
+		if results[0].err != nil {
+			return nil, results[0].err
+		}
+		histories = []*internal.CostHistory{results[0].value}
+	} else {
+		var err error
+		if histories, err = collect(ctx, s.logger, results); err != nil {
+			return nil, err
+		}
+	}
+
+	// Clusters are converted before they are merged, so that a missing
+	// rate fails the request rather than dropping the cluster.
+	for _, history := range histories {
+		if err := converter.ConvertHistory(history, step); err != nil {
+			return nil, err
+		}
+	}
+	if len(clusters) == 1 {
+		return histories[0], nil
+	}
+	return mergeCostHistories(duration.String(), histories), nil
+}
+
+// mergeCostHistories sums the histories of several clusters point by point.
+func mergeCostHistories(period string, histories []*internal.CostHistory) *internal.CostHistory {
+	merged := &internal.CostHistory{
+		Currency:  histories[0].Currency,
+		Period:    period,
+		StartTime: histories[0].StartTime,
+		EndTime:   histories[0].EndTime,
+	}
+
+	points := make(map[int64]*internal.CostHistoryPoint)
+	for _, history := range histories {
+		for _, p := range history.Data {
+			point, exists := points[p.Timestamp.Unix()]
+			if !exists {
+				point = &internal.CostHistoryPoint{Timestamp: p.Timestamp, PricingPeriod: p.PricingPeriod}
+				points[p.Timestamp.Unix()] = point
+			}
+			if point.PricingPeriod != p.PricingPeriod {
+				// Clusters with different schedules have no common period.
+				point.PricingPeriod = ""
+			}
+			point.CPUCost = point.CPUCost.Add(p.CPUCost)
+			point.MemoryCost = point.MemoryCost.Add(p.MemoryCost)
+			point.StorageCost = point.StorageCost.Add(p.StorageCost)
+			point.NetworkCost = point.NetworkCost.Add(p.NetworkCost)
+			point.TotalCost = point.TotalCost.Add(p.TotalCost)
+		}
+	}
+
+	// A period's hours are the longest any cluster spent in it.
+	periods := make(map[string]int)
+	for _, history := range histories {
+		for _, period := range history.PricingPeriods {
+			i, ok := periods[period.Period]
+			if !ok {
+				i = len(merged.PricingPeriods)
+				periods[period.Period] = i
+				merged.PricingPeriods = append(merged.PricingPeriods, internal.PeriodCost{Period: period.Period})
+			}
+			merged.PricingPeriods[i].Hours = max(merged.PricingPeriods[i].Hours, period.Hours)
+			merged.PricingPeriods[i].Cost = merged.PricingPeriods[i].Cost.Add(period.Cost)
+		}
+	}
+
+	merged.Data = make([]internal.CostHistoryPoint, 0, len(points))
+	for _, point := range points {
+		merged.Data = append(merged.Data, *point)
+	}
+	sort.Slice(merged.Data, func(i, j int) bool {
+		return merged.Data[i].Timestamp.Before(merged.Data[j].Timestamp)
+	})
+
+	return merged
+}
+
+func rollupCluster(name string, overview *internal.CostOverview, err error) internal.ClusterCost {
+	rollup := internal.ClusterCost{
+		Cluster:   name,
+		Timestamp: time.Now(),
+	}
+	if err != nil {
+		rollup.Error = err.Error()
+		return rollup
+	}
+
+	return RollupNamespaces(name, overview.NamespacesCost)
+}
+
+// RollupNamespaces sums the costs of a cluster's namespaces.
+func RollupNamespaces(name string, namespaces []internal.NamespaceCost) internal.ClusterCost {
+	rollup := internal.ClusterCost{
+		Cluster:   name,
+		Timestamp: time.Now(),
+	}
+
+	for _, ns := range namespaces {
+		rollup.CPUCost = rollup.CPUCost.Add(ns.CPUCost)
+		rollup.MemoryCost = rollup.MemoryCost.Add(ns.MemoryCost)
+		rollup.StorageCost = rollup.StorageCost.Add(ns.StorageCost)
+		rollup.NetworkCost = rollup.NetworkCost.Add(ns.NetworkCost)
+		rollup.TotalCost = rollup.TotalCost.Add(ns.TotalCost)
+		rollup.ListCost = rollup.ListCost.Add(ns.ListCost)
+		rollup.Savings = rollup.Savings.Add(ns.Savings)
+		rollup.PodCount += ns.PodCount
+	}
+	rollup.NamespaceCount = len(namespaces)
+
+	return rollup
+}
+
+func aggregationKey(aggregate string) (func(internal.NamespaceCost, internal.PodCost) string, error) {
+	switch {
+	case aggregate == "" || aggregate == "namespace":
+		return func(ns internal.NamespaceCost, _ internal.PodCost) string {
+			return ns.Namespace
+		}, nil
+	case aggregate == "cluster":
+		return func(ns internal.NamespaceCost, _ internal.PodCost) string {
+			return ns.Cluster
+		}, nil
+	case aggregate == "controller":
+		return func(ns internal.NamespaceCost, pod internal.PodCost) string {
+			if pod.Controller == "" {
+				return ns.Namespace + "/pod/" + pod.Name
+			}
+			return ns.Namespace + "/" + strings.ToLower(pod.ControllerKind) + "/" + pod.Controller
+		}, nil
+	case strings.HasPrefix(aggregate, "label:") && len(aggregate) > len("label:"):
+		label := strings.TrimPrefix(aggregate, "label:")
+		return func(ns internal.NamespaceCost, pod internal.PodCost) string {
+			if value, ok := pod.Labels[label]; ok {
+				return value
+			}
+			if value, ok := ns.Labels[label]; ok {
+				return value
+			}
+			return unallocatedKey
+		}, nil
+	default:
+		return nil, ErrInvalidAggregate
+	}
+}
+
+// externalAggregationLabel is the label of an external cost that holds its
+// key under a valid aggregate.
+func externalAggregationLabel(aggregate string) string {
+	if label, ok := strings.CutPrefix(aggregate, "label:"); ok {
+		return label
+	}
+	if aggregate == "cluster" || aggregate == "controller" {
+		return aggregate
+	}
+	return "namespace"
+}
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/observability"
+	"github.com/SinghaAnirban005/KuBudget/pkg/prometheus"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	hoursPerMonth = 730
+
+	riskLow    = "low"
+	riskMedium = "medium"
+	riskHigh   = "high"
+)
+
+var promLabelInvalid = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// nodeSample is one node observed in one hour of history.
+type nodeSample struct {
+	instanceType string
+	spot         bool
+	list         float64
+}
+
+// CommitmentService recommends reserved instance and savings plan purchases
+// from the hourly node history kube-state-metrics records in Prometheus.
+// Instance types and capacity types come from kube_node_labels, so
+// kube-state-metrics must expose the node.kubernetes.io/instance-type and
+// capacity-type labels through --metric-labels-allowlist.
+type CommitmentService struct {
+	clusters *ClusterService
+	config   *internal.ConfigStore
+	logger   *slog.Logger
+}
+
+func NewCommitmentService(clusters *ClusterService, config *internal.ConfigStore, logger *slog.Logger) *CommitmentService {
+	return &CommitmentService{
+		clusters: clusters,
+		config:   config,
+		logger:   logger,
+	}
+}
+
+// Recommend loads the node history of the longest configured window and
+// computes purchase options for every window. Clusters whose history cannot
+// be read are reported and skipped.
+func (s *CommitmentService) Recommend(ctx context.Context) (*internal.CommitmentRecommendations, error) {
+	ctx, span := observability.StartSpan(ctx, "CommitmentService.Recommend")
+	defer span.End()
+
+	cfg := s.config.Current()
+	recommendations := &internal.CommitmentRecommendations{
+		GeneratedAt: time.Now(),
+		Currency:    cfg.Currency.Base,
+		TermMonths:  cfg.Commitments.TermMonths,
+		Windows:     make([]internal.CommitmentWindow, 0, len(cfg.Commitments.WindowDays)),
+	}
+
+	end := recommendations.GeneratedAt.UTC().Truncate(time.Hour)
+	start := end.Add(-time.Duration(slices.Max(cfg.Commitments.WindowDays)) * 24 * time.Hour)
+
+	history := make(map[time.Time][]nodeSample)
+	var read int
+	for _, cluster := range s.clusters.Clusters() {
+		if err := s.loadHistory(ctx, cluster, cfg, start, end, history); err != nil {
+			recommendations.Errors = append(recommendations.Errors, fmt.Sprintf("cluster %s: %v", cluster.Name, err))
+			continue
+		}
+		read++
+	}
+	if read == 0 {
+		return nil, fmt.Errorf("no node history could be read: %s", strings.Join(recommendations.Errors, "; "))
+	}
+
+	for _, days := range cfg.Commitments.WindowDays {
+		windowStart := end.Add(-time.Duration(days) * 24 * time.Hour)
+		hours := make([][]nodeSample, 0, days*24)
+		for hour, samples := range history {
+			if !hour.Before(windowStart) {
+				hours = append(hours, samples)
+			}
+		}
+
+		window := commitmentWindow(hours, cfg.Discounts, cfg.Commitments)
+		window.Days, window.Start, window.End = days, windowStart, end
+		recommendations.Windows = append(recommendations.Windows, window)
+	}
+
+	return recommendations, nil
+}
+
+// loadHistory adds one sample per node and hour of cluster to history.
+// Nodes are priced at the cluster's rates from their capacity at the time.
+func (s *CommitmentService) loadHistory(ctx context.Context, cluster *Cluster, cfg *internal.Config, start, end time.Time, history map[time.Time][]nodeSample) error {
+	type nodeHour struct {
+		hour time.Time
+		node string
+	}
+
+	capacity := func(resource string) (map[nodeHour]float64, error) {
+		query := prometheus.NewSelector("kube_node_status_capacity").Eq("resource", resource).String()
+		series, err := cluster.PromClient.QueryRangeSeries(ctx, query, start, end, time.Hour)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query node %s capacity: %v", resource, err)
+		}
+		values := make(map[nodeHour]float64)
+		for _, s := range series {
+			for _, sample := range s.Samples {
+				values[nodeHour{sample.Timestamp.Truncate(time.Hour), s.Labels["node"]}] = sample.Value
+			}
+		}
+		return values, nil
+	}
+
+	cpu, err := capacity("cpu")
+	if err != nil {
+		return err
+	}
+	memory, err := capacity("memory")
+	if err != nil {
+		return err
+	}
+
+	series, err := cluster.PromClient.QueryRangeSeries(ctx, prometheus.NewSelector("kube_node_labels").String(), start, end, time.Hour)
+	if err != nil {
+		return fmt.Errorf("failed to query node labels: %v", err)
+	}
+	labels := make(map[nodeHour]map[string]string)
+	for _, s := range series {
+		nodeLabels := nodeLabelsFromMetric(s.Labels, cfg.Discounts.Spot.Labels)
+		for _, sample := range s.Samples {
+			labels[nodeHour{sample.Timestamp.Truncate(time.Hour), s.Labels["node"]}] = nodeLabels
+		}
+	}
+
+	pricing := cfg.PricingFor(cluster.Name)
+	for key, cores := range cpu {
+		nodeLabels := labels[key]
+		history[key.hour] = append(history[key.hour], nodeSample{
+			instanceType: instanceType(nodeLabels),
+			spot:         isSpot(nodeLabels, cfg.Discounts.Spot.Labels),
+			list:         cores*pricing.CPUCostPerHour + memory[key]/bytesPerGB*pricing.MemoryCostPerGB,
+		})
+	}
+	return nil
+}
+
+// commitmentWindow computes the options for the hours of one window. Usage
+// is the hourly list cost of on-demand nodes, net of the configured
+// reservations; savings plan usage is also net of the configured savings
+// plans.
+func commitmentWindow(hours [][]nodeSample, discounts internal.DiscountsConfig, commitments internal.CommitmentsConfig) internal.CommitmentWindow {
+	window := internal.CommitmentWindow{
+		HoursObserved:     len(hours),
+		ReservedInstances: make([]internal.ReservationRecommendation, 0),
+		SavingsPlans:      make([]internal.CommitmentOption, 0),
+	}
+	if len(hours) == 0 {
+		return window
+	}
+
+	reserved := make(map[string]int, len(discounts.Reservations))
+	if discounts.Enabled {
+		for _, reservation := range discounts.Reservations {
+			reserved[reservation.InstanceType] += reservation.Count
+		}
+	}
+
+	type typeStats struct {
+		hours int
+		cost  float64
+	}
+	families := make(map[string][]float64)
+	types := make(map[string]map[string]*typeStats)
+	total := make([]float64, len(hours))
+
+	for i, samples := range hours {
+		covered := make(map[string]int)
+		for _, sample := range samples {
+			if sample.spot {
+				continue
+			}
+			if covered[sample.instanceType] < reserved[sample.instanceType] {
+				covered[sample.instanceType]++
+				continue
+			}
+			total[i] += sample.list
+			if sample.instanceType == "" {
+				continue
+			}
+
+			family := instanceFamily(sample.instanceType)
+			if _, ok := families[family]; !ok {
+				families[family] = make([]float64, len(hours))
+				types[family] = make(map[string]*typeStats)
+			}
+			families[family][i] += sample.list
+
+			stats, ok := types[family][sample.instanceType]
+			if !ok {
+				stats = &typeStats{}
+				types[family][sample.instanceType] = stats
+			}
+			stats.hours++
+			stats.cost += sample.list
+		}
+	}
+
+	var existing float64
+	if discounts.Enabled {
+		for _, plan := range discounts.SavingsPlans {
+			existing += plan.CommitmentPerHour / (1 - plan.Discount)
+		}
+	}
+	for i := range total {
+		window.AverageOnDemandCost += total[i] / float64(len(total))
+		total[i] = math.Max(0, total[i]-existing)
+	}
+
+	for family, usage := range families {
+		var dominant string
+		for name, stats := range types[family] {
+			if dominant == "" || stats.hours > types[family][dominant].hours ||
+				(stats.hours == types[family][dominant].hours && name < dominant) {
+				dominant = name
+			}
+		}
+		stats := types[family][dominant]
+		unit := stats.cost / float64(stats.hours)
+
+		recommendation := internal.ReservationRecommendation{
+			Family:             family,
+			InstanceType:       dominant,
+			InstanceHourlyCost: unit,
+			Options:            commitmentOptions(usage, commitments.Percentiles, commitments.ReservedDiscount, commitments.TermMonths, unit),
+		}
+		for _, cost := range usage {
+			recommendation.AverageOnDemandCost += cost / float64(len(usage))
+		}
+		if len(recommendation.Options) > 0 {
+			window.ReservedInstances = append(window.ReservedInstances, recommendation)
+		}
+	}
+	sort.Slice(window.ReservedInstances, func(i, j int) bool {
+		return window.ReservedInstances[i].Family < window.ReservedInstances[j].Family
+	})
+
+	window.SavingsPlans = commitmentOptions(total, commitments.Percentiles, commitments.SavingsPlanDiscount, commitments.TermMonths, 0)
+	return window
+}
+
+// commitmentOptions offers one commitment per percentile of hourly usage
+// and replays the usage against it. When unit is positive, commitments are
+// rounded down to whole instances costing unit per hour. Percentiles that
+// round to the same commitment are offered once.
+func commitmentOptions(usage, percentiles []float64, discount float64, termMonths int, unit float64) []internal.CommitmentOption {
+	sorted := append([]float64(nil), usage...)
+	sort.Float64s(sorted)
+
+	options := make([]internal.CommitmentOption, 0, len(percentiles))
+	seen := make(map[float64]bool, len(percentiles))
+	for _, percentile := range percentiles {
+		rank := int(math.Ceil(percentile/100*float64(len(sorted)))) - 1
+		level := sorted[max(0, min(rank, len(sorted)-1))]
+
+		option := internal.CommitmentOption{Percentile: percentile, BreakEvenUtilization: 1 - discount}
+		if unit > 0 {
+			option.Instances = int(level/unit + 1e-9)
+			level = float64(option.Instances) * unit
+		}
+		if level <= 0 || seen[level] {
+			continue
+		}
+		seen[level] = true
+
+		var covered, total float64
+		var under int
+		for _, cost := range usage {
+			covered += min(cost, level)
+			total += cost
+			if cost < level {
+				under++
+			}
+		}
+		hours := float64(len(usage))
+
+		option.CoveredPerHour = level
+		option.CommitmentPerHour = level * (1 - discount)
+		option.Coverage = covered / total
+		option.Utilization = covered / (level * hours)
+		option.UnderutilizedHours = float64(under) / hours
+		option.MonthlySavings = (covered/hours - option.CommitmentPerHour) * hoursPerMonth
+		if option.Utilization > option.BreakEvenUtilization {
+			months := float64(termMonths) * option.BreakEvenUtilization / option.Utilization
+			option.BreakEvenMonths = &months
+		}
+		option.Risk = commitmentRisk(option.Utilization, discount)
+
+		options = append(options, option)
+	}
+	return options
+}
+
+// commitmentRisk rates how close a commitment's utilization is to its
+// break-even point, as a fraction of the discount that is kept.
+func commitmentRisk(utilization, discount float64) string {
+	margin := (utilization - (1 - discount)) / discount
+	switch {
+	case margin >= 0.9:
+		return riskLow
+	case margin >= 0.5:
+		return riskMedium
+	default:
+		return riskHigh
+	}
+}
+
+// instanceFamily groups instance types that reservations can be shared
+// between: m5.xlarge is m5, n2-standard-4 is n2 and Standard_D4s_v3 is
+// Dsv3.
+func instanceFamily(instanceType string) string {
+	if name, ok := strings.CutPrefix(instanceType, "Standard_"); ok {
+		size, version, _ := strings.Cut(name, "_")
+		series := strings.TrimLeftFunc(size, unicode.IsLetter)
+		suffix := strings.TrimLeftFunc(series, unicode.IsDigit)
+		return size[:len(size)-len(series)] + suffix + version
+	}
+	if family, _, ok := strings.Cut(instanceType, "."); ok {
+		return family
+	}
+	if family, _, ok := strings.Cut(instanceType, "-"); ok {
+		return family
+	}
+	return instanceType
+}
+
+// nodeLabelsFromMetric recovers the node labels used for pricing from a
+// kube_node_labels series, where kube-state-metrics has renamed each label
+// to label_<name> in snake case.
+func nodeLabelsFromMetric(metric, extraSpot map[string]string) map[string]string {
+	keys := []string{corev1.LabelInstanceTypeStable, corev1.LabelInstanceType}
+	for key := range spotLabels {
+		keys = append(keys, key)
+	}
+	for key := range extraSpot {
+		keys = append(keys, key)
+	}
+
+	labels := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if value, ok := metric[promLabelName(key)]; ok {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+// promLabelName is the name kube-state-metrics gives a Kubernetes label.
+func promLabelName(key string) string {
+	var b strings.Builder
+	runes := []rune(key)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return "label_" + promLabelInvalid.ReplaceAllString(b.String(), "_")
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+	"github.com/SinghaAnirban005/KuBudget/pkg/observability"
+	promclient "github.com/prometheus/client_golang/prometheus"
+)
+
+// otherPodLabel is the pod label used for the series that aggregates every
+// pod beyond the exporter's cardinality cap.
+const otherPodLabel = "__other__"
+
+var (
+	podCostDesc = promclient.NewDesc(
+		"kubudget_pod_cost_hourly",
+		"Hourly cost of a pod broken down by resource.",
+		[]string{"cluster", "namespace", "pod", "node", "resource"}, nil,
+	)
+	namespaceCostDesc = promclient.NewDesc(
+		"kubudget_namespace_cost_hourly",
+		"Hourly cost of a namespace broken down by resource.",
+		[]string{"cluster", "namespace", "resource"}, nil,
+	)
+	nodeCostDesc = promclient.NewDesc(
+		"kubudget_node_cost_hourly",
+		"Hourly cost of a node broken down by resource.",
+		[]string{"cluster", "node", "resource"}, nil,
+	)
+	idleCostDesc = promclient.NewDesc(
+		"kubudget_idle_cost_hourly",
+		"Hourly cost of a node not attributed to any pod.",
+		[]string{"cluster", "node"}, nil,
+	)
+	lastRefreshDesc = promclient.NewDesc(
+		"kubudget_cost_last_refresh_timestamp_seconds",
+		"Unix time of the last successful cost refresh.",
+		nil, nil,
+	)
+)
+
+type costSnapshot struct {
+	pods        []internal.PodCost
+	nodes       []internal.NodeCost
+	lastRefresh time.Time
+}
+
+// CostExporter publishes the costs of every configured cluster as
+// Prometheus metrics. Costs are recomputed in the background on a fixed interval so
+// that scrapes never fan out into Kubernetes and Prometheus calls.
+type CostExporter struct {
+	clusters *ClusterService
+	config   *internal.ConfigStore
+	logger   *slog.Logger
+
+	mu       sync.RWMutex
+	snapshot costSnapshot
+}
+
+func NewCostExporter(clusters *ClusterService, config *internal.ConfigStore, logger *slog.Logger) *CostExporter {
+	return &CostExporter{
+		clusters: clusters,
+		config:   config,
+		logger:   logger,
+	}
+}
+
+// Run refreshes the cost snapshot until ctx is cancelled.
+func (e *CostExporter) Run(ctx context.Context) {
+	for {
+		if err := e.Refresh(ctx); err != nil {
+			e.logger.ErrorContext(ctx, "failed to refresh cost metrics", "error", err)
+		}
+
+		// The interval is re-read every cycle so a reloaded config applies
+		// from the next refresh.
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.config.Current().Collectors.Interval):
+		}
+	}
+}
+
+func (e *CostExporter) Refresh(ctx context.Context) (err error) {
+	ctx, span := observability.StartSpan(ctx, "CostExporter.Refresh")
+	defer func(start time.Time) {
+		observability.ObserveCollectorRun("cost_exporter", start, err)
+		observability.EndSpan(span, err)
+	}(time.Now())
+
+	clusters := e.clusters.Clusters()
+
+	pods, err := e.clusters.GetPodCosts(ctx, clusters, "")
+	if err != nil {
+		return err
+	}
+
+	nodes, err := e.clusters.GetNodeCosts(ctx, clusters)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.snapshot = costSnapshot{
+		pods:        pods,
+		nodes:       nodes,
+		lastRefresh: time.Now(),
+	}
+	e.mu.Unlock()
+
+	return nil
+}
+
+// LastRefresh returns the time of the last successful refresh, or the zero
+// time if the exporter has not completed one yet.
+func (e *CostExporter) LastRefresh() time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.snapshot.lastRefresh
+}
+
+func (e *CostExporter) Describe(ch chan<- *promclient.Desc) {
+	ch <- podCostDesc
+	ch <- namespaceCostDesc
+	ch <- nodeCostDesc
+	ch <- idleCostDesc
+	ch <- lastRefreshDesc
+}
+
+func (e *CostExporter) Collect(ch chan<- promclient.Metric) {
+	e.mu.RLock()
+	snapshot := e.snapshot
+	e.mu.RUnlock()
+
+	observability.ObserveCacheLookup("cost_snapshot", !snapshot.lastRefresh.IsZero())
+	if snapshot.lastRefresh.IsZero() {
+		return
+	}
+
+	ch <- promclient.MustNewConstMetric(lastRefreshDesc, promclient.GaugeValue, float64(snapshot.lastRefresh.Unix()))
+
+	e.collectPods(ch, snapshot.pods, e.config.Current().Collectors.ExporterMaxPods)
+	e.collectNamespaces(ch, snapshot.pods)
+	e.collectNodes(ch, snapshot.nodes, snapshot.pods)
+}
+
+// collectPods emits per-pod series for the most expensive pods and folds
+// the remainder into a single "__other__" pod per namespace so the number
+// of series stays bounded regardless of cluster size.
+func (e *CostExporter) collectPods(ch chan<- promclient.Metric, pods []internal.PodCost, maxPods int) {
+	sorted := make([]internal.PodCost, len(pods))
+	copy(sorted, pods)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].TotalCost.Cmp(sorted[j].TotalCost) > 0
+	})
+
+	var others []internal.PodCost
+	for i, pod := range sorted {
+		if maxPods <= 0 || i < maxPods {
+			emitResources(ch, podCostDesc, pod.CPUCost, pod.MemoryCost, pod.StorageCost, pod.NetworkCost, pod.EphemeralStorageCost, pod.ExtendedCost, pod.Cluster, pod.Namespace, pod.Name, pod.Node)
+			continue
+		}
+		others = append(others, pod)
+	}
+
+	for _, other := range sumByNamespace(others) {
+		emitResources(ch, podCostDesc, other.CPUCost, other.MemoryCost, other.StorageCost, other.NetworkCost, other.EphemeralStorageCost, other.ExtendedCost, other.Cluster, other.Namespace, otherPodLabel, "")
+	}
+}
+
+func (e *CostExporter) collectNamespaces(ch chan<- promclient.Metric, pods []internal.PodCost) {
+	for _, ns := range sumByNamespace(pods) {
+		emitResources(ch, namespaceCostDesc, ns.CPUCost, ns.MemoryCost, ns.StorageCost, ns.NetworkCost, ns.EphemeralStorageCost, ns.ExtendedCost, ns.Cluster, ns.Namespace)
+	}
+}
+
+// sumByNamespace groups pods by cluster and namespace and sums each group
+// into a namespace.
+func sumByNamespace(pods []internal.PodCost) []internal.NamespaceCost {
+	type namespaceKey struct{ cluster, namespace string }
+	grouped := make(map[namespaceKey][]internal.PodCost)
+	for _, pod := range pods {
+		key := namespaceKey{pod.Cluster, pod.Namespace}
+		grouped[key] = append(grouped[key], pod)
+	}
+
+	namespaces := make([]internal.NamespaceCost, 0, len(grouped))
+	for key, pods := range grouped {
+		ns := internal.NamespaceCost{Cluster: key.cluster, Namespace: key.namespace, PodCount: len(pods)}
+		ns.SetPods(pods)
+		namespaces = append(namespaces, ns)
+	}
+	return namespaces
+}
+
+// collectNodes emits each node's costs and its idle cost: what its CPU,
+// memory, root disk and extended resources cost beyond what is charged to
+// its pods.
+func (e *CostExporter) collectNodes(ch chan<- promclient.Metric, nodes []internal.NodeCost, pods []internal.PodCost) {
+	type nodeKey struct{ cluster, node string }
+	allocated := make(map[nodeKey]money.Amount)
+	for _, pod := range pods {
+		key := nodeKey{pod.Cluster, pod.Node}
+		allocated[key] = money.Sum(allocated[key], pod.CPUCost, pod.MemoryCost, pod.EphemeralStorageCost, pod.ExtendedCost)
+	}
+
+	for _, node := range nodes {
+		ch <- promclient.MustNewConstMetric(nodeCostDesc, promclient.GaugeValue, node.CPUCost.Float64(), node.Cluster, node.Name, "cpu")
+		ch <- promclient.MustNewConstMetric(nodeCostDesc, promclient.GaugeValue, node.MemoryCost.Float64(), node.Cluster, node.Name, "memory")
+		ch <- promclient.MustNewConstMetric(nodeCostDesc, promclient.GaugeValue, node.StorageCost.Float64(), node.Cluster, node.Name, "storage")
+		ch <- promclient.MustNewConstMetric(nodeCostDesc, promclient.GaugeValue, node.BootDiskCost.Float64(), node.Cluster, node.Name, "boot-disk")
+		ch <- promclient.MustNewConstMetric(nodeCostDesc, promclient.GaugeValue, node.ExtendedCost.Float64(), node.Cluster, node.Name, "extended")
+
+		idle := money.Sum(node.CPUCost, node.MemoryCost, node.BootDiskCost, node.ExtendedCost).Sub(allocated[nodeKey{node.Cluster, node.Name}])
+		if idle.IsNegative() {
+			idle = money.Zero
+		}
+		ch <- promclient.MustNewConstMetric(idleCostDesc, promclient.GaugeValue, idle.Float64(), node.Cluster, node.Name)
+	}
+}
+
+func emitResources(ch chan<- promclient.Metric, desc *promclient.Desc, cpu, memory, storage, network, ephemeralStorage, extended money.Amount, labels ...string) {
+	ch <- promclient.MustNewConstMetric(desc, promclient.GaugeValue, cpu.Float64(), append(labels, "cpu")...)
+	ch <- promclient.MustNewConstMetric(desc, promclient.GaugeValue, memory.Float64(), append(labels, "memory")...)
+	ch <- promclient.MustNewConstMetric(desc, promclient.GaugeValue, storage.Float64(), append(labels, "storage")...)
+	ch <- promclient.MustNewConstMetric(desc, promclient.GaugeValue, network.Float64(), append(labels, "network")...)
+	ch <- promclient.MustNewConstMetric(desc, promclient.GaugeValue, ephemeralStorage.Float64(), append(labels, "ephemeral-storage")...)
+	ch <- promclient.MustNewConstMetric(desc, promclient.GaugeValue, extended.Float64(), append(labels, "extended")...)
+}
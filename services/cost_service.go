@@ -3,56 +3,81 @@ package services
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/SinghaAnirban005/KuBudget/internal"
 	"github.com/SinghaAnirban005/KuBudget/pkg/kubernetes"
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+	"github.com/SinghaAnirban005/KuBudget/pkg/observability"
 	"github.com/SinghaAnirban005/KuBudget/pkg/prometheus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// CostService computes costs for a single cluster.
 type CostService struct {
-	k8sClient  *kubernetes.Client
-	promClient *prometheus.Client
-	config     *internal.Config
+	cluster     string
+	k8sClient   *kubernetes.Client
+	promClient  *prometheus.Client
+	config      *internal.ConfigStore
+	adjustments *Adjustments
+	prices      *PriceHistory
+	logger      *slog.Logger
 }
 
-func NewCostService(k8sClient *kubernetes.Client, promClient *prometheus.Client) *CostService {
+func NewCostService(cluster string, k8sClient *kubernetes.Client, promClient *prometheus.Client, config *internal.ConfigStore, adjustments *Adjustments, prices *PriceHistory, logger *slog.Logger) *CostService {
 	return &CostService{
-		k8sClient:  k8sClient,
-		promClient: promClient,
-		config:     internal.LoadConfig(),
+		cluster:     cluster,
+		k8sClient:   k8sClient,
+		promClient:  promClient,
+		config:      config,
+		adjustments: adjustments,
+		prices:      prices,
+		logger:      logger,
 	}
 }
 
+func (s *CostService) Cluster() string {
+	return s.cluster
+}
+
 func (s *CostService) GetCostOverview(ctx context.Context) (*internal.CostOverview, error) {
-	namespaces, err := s.k8sClient.GetNamespaces()
+	ctx, span := observability.StartSpan(ctx, "CostService.GetCostOverview")
+	defer span.End()
+
+	namespaces, err := s.k8sClient.GetNamespaces(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get namespaces: %v", err)
 	}
 
-	var totalCPUCost, totalMemoryCost, totalStorageCost float64
+	var total internal.CostBreakdown
 	namespaceCosts := make([]internal.NamespaceCost, 0, len(namespaces.Items))
+	inputs := s.costInputs(ctx, "")
 
 	for _, ns := range namespaces.Items {
-		nsCost, err := s.calculateNamespaceCost(ctx, ns.Name)
+		nsCost, err := s.calculateNamespaceCost(ctx, ns.Name, inputs)
 		if err != nil {
+			s.logger.WarnContext(ctx, "skipping namespace", "namespace", ns.Name, "error", err)
 			continue
 		}
+		nsCost.Labels = ns.Labels
 
-		totalCPUCost += nsCost.CPUCost
-		totalMemoryCost += nsCost.MemoryCost
-		totalStorageCost += nsCost.StorageCost
+		total.CPUCost = total.CPUCost.Add(nsCost.CPUCost)
+		total.MemoryCost = total.MemoryCost.Add(nsCost.MemoryCost)
+		total.StorageCost = total.StorageCost.Add(nsCost.StorageCost)
+		total.NetworkCost = total.NetworkCost.Add(nsCost.NetworkCost)
+		total.TotalCost = total.TotalCost.Add(nsCost.TotalCost)
 
 		namespaceCosts = append(namespaceCosts, *nsCost)
 	}
 
 	return &internal.CostOverview{
-		TotalCost: internal.CostBreakdown{
-			CPUCost:     totalCPUCost,
-			MemoryCost:  totalMemoryCost,
-			StorageCost: totalStorageCost,
-		},
+		Cluster:        s.cluster,
+		TotalCost:      total,
 		NamespacesCost: namespaceCosts,
 		Timestamp:      time.Now(),
 	}, nil
@@ -60,51 +85,121 @@ func (s *CostService) GetCostOverview(ctx context.Context) (*internal.CostOvervi
 }
 
 func (s *CostService) GetCostHistory(ctx context.Context, duration time.Duration, step time.Duration, namespace string) (*internal.CostHistory, error) {
+	ctx, span := observability.StartSpan(ctx, "CostService.GetCostHistory")
+	defer span.End()
+
 	endTime := time.Now()
 	startTime := endTime.Add(-duration)
 
-	cpuQuery := "rate(container_cpu_usage_seconds_total[5m])"
-	if namespace != "" {
-		cpuQuery = fmt.Sprintf(`rate(container_cpu_usage_seconds_total{namespace="%s"}[5m])`, namespace)
+	cpuResult, memResult, err := s.usageHistory(ctx, startTime, endTime, step, namespace)
+	if err != nil {
+		return nil, err
 	}
 
-	cpuResult, err := s.promClient.QueryRange(ctx, cpuQuery, startTime, endTime, step)
+	return s.costHistory(cpuResult, memResult, startTime, endTime, step, duration.String(), s.pricingAt), nil
+}
+
+// CompareCostHistory prices the usage of [start, end) twice: as it was
+// priced, with the price version in effect at each point, and under
+// version throughout. A version without this cluster uses its current
+// pricing.
+func (s *CostService) CompareCostHistory(ctx context.Context, start, end time.Time, step time.Duration, namespace string, version internal.PriceVersion) (actual, repriced *internal.CostHistory, err error) {
+	ctx, span := observability.StartSpan(ctx, "CostService.CompareCostHistory")
+	defer span.End()
+
+	cpuResult, memResult, err := s.usageHistory(ctx, start, end, step, namespace)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get CPU history: %v", err)
+		return nil, nil, err
 	}
 
-	memQuery := "container_memory_usage_bytes"
-	if namespace != "" {
-		memQuery = fmt.Sprintf(`container_memory_usage_bytes{namespace="%s"}`, namespace)
+	pricing, ok := version.Pricing[s.cluster]
+	if !ok {
+		pricing = s.config.Current().PricingFor(s.cluster)
 	}
+	period := end.Sub(start).String()
+	actual = s.costHistory(cpuResult, memResult, start, end, step, period, s.pricingAt)
+	repriced = s.costHistory(cpuResult, memResult, start, end, step, period, func(time.Time) internal.PricingConfig {
+		return pricing
+	})
+	return actual, repriced, nil
+}
+
+// usageHistory queries CPU and memory usage over [start, end).
+func (s *CostService) usageHistory(ctx context.Context, start, end time.Time, step time.Duration, namespace string) (*prometheus.RangeQueryResult, *prometheus.RangeQueryResult, error) {
+	cpuQuery := prometheus.Rate(prometheus.NewSelector("container_cpu_usage_seconds_total").EqIfSet("namespace", namespace), 5*time.Minute)
 
-	memResult, err := s.promClient.QueryRange(ctx, memQuery, startTime, endTime, step)
+	cpuResult, err := s.promClient.QueryRange(ctx, cpuQuery, start, end, step)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get memory history: %v", err)
+		return nil, nil, fmt.Errorf("failed to get CPU history: %v", err)
 	}
 
-	historyPoints := s.convertToCostHistory(*cpuResult, *memResult)
+	memQuery := prometheus.NewSelector("container_memory_usage_bytes").EqIfSet("namespace", namespace).String()
+
+	memResult, err := s.promClient.QueryRange(ctx, memQuery, start, end, step)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get memory history: %v", err)
+	}
+
+	return cpuResult, memResult, nil
+}
+
+func (s *CostService) costHistory(cpuResult, memResult *prometheus.RangeQueryResult, start, end time.Time, step time.Duration, period string, pricingAt func(time.Time) internal.PricingConfig) *internal.CostHistory {
+	historyPoints := s.convertToCostHistory(*cpuResult, *memResult, pricingAt)
 
 	return &internal.CostHistory{
-		Period:    duration.String(),
-		StartTime: startTime,
-		EndTime:   endTime,
-		Data:      historyPoints,
-	}, nil
+		Cluster:        s.cluster,
+		Period:         period,
+		StartTime:      start,
+		EndTime:        end,
+		Data:           historyPoints,
+		PricingPeriods: historyPeriods(historyPoints, step),
+	}
+}
+
+// pricingAt returns the pricing of the cluster in effect at t.
+func (s *CostService) pricingAt(t time.Time) internal.PricingConfig {
+	return s.prices.PricingAt(s.config.Current(), s.cluster, t)
 }
 
 func (s *CostService) GetNodeCosts(ctx context.Context) ([]internal.NodeCost, error) {
-	nodes, err := s.k8sClient.GetNodes()
+	ctx, span := observability.StartSpan(ctx, "CostService.GetNodeCosts")
+	defer span.End()
+
+	nodes, err := s.k8sClient.GetNodes(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get nodes: %v", err)
 	}
 
+	pricing := s.config.Current().PricingFor(s.cluster)
+	var pods []corev1.Pod
+	if len(pricing.ExtendedResources) > 0 {
+		list, err := s.k8sClient.GetPods(ctx, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pods: %v", err)
+		}
+		pods = list.Items
+	}
+
 	costs := make([]internal.NodeCost, 0, len(nodes.Items))
 	for _, node := range nodes.Items {
-		cost, err := s.calculateNodeCost(ctx, node.Namespace, "", node.Name)
-		if err != nil {
-			continue
+		cost := s.calculateNodeCost(ctx, node, pricing)
+
+		extended, idle := nodeExtendedResourceCosts(node, pods, pricing)
+		cost.ExtendedResources = extended
+		cost.ExtendedCost = sumCosts(extended)
+		cost.ExtendedIdleCost = sumCosts(idle)
+		for resource, idleCost := range idle {
+			if isGPU(resource) {
+				cost.GPUIdleCost = cost.GPUIdleCost.Add(idleCost)
+			}
 		}
+		cost.TotalCost = cost.TotalCost.Add(cost.ExtendedCost)
+
+		disk := node.Status.Capacity[corev1.ResourceEphemeralStorage]
+		cost.BootDiskCost = money.FromFloat(pricing.EphemeralStorageCostPerGB).Mul(disk.AsApproximateFloat64() / bytesPerGB)
+		cost.TotalCost = cost.TotalCost.Add(cost.BootDiskCost)
+
+		s.adjustments.ApplyNode(cost)
 		costs = append(costs, *cost)
 	}
 
@@ -112,17 +207,23 @@ func (s *CostService) GetNodeCosts(ctx context.Context) ([]internal.NodeCost, er
 }
 
 func (s *CostService) GetNamespaceCosts(ctx context.Context) ([]internal.NamespaceCost, error) {
-	namespaces, err := s.k8sClient.GetNamespaces()
+	ctx, span := observability.StartSpan(ctx, "CostService.GetNamespaceCosts")
+	defer span.End()
+
+	namespaces, err := s.k8sClient.GetNamespaces(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get namespaces: %v", err)
 	}
 
 	costs := make([]internal.NamespaceCost, 0, len(namespaces.Items))
+	inputs := s.costInputs(ctx, "")
 	for _, ns := range namespaces.Items {
-		cost, err := s.calculateNamespaceCost(ctx, ns.Name)
+		cost, err := s.calculateNamespaceCost(ctx, ns.Name, inputs)
 		if err != nil {
+			s.logger.WarnContext(ctx, "skipping namespace", "namespace", ns.Name, "error", err)
 			continue
 		}
+		cost.Labels = ns.Labels
 		costs = append(costs, *cost)
 	}
 
@@ -130,43 +231,55 @@ func (s *CostService) GetNamespaceCosts(ctx context.Context) ([]internal.Namespa
 
 }
 
-func (s *CostService) calculateNodeCost(ctx context.Context, namespace, pod, nodeName string) (*internal.NodeCost, error) {
-	cpuUsage, err := s.promClient.GetCPUUsage(ctx, namespace, pod)
+// calculateNodeCost prices a node's CPU and memory capacity, which is what
+// the node costs whether or not pods use it. Usage is reported alongside
+// for reference and does not affect the cost.
+func (s *CostService) calculateNodeCost(ctx context.Context, node corev1.Node, pricing internal.PricingConfig) *internal.NodeCost {
+	cpu := node.Status.Capacity[corev1.ResourceCPU]
+	memory := node.Status.Capacity[corev1.ResourceMemory]
+	cpuCost := money.FromFloat(pricing.CPUCostPerHour).Mul(cpu.AsApproximateFloat64())
+	memoryCost := money.FromFloat(pricing.MemoryCostPerGB).Mul(float64(memory.Value()) / bytesPerGB)
+
+	cpuUsage, err := s.promClient.GetNodeCPUUsage(ctx, node.Name)
 	if err != nil {
+		s.logger.DebugContext(ctx, "failed to get node CPU usage, assuming zero", "node", node.Name, "error", err)
 		cpuUsage = 0
 	}
-
-	cpuCost := cpuUsage * s.config.CPUCostPerHour
-
-	memoryUsage, err := s.promClient.GetMemoryUsage(ctx, namespace, pod)
+	memoryUsage, err := s.promClient.GetNodeMemoryUsage(ctx, node.Name)
 	if err != nil {
+		s.logger.DebugContext(ctx, "failed to get node memory usage, assuming zero", "node", node.Name, "error", err)
 		memoryUsage = 0
 	}
-	memoryGB := memoryUsage / (1024 * 1024 * 1024)
-	memoryCost := memoryGB * s.config.MemoryCostPerGB
-
-	totalCost := cpuCost + memoryCost
 
 	return &internal.NodeCost{
-		Name:        nodeName,
-		CPUCost:     cpuCost,
-		MemoryCost:  memoryCost,
-		TotalCost:   totalCost,
-		CPUUsage:    cpuUsage,
-		MemoryUsage: int64(memoryUsage),
-		Timestamp:   time.Now(),
-	}, nil
+		Cluster:        s.cluster,
+		Name:           node.Name,
+		CPUCost:        cpuCost,
+		MemoryCost:     memoryCost,
+		TotalCost:      cpuCost.Add(memoryCost),
+		CPUCapacity:    cpu.String(),
+		MemoryCapacity: memory.String(),
+		CPUUsage:       cpuUsage,
+		MemoryUsage:    int64(memoryUsage),
+		Timestamp:      time.Now(),
+	}
 }
+
 func (s *CostService) GetPodCosts(ctx context.Context, namespace string) ([]internal.PodCost, error) {
-	pods, err := s.k8sClient.GetPods(namespace)
+	ctx, span := observability.StartSpan(ctx, "CostService.GetPodCosts")
+	defer span.End()
+
+	pods, err := s.k8sClient.GetPods(ctx, namespace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pods: %v", err)
 	}
 
 	costs := make([]internal.PodCost, 0, len(pods.Items))
+	inputs := s.costInputs(ctx, namespace)
 	for _, pod := range pods.Items {
-		cost, err := s.calculatePodCost(ctx, pod.Namespace, pod.Name)
+		cost, err := s.podCost(ctx, pod, inputs)
 		if err != nil {
+			s.logger.WarnContext(ctx, "skipping pod", "namespace", pod.Namespace, "pod", pod.Name, "error", err)
 			continue
 		}
 		costs = append(costs, *cost)
@@ -175,85 +288,190 @@ func (s *CostService) GetPodCosts(ctx context.Context, namespace string) ([]inte
 	return costs, nil
 }
 
-func (s *CostService) calculateNamespaceCost(ctx context.Context, namespace string) (*internal.NamespaceCost, error) {
-	pods, err := s.k8sClient.GetPods(namespace)
+// podCost prices a running pod's usage, its share of the persistent volume
+// claims it mounts, its requests of extended resources, its share of the
+// node's root disk and its egress traffic, and applies its node's
+// adjustment.
+func (s *CostService) podCost(ctx context.Context, pod corev1.Pod, inputs *costInputs) (*internal.PodCost, error) {
+	cost, err := s.calculatePodCost(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+	cost.Node = pod.Spec.NodeName
+	cost.Labels = pod.Labels
+	cost.ControllerKind, cost.Controller = podController(pod)
+
+	pricing := s.config.Current().PricingFor(s.cluster)
+	if claimed := inputs.storage[pod.Namespace+"/"+pod.Name]; claimed > 0 {
+		cost.StorageCost = money.FromFloat(pricing.StorageCostPerGB).Mul(claimed)
+		cost.TotalCost = cost.TotalCost.Add(cost.StorageCost)
+	}
+
+	cost.ExtendedResources = extendedResourceCosts(pod, pricing, inputs.instanceTypes[pod.Spec.NodeName])
+	cost.ExtendedCost = sumCosts(cost.ExtendedResources)
+	cost.TotalCost = cost.TotalCost.Add(cost.ExtendedCost)
+
+	if pricing.EphemeralStorageCostPerGB > 0 && holdsResources(pod) {
+		usage, err := s.promClient.GetEphemeralStorageUsage(ctx, pod.Namespace, pod.Name)
+		if err != nil {
+			s.logger.DebugContext(ctx, "failed to get ephemeral storage usage, assuming zero", "namespace", pod.Namespace, "pod", pod.Name, "error", err)
+			usage = 0
+		}
+		requested := podRequests(pod)[corev1.ResourceEphemeralStorage]
+		cost.EphemeralStorageUsage = int64(usage)
+		cost.EphemeralStorageCost = money.FromFloat(pricing.EphemeralStorageCostPerGB).Mul(max(usage, requested.AsApproximateFloat64()) / bytesPerGB)
+		cost.TotalCost = cost.TotalCost.Add(cost.EphemeralStorageCost)
+	}
+
+	cost.NetworkCost, cost.NetworkEgress = s.networkCost(ctx, pod, inputs)
+	cost.TotalCost = cost.TotalCost.Add(cost.NetworkCost)
+
+	s.adjustments.ApplyPod(cost)
+	return cost, nil
+}
+
+func (s *CostService) calculateNamespaceCost(ctx context.Context, namespace string, inputs *costInputs) (*internal.NamespaceCost, error) {
+	pods, err := s.k8sClient.GetPods(ctx, namespace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pods for namespace %s: %v", namespace, err)
 	}
-	var totalCPUCost, totalMemoryCost, totalStorageCost, totalNetworkCost float64
+	var totalCPUCost, totalMemoryCost, totalStorageCost, totalNetworkCost, totalEphemeralStorageCost, totalExtendedCost, totalListCost money.Amount
+	var extended, egress map[string]money.Amount
 
 	podCosts := make([]internal.PodCost, 0, len(pods.Items))
 
 	for _, pod := range pods.Items {
-		podCost, err := s.calculatePodCost(ctx, pod.Namespace, pod.Name)
+		podCost, err := s.podCost(ctx, pod, inputs)
 		if err != nil {
+			s.logger.WarnContext(ctx, "skipping pod", "namespace", pod.Namespace, "pod", pod.Name, "error", err)
 			continue
 		}
 
-		totalCPUCost += podCost.CPUCost
-		totalMemoryCost += podCost.MemoryCost
-		totalNetworkCost += podCost.NetworkCost
-		totalStorageCost += podCost.StorageCost
+		for resource, cost := range podCost.ExtendedResources {
+			if extended == nil {
+				extended = make(map[string]money.Amount)
+			}
+			extended[resource] = extended[resource].Add(cost)
+		}
+		for class, cost := range podCost.NetworkEgress {
+			if egress == nil {
+				egress = make(map[string]money.Amount)
+			}
+			egress[class] = egress[class].Add(cost)
+		}
+		totalExtendedCost = totalExtendedCost.Add(podCost.ExtendedCost)
+		totalEphemeralStorageCost = totalEphemeralStorageCost.Add(podCost.EphemeralStorageCost)
+		totalCPUCost = totalCPUCost.Add(podCost.CPUCost)
+		totalMemoryCost = totalMemoryCost.Add(podCost.MemoryCost)
+		totalNetworkCost = totalNetworkCost.Add(podCost.NetworkCost)
+		totalStorageCost = totalStorageCost.Add(podCost.StorageCost)
+		totalListCost = totalListCost.Add(podCost.ListCost)
 
 		podCosts = append(podCosts, *podCost)
 	}
 
+	totalCost := money.Sum(totalCPUCost, totalMemoryCost, totalStorageCost, totalNetworkCost, totalEphemeralStorageCost, totalExtendedCost)
 	return &internal.NamespaceCost{
-		Namespace:   namespace,
-		CPUCost:     totalCPUCost,
-		MemoryCost:  totalMemoryCost,
-		StorageCost: totalStorageCost,
-		NetworkCost: totalNetworkCost,
-		TotalCost:   totalCPUCost + totalMemoryCost + totalStorageCost + totalNetworkCost,
-		PodCount:    len(pods.Items),
-		Pods:        podCosts,
-		Timestamp:   time.Now(),
+		Cluster:              s.cluster,
+		Namespace:            namespace,
+		CPUCost:              totalCPUCost,
+		MemoryCost:           totalMemoryCost,
+		StorageCost:          totalStorageCost,
+		NetworkCost:          totalNetworkCost,
+		NetworkEgress:        egress,
+		EphemeralStorageCost: totalEphemeralStorageCost,
+		ExtendedCost:         totalExtendedCost,
+		ExtendedResources:    extended,
+		TotalCost:            totalCost,
+		ListCost:             totalListCost,
+		Savings:              totalListCost.Sub(totalCost),
+		PodCount:             len(pods.Items),
+		Pods:                 podCosts,
+		Timestamp:            time.Now(),
 	}, nil
 
 }
 
 func (s *CostService) calculatePodCost(ctx context.Context, namespace, podName string) (*internal.PodCost, error) {
+	pricing := s.config.Current().PricingFor(s.cluster)
+
 	cpuUsage, err := s.promClient.GetCPUUsage(ctx, namespace, podName)
 	if err != nil {
+		s.logger.DebugContext(ctx, "failed to get CPU usage, assuming zero", "namespace", namespace, "pod", podName, "error", err)
 		cpuUsage = 0
 	}
 
-	cpuCost := cpuUsage * s.config.CPUCostPerHour
+	cpuCost := money.FromFloat(pricing.CPUCostPerHour).Mul(cpuUsage)
 
 	memoryUsage, err := s.promClient.GetMemoryUsage(ctx, namespace, podName)
 	if err != nil {
+		s.logger.DebugContext(ctx, "failed to get memory usage, assuming zero", "namespace", namespace, "pod", podName, "error", err)
 		memoryUsage = 0
 	}
 	memoryGB := memoryUsage / (1024 * 1024 * 1024)
-	memoryCost := memoryGB * s.config.MemoryCostPerGB
-
-	rxBytes, txBytes, err := s.promClient.GetNetworkIO(ctx, namespace, podName)
-	if err != nil {
-		rxBytes = 0
-		txBytes = 0
-	}
-
-	networkCost := (rxBytes + txBytes) * 0.000001
-
-	storageCost := 0.01
+	memoryCost := money.FromFloat(pricing.MemoryCostPerGB).Mul(memoryGB)
 
 	return &internal.PodCost{
+		Cluster:     s.cluster,
 		Name:        podName,
 		Namespace:   namespace,
 		CPUCost:     cpuCost,
 		MemoryCost:  memoryCost,
-		StorageCost: storageCost,
-		NetworkCost: networkCost,
-		TotalCost:   cpuCost + memoryCost + storageCost + networkCost,
+		TotalCost:   cpuCost.Add(memoryCost),
 		CPUUsage:    cpuUsage,
 		MemoryUsage: int64(memoryUsage),
-		// Status:      "Running",
-		// Timestamp:   time.Time{},
-		// CreatedAt:   time.Time{},
 	}, nil
 }
 
-func (s *CostService) convertToCostHistory(cpuResult, memResult prometheus.RangeQueryResult) []internal.CostHistoryPoint {
+// claimedStorage returns the GB of persistent volume claims each running
+// pod, keyed namespace/pod, mounts. A claim mounted by several pods is
+// split evenly between them. Failures are logged and leave pod storage
+// unpriced.
+func (s *CostService) claimedStorage(ctx context.Context, namespace string) map[string]float64 {
+	claims, err := s.k8sClient.GetPersistentVolumeClaims(ctx, namespace)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to get persistent volume claims, leaving pod storage unpriced", "error", err)
+		return nil
+	}
+	pods, err := s.k8sClient.GetPods(ctx, namespace)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to get pods, leaving pod storage unpriced", "error", err)
+		return nil
+	}
+
+	capacity := make(map[string]float64, len(claims.Items))
+	for _, claim := range claims.Items {
+		size := claim.Status.Capacity[corev1.ResourceStorage]
+		capacity[claim.Namespace+"/"+claim.Name] = size.AsApproximateFloat64() / bytesPerGB
+	}
+	mounts := make(map[string][]string)
+	for _, pod := range pods.Items {
+		if !holdsResources(pod) {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim == nil {
+				continue
+			}
+			claim := pod.Namespace + "/" + volume.PersistentVolumeClaim.ClaimName
+			if capacity[claim] > 0 {
+				mounts[claim] = append(mounts[claim], pod.Namespace+"/"+pod.Name)
+			}
+		}
+	}
+
+	storage := make(map[string]float64)
+	for claim, pods := range mounts {
+		for _, pod := range pods {
+			storage[pod] += capacity[claim] / float64(len(pods))
+		}
+	}
+	return storage
+}
+
+// convertToCostHistory prices usage at each timestamp with the pricing
+// pricingAt returns for it.
+func (s *CostService) convertToCostHistory(cpuResult, memResult prometheus.RangeQueryResult, pricingAt func(time.Time) internal.PricingConfig) []internal.CostHistoryPoint {
 	points := make([]internal.CostHistoryPoint, 0)
 
 	// Assuming both CPU and memory results have the same timestamps
@@ -263,14 +481,14 @@ func (s *CostService) convertToCostHistory(cpuResult, memResult prometheus.Range
 		for _, value := range series.Values {
 			timestamp := value.Timestamp
 			cpuUsage := value.Value
-			cpuCost := cpuUsage * s.config.CPUCostPerHour
+			cpuCost := money.FromFloat(pricingAt(timestamp).CPUCostPerHour).Mul(cpuUsage)
 
 			point, exists := timestampMap[timestamp.Unix()]
 			if !exists {
 				point = &internal.CostHistoryPoint{Timestamp: time.Unix(timestamp.Unix(), 0)}
 				timestampMap[timestamp.Unix()] = point
 			}
-			point.CPUCost += cpuCost
+			point.CPUCost = point.CPUCost.Add(cpuCost)
 		}
 	}
 
@@ -280,20 +498,24 @@ func (s *CostService) convertToCostHistory(cpuResult, memResult prometheus.Range
 			timestamp := value.Timestamp
 			memoryUsage := value.Value
 			memoryGB := memoryUsage / (1024 * 1024 * 1024)
-			memoryCost := memoryGB * s.config.MemoryCostPerGB
+			memoryCost := money.FromFloat(pricingAt(timestamp).MemoryCostPerGB).Mul(memoryGB)
 
 			point, exists := timestampMap[timestamp.Unix()]
 			if !exists {
 				point = &internal.CostHistoryPoint{Timestamp: time.Unix(timestamp.Unix(), 0)}
 				timestampMap[timestamp.Unix()] = point
 			}
-			point.MemoryCost += memoryCost
+			point.MemoryCost = point.MemoryCost.Add(memoryCost)
 		}
 	}
 
-	// Finalize points
+	// Finalize points at the rates in effect at each timestamp
 	for _, point := range timestampMap {
-		point.TotalCost = point.CPUCost + point.MemoryCost
+		period, multiplier := pricingAt(point.Timestamp).PricingPeriodAt(point.Timestamp)
+		point.PricingPeriod = period
+		point.CPUCost = point.CPUCost.Mul(multiplier)
+		point.MemoryCost = point.MemoryCost.Mul(multiplier)
+		point.TotalCost = point.CPUCost.Add(point.MemoryCost)
 		points = append(points, *point)
 	}
 
@@ -304,3 +526,42 @@ func (s *CostService) convertToCostHistory(cpuResult, memResult prometheus.Range
 
 	return points
 }
+
+// historyPeriods sums the cost of history points, each standing for one
+// step, by pricing period in the order the periods first occur.
+func historyPeriods(points []internal.CostHistoryPoint, step time.Duration) []internal.PeriodCost {
+	var periods []internal.PeriodCost
+	index := make(map[string]int)
+	for _, point := range points {
+		i, ok := index[point.PricingPeriod]
+		if !ok {
+			i = len(periods)
+			index[point.PricingPeriod] = i
+			periods = append(periods, internal.PeriodCost{Period: point.PricingPeriod})
+		}
+		periods[i].Hours += step.Hours()
+		periods[i].Cost = periods[i].Cost.Add(point.TotalCost.Mul(step.Hours()))
+	}
+	return periods
+}
+
+// podController returns the workload that owns pod. Pods of a ReplicaSet
+// created by a Deployment are attributed to the Deployment, using the
+// pod-template-hash suffix the Deployment controller appends to ReplicaSet
+// names. Bare pods have no controller.
+func podController(pod corev1.Pod) (kind, name string) {
+	owner := metav1.GetControllerOf(&pod)
+	if owner == nil {
+		return "", ""
+	}
+
+	if owner.Kind == "ReplicaSet" {
+		if hash, ok := pod.Labels["pod-template-hash"]; ok {
+			if deployment, found := strings.CutSuffix(owner.Name, "-"+hash); found {
+				return "Deployment", deployment
+			}
+		}
+	}
+
+	return owner.Kind, owner.Name
+}
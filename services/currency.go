@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+)
+
+var (
+	ErrInvalidCurrency = errors.New("invalid currency")
+	ErrNoExchangeRate  = errors.New("no exchange rate")
+)
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ExchangeRates holds dated exchange rates, oldest first. A rate applies
+// from its effective time until the next rate for the same pair; a rate
+// also converts the inverse pair, unless a later rate for that pair exists.
+// Rates are not chained, so converting USD to GBP needs a USD/GBP or
+// GBP/USD rate even when USD/EUR and EUR/GBP rates exist.
+type ExchangeRates struct {
+	mu    sync.RWMutex
+	rates []internal.ExchangeRate
+}
+
+func NewExchangeRates() *ExchangeRates {
+	return &ExchangeRates{}
+}
+
+// Set replaces the rates. Of two rates for the same pair and time, the
+// later one in rates wins.
+func (r *ExchangeRates) Set(rates []internal.ExchangeRate) {
+	sorted := make([]internal.ExchangeRate, len(rates))
+	copy(sorted, rates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].EffectiveFrom.Before(sorted[j].EffectiveFrom)
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rates = sorted
+}
+
+// List returns the rates, oldest first.
+func (r *ExchangeRates) List() []internal.ExchangeRate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rates
+}
+
+// Rate returns how many units of to one unit of from is worth at t.
+func (r *ExchangeRates) Rate(from, to string, t time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+
+	rates := r.List()
+	for i := sort.Search(len(rates), func(i int) bool {
+		return rates[i].EffectiveFrom.After(t)
+	}) - 1; i >= 0; i-- {
+		switch rate := rates[i]; {
+		case rate.From == from && rate.To == to:
+			return rate.Rate, nil
+		case rate.From == to && rate.To == from:
+			return 1 / rate.Rate, nil
+		}
+	}
+	return 0, fmt.Errorf("%w from %s to %s at %s", ErrNoExchangeRate, from, to, t.UTC().Format(time.RFC3339))
+}
+
+// CurrencyConverter converts costs from the base currency into Currency.
+type CurrencyConverter struct {
+	Currency string
+
+	base  string
+	rates *ExchangeRates
+}
+
+// Converter returns a converter into currency, which is an ISO 4217 code
+// in any case. An empty currency keeps costs in the base currency.
+func (s *ClusterService) Converter(currency string) (*CurrencyConverter, error) {
+	base := s.config.Current().Currency.Base
+	currency = strings.ToUpper(currency)
+	if currency == "" {
+		currency = base
+	}
+	if !currencyPattern.MatchString(currency) {
+		return nil, fmt.Errorf("%w: %q must be an ISO 4217 code such as EUR", ErrInvalidCurrency, currency)
+	}
+	return &CurrencyConverter{Currency: currency, base: base, rates: s.rates}, nil
+}
+
+// Rounding returns the rounding costs are presented with.
+func (s *ClusterService) Rounding() money.Rounding {
+	return s.config.Current().Currency.Presentation()
+}
+
+// Rate returns the factor that converts base currency costs at t.
+func (c *CurrencyConverter) Rate(t time.Time) (float64, error) {
+	return c.rates.Rate(c.base, c.Currency, t)
+}
+
+// RateFrom returns the factor that converts costs in currency at t. An
+// empty currency is the base currency.
+func (c *CurrencyConverter) RateFrom(currency string, t time.Time) (float64, error) {
+	if currency == "" {
+		currency = c.base
+	}
+	return c.rates.Rate(currency, c.Currency, t)
+}
+
+// ConvertHistory converts every point of history at the rate in effect at
+// its timestamp and recomputes the pricing period breakdown.
+func (c *CurrencyConverter) ConvertHistory(history *internal.CostHistory, step time.Duration) error {
+	history.Currency = c.Currency
+	if c.Currency == c.base {
+		return nil
+	}
+	for i := range history.Data {
+		rate, err := c.Rate(history.Data[i].Timestamp)
+		if err != nil {
+			return err
+		}
+		history.Data[i].Scale(rate)
+	}
+	history.PricingPeriods = historyPeriods(history.Data, step)
+	return nil
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/observability"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	pricingModelOnDemand    = "on_demand"
+	pricingModelSpot        = "spot"
+	pricingModelReserved    = "reserved"
+	pricingModelSavingsPlan = "savings_plan"
+)
+
+var ErrDiscountsNotComputed = errors.New("discounts have not been computed yet")
+
+// spotLabels are the node labels, and their values, by which Karpenter, EKS
+// managed node groups, GKE and AKS mark spot and preemptible nodes.
+var spotLabels = map[string]string{
+	"karpenter.sh/capacity-type":            "spot",
+	"eks.amazonaws.com/capacityType":        "spot",
+	"cloud.google.com/gke-spot":             "true",
+	"cloud.google.com/gke-preemptible":      "true",
+	"kubernetes.azure.com/scalesetpriority": "spot",
+}
+
+// DiscountService models the effective hourly rate of every node from the
+// configured spot discounts, reservations and savings plans and publishes
+// the rates to the cluster service, where they scale node and pod costs.
+type DiscountService struct {
+	clusters *ClusterService
+	config   *internal.ConfigStore
+	logger   *slog.Logger
+
+	mu     sync.RWMutex
+	report *internal.DiscountReport
+}
+
+func NewDiscountService(clusters *ClusterService, config *internal.ConfigStore, logger *slog.Logger) *DiscountService {
+	return &DiscountService{
+		clusters: clusters,
+		config:   config,
+		logger:   logger,
+	}
+}
+
+// Run refreshes the rates immediately and then on the configured interval
+// until ctx is cancelled.
+func (s *DiscountService) Run(ctx context.Context) {
+	for {
+		if _, err := s.Refresh(ctx); err != nil {
+			s.logger.ErrorContext(ctx, "failed to compute discounts", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.config.Current().Discounts.Interval):
+		}
+	}
+}
+
+// Report returns the result of the last refresh.
+func (s *DiscountService) Report() (*internal.DiscountReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.report == nil {
+		return nil, ErrDiscountsNotComputed
+	}
+	return s.report, nil
+}
+
+// Refresh lists the nodes of every cluster and recomputes their rates.
+// Reservations and savings plans apply across clusters, as they do on a
+// cloud account. Clusters whose nodes cannot be listed are reported and
+// skipped; an error is returned only when no cluster could be read.
+func (s *DiscountService) Refresh(ctx context.Context) (*internal.DiscountReport, error) {
+	ctx, span := observability.StartSpan(ctx, "DiscountService.Refresh")
+	defer span.End()
+
+	cfg := s.config.Current()
+	report := &internal.DiscountReport{GeneratedAt: time.Now(), Currency: cfg.Currency.Base}
+
+	var nodes []discountedNode
+	var read int
+	for _, cluster := range s.clusters.Clusters() {
+		list, err := cluster.K8sClient.GetNodes(ctx)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("cluster %s: failed to get nodes: %v", cluster.Name, err))
+			continue
+		}
+		read++
+
+		pricing := cfg.PricingFor(cluster.Name)
+		for _, node := range list.Items {
+			nodes = append(nodes, discountedNode{
+				cluster:      cluster.Name,
+				node:         node,
+				instanceType: instanceType(node.Labels),
+				list:         nodeHourlyListCost(node, pricing),
+			})
+		}
+	}
+	if read == 0 && len(report.Errors) > 0 {
+		return nil, fmt.Errorf("no cluster nodes could be listed: %s", strings.Join(report.Errors, "; "))
+	}
+
+	report.Reservations, report.SavingsPlans = applyDiscounts(nodes, cfg.Discounts)
+
+	rates := make(map[nodeRef]nodeRate, len(nodes))
+	report.Nodes = make([]internal.NodeRate, 0, len(nodes))
+	for _, n := range nodes {
+		rate := internal.NodeRate{
+			Cluster:       n.cluster,
+			Node:          n.node.Name,
+			InstanceType:  n.instanceType,
+			PricingModel:  n.model,
+			ListCost:      n.list,
+			EffectiveCost: n.effective,
+			Savings:       n.list - n.effective,
+		}
+		report.Nodes = append(report.Nodes, rate)
+		report.ListCost += rate.ListCost
+		report.EffectiveCost += rate.EffectiveCost
+
+		if n.list > 0 {
+			rates[nodeRef{n.cluster, n.node.Name}] = nodeRate{factor: n.effective / n.list, model: n.model}
+		}
+	}
+	report.Savings = report.ListCost - report.EffectiveCost
+
+	sort.Slice(report.Nodes, func(i, j int) bool {
+		a, b := report.Nodes[i], report.Nodes[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		return a.Node < b.Node
+	})
+
+	s.clusters.Adjustments().SetDiscounts(rates)
+
+	s.mu.Lock()
+	s.report = report
+	s.mu.Unlock()
+
+	s.logger.DebugContext(ctx, "computed node discounts",
+		"nodes", len(report.Nodes), "list_cost", report.ListCost, "effective_cost", report.EffectiveCost)
+
+	return report, nil
+}
+
+// discountedNode is a node being priced. effective is its hourly cost after
+// discounts and uncovered is the fraction of it still billed on demand.
+type discountedNode struct {
+	cluster      string
+	node         corev1.Node
+	instanceType string
+	list         float64
+
+	model     string
+	effective float64
+	uncovered float64
+}
+
+// applyDiscounts prices nodes in place. Spot nodes are discounted first.
+// The remaining nodes, oldest first, take the reserved instances of their
+// type, and then each savings plan in turn covers what is still on demand
+// until its hourly commitment, at its discounted rate, is spent. A node
+// can be covered in part, by more than one plan.
+func applyDiscounts(nodes []discountedNode, discounts internal.DiscountsConfig) ([]internal.ReservationUsage, []internal.SavingsPlanUsage) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if !a.node.CreationTimestamp.Equal(&b.node.CreationTimestamp) {
+			return a.node.CreationTimestamp.Before(&b.node.CreationTimestamp)
+		}
+		if a.cluster != b.cluster {
+			return a.cluster < b.cluster
+		}
+		return a.node.Name < b.node.Name
+	})
+
+	for i := range nodes {
+		n := &nodes[i]
+		n.model, n.uncovered = pricingModelOnDemand, 1
+		if isSpot(n.node.Labels, discounts.Spot.Labels) {
+			discount := discounts.Spot.Discount
+			if typed, ok := discounts.Spot.InstanceTypes[n.instanceType]; ok {
+				discount = typed
+			}
+			n.model, n.uncovered = pricingModelSpot, 0
+			n.effective = n.list * (1 - discount)
+		}
+	}
+
+	reservations := make([]internal.ReservationUsage, 0, len(discounts.Reservations))
+	for _, reservation := range discounts.Reservations {
+		usage := internal.ReservationUsage{
+			InstanceType: reservation.InstanceType,
+			Count:        reservation.Count,
+			Discount:     reservation.Discount,
+		}
+		for i := range nodes {
+			n := &nodes[i]
+			if usage.Used == usage.Count {
+				break
+			}
+			if n.model != pricingModelOnDemand || n.instanceType != reservation.InstanceType {
+				continue
+			}
+			n.model, n.uncovered = pricingModelReserved, 0
+			n.effective = n.list * (1 - reservation.Discount)
+			usage.Used++
+		}
+		reservations = append(reservations, usage)
+	}
+
+	plans := make([]internal.SavingsPlanUsage, 0, len(discounts.SavingsPlans))
+	for _, plan := range discounts.SavingsPlans {
+		usage := internal.SavingsPlanUsage{
+			Name:              plan.Name,
+			CommitmentPerHour: plan.CommitmentPerHour,
+			Discount:          plan.Discount,
+		}
+		for i := range nodes {
+			n := &nodes[i]
+			remaining := plan.CommitmentPerHour - usage.UsedPerHour
+			if remaining <= 0 {
+				break
+			}
+			rate := n.list * (1 - plan.Discount)
+			if n.uncovered <= 0 || rate <= 0 {
+				continue
+			}
+			covered := min(n.uncovered*rate, remaining)
+			n.effective += covered
+			n.uncovered -= covered / rate
+			n.model = pricingModelSavingsPlan
+			usage.UsedPerHour += covered
+		}
+		usage.UnusedPerHour = usage.CommitmentPerHour - usage.UsedPerHour
+		plans = append(plans, usage)
+	}
+
+	for i := range nodes {
+		n := &nodes[i]
+		n.effective += n.uncovered * n.list
+		n.uncovered = 0
+	}
+
+	return reservations, plans
+}
+
+// isSpot reports whether node labels include one of the well-known spot
+// labels or one of the configured extra labels.
+func isSpot(labels, extra map[string]string) bool {
+	for _, spot := range []map[string]string{spotLabels, extra} {
+		for key, value := range spot {
+			if strings.EqualFold(labels[key], value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func instanceType(labels map[string]string) string {
+	if value := labels[corev1.LabelInstanceTypeStable]; value != "" {
+		return value
+	}
+	return labels[corev1.LabelInstanceType]
+}
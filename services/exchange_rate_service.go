@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/exchangerate"
+)
+
+const exchangeRatesFile = "exchange-rates.json"
+
+// ExchangeRateService holds the exchange rates costs are converted with and
+// publishes them to the cluster service. Rates posted to the API are
+// persisted under the storage path and take precedence over rates for the
+// same pair and time from the configured files, which are reloaded on an
+// interval.
+type ExchangeRateService struct {
+	clusters *ClusterService
+	config   *internal.ConfigStore
+	logger   *slog.Logger
+
+	mu        sync.Mutex
+	uploaded  map[string]internal.ExchangeRate
+	fromFiles []internal.ExchangeRate
+}
+
+func NewExchangeRateService(clusters *ClusterService, config *internal.ConfigStore, logger *slog.Logger) (*ExchangeRateService, error) {
+	s := &ExchangeRateService{
+		clusters: clusters,
+		config:   config,
+		logger:   logger,
+		uploaded: make(map[string]internal.ExchangeRate),
+	}
+
+	storagePath := config.Current().Currency.ExchangeRates.StoragePath
+	if err := os.MkdirAll(storagePath, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create exchange rate storage directory: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(storagePath, exchangeRatesFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read exchange rates: %v", err)
+	}
+	if len(data) > 0 {
+		var rates []internal.ExchangeRate
+		if err := json.Unmarshal(data, &rates); err != nil {
+			return nil, fmt.Errorf("failed to parse exchange rates: %v", err)
+		}
+		for _, rate := range rates {
+			s.uploaded[exchangerate.Key(rate)] = rate
+		}
+	}
+	s.publish()
+
+	return s, nil
+}
+
+// Run reloads the configured files immediately and then on the configured
+// interval until ctx is cancelled.
+func (s *ExchangeRateService) Run(ctx context.Context) {
+	for {
+		if err := s.Reload(ctx); err != nil {
+			s.logger.ErrorContext(ctx, "failed to reload exchange rate files", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.config.Current().Currency.ExchangeRates.Interval):
+		}
+	}
+}
+
+// Reload re-reads every configured file. A file that fails to parse keeps
+// the whole reload from taking effect, so that a half-written file never
+// drops rates.
+func (s *ExchangeRateService) Reload(ctx context.Context) error {
+	var rates []internal.ExchangeRate
+	for _, pattern := range s.config.Current().Currency.ExchangeRates.Files {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		for _, path := range paths {
+			fileRates, err := loadExchangeRateFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to load %s: %v", path, err)
+			}
+			rates = append(rates, fileRates...)
+		}
+	}
+
+	s.mu.Lock()
+	s.fromFiles = rates
+	s.publish()
+	s.mu.Unlock()
+
+	s.logger.DebugContext(ctx, "reloaded exchange rate files", "rates", len(rates))
+	return nil
+}
+
+// Upload adds rates, replacing those for the same pair and time, and
+// persists them.
+func (s *ExchangeRateService) Upload(rates []internal.ExchangeRate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uploaded := make(map[string]internal.ExchangeRate, len(s.uploaded)+len(rates))
+	for key, rate := range s.uploaded {
+		uploaded[key] = rate
+	}
+	for _, rate := range rates {
+		uploaded[exchangerate.Key(rate)] = rate
+	}
+
+	if err := s.persist(uploaded); err != nil {
+		return err
+	}
+	s.uploaded = uploaded
+	s.publish()
+	return nil
+}
+
+// List returns every rate in effect, from files and the API, oldest first.
+func (s *ExchangeRateService) List() []internal.ExchangeRate {
+	return s.clusters.ExchangeRates().List()
+}
+
+// publish hands the rates to the cluster service, uploaded rates last so
+// that they win over file rates with the same key. s.mu must be held, or
+// s not yet shared.
+func (s *ExchangeRateService) publish() {
+	rates := make([]internal.ExchangeRate, 0, len(s.fromFiles)+len(s.uploaded))
+	rates = append(rates, s.fromFiles...)
+	for _, key := range slices.Sorted(maps.Keys(s.uploaded)) {
+		rates = append(rates, s.uploaded[key])
+	}
+	s.clusters.ExchangeRates().Set(rates)
+}
+
+func (s *ExchangeRateService) persist(uploaded map[string]internal.ExchangeRate) error {
+	rates := make([]internal.ExchangeRate, 0, len(uploaded))
+	for _, key := range slices.Sorted(maps.Keys(uploaded)) {
+		rates = append(rates, uploaded[key])
+	}
+
+	data, err := json.Marshal(rates)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.config.Current().Currency.ExchangeRates.StoragePath, exchangeRatesFile), data); err != nil {
+		return fmt.Errorf("failed to persist exchange rates: %v", err)
+	}
+	return nil
+}
+
+func loadExchangeRateFile(path string) ([]internal.ExchangeRate, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return exchangerate.ParseCSV(file)
+	}
+	return exchangerate.ParseJSON(file)
+}
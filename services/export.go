@@ -0,0 +1,76 @@
+package services
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+)
+
+type allocationResource struct {
+	name  string
+	usage float64
+	unit  string
+	cost  money.Amount
+}
+
+// AllocationRows flattens namespaces into one row per pod, resource and
+// pricing period for the window ending at end. Pod costs are hourly rates
+// at base prices, so each row's cost is the rate scaled by the hours the
+// window spends in the period and by the period's multiplier. Costs are
+// in currency, which namespaces must already be converted into. pricingFor
+// returns the pricing of a cluster.
+//
+// The yielded row is reused between iterations and must not be retained.
+func AllocationRows(namespaces []internal.NamespaceCost, window time.Duration, end time.Time, currency string, pricingFor func(cluster string) internal.PricingConfig) iter.Seq[*internal.AllocationRow] {
+	start := end.Add(-window)
+	periods := make(map[string][]internal.PricingPeriod)
+
+	return func(yield func(*internal.AllocationRow) bool) {
+		var row internal.AllocationRow
+		for _, ns := range namespaces {
+			if _, ok := periods[ns.Cluster]; !ok {
+				periods[ns.Cluster] = pricingFor(ns.Cluster).PricingPeriods(start, end)
+			}
+			for _, pod := range ns.Pods {
+				row = internal.AllocationRow{
+					WindowStart:    start,
+					WindowEnd:      end,
+					Cluster:        ns.Cluster,
+					Namespace:      ns.Namespace,
+					ControllerKind: pod.ControllerKind,
+					Controller:     pod.Controller,
+					Pod:            pod.Name,
+					Node:           pod.Node,
+					Currency:       currency,
+				}
+
+				resources := []allocationResource{
+					{"cpu", pod.CPUUsage, "cores", pod.CPUCost},
+					{"memory", float64(pod.MemoryUsage), "bytes", pod.MemoryCost},
+					{"storage", 0, "", pod.StorageCost},
+					{"network", 0, "", pod.NetworkCost},
+					{"ephemeral-storage", float64(pod.EphemeralStorageUsage), "bytes", pod.EphemeralStorageCost},
+				}
+				for _, name := range slices.Sorted(maps.Keys(pod.ExtendedResources)) {
+					resources = append(resources, allocationResource{name, 0, "", pod.ExtendedResources[name]})
+				}
+				for _, resource := range resources {
+					for _, period := range periods[ns.Cluster] {
+						row.Resource = resource.name
+						row.Usage = resource.usage
+						row.UsageUnit = resource.unit
+						row.PricingPeriod = period.Name
+						row.Cost = resource.cost.Mul(period.Multiplier).Mul(period.Hours)
+						if !yield(&row) {
+							return
+						}
+					}
+				}
+			}
+		}
+	}
+}
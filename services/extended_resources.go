@@ -0,0 +1,150 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// extendedResourceQuantity converts a quantity of resource to the unit it
+// is priced in: GB for hugepages and devices for everything else.
+func extendedResourceQuantity(resource corev1.ResourceName, quantity float64) float64 {
+	if strings.HasPrefix(string(resource), corev1.ResourceHugePagesPrefix) {
+		return quantity / bytesPerGB
+	}
+	return quantity
+}
+
+// isGPU reports whether an extended resource is a GPU, such as
+// nvidia.com/gpu, amd.com/gpu or gpu.intel.com/i915.
+func isGPU(resource string) bool {
+	return strings.Contains(strings.ToLower(resource), "gpu")
+}
+
+// extendedResourceCosts prices a pod's requests of every priced extended
+// resource. Pods that are not running on a node, or that have finished,
+// hold no devices and cost nothing.
+func extendedResourceCosts(pod corev1.Pod, pricing internal.PricingConfig, instanceType string) map[string]money.Amount {
+	if len(pricing.ExtendedResources) == 0 || !holdsResources(pod) {
+		return nil
+	}
+
+	costs := make(map[string]money.Amount)
+	for resource, quantity := range podRequests(pod) {
+		rate, ok := pricing.ExtendedResourceRate(string(resource), instanceType)
+		if !ok {
+			continue
+		}
+		if cost := money.FromFloat(rate).Mul(extendedResourceQuantity(resource, quantity.AsApproximateFloat64())); cost.IsPositive() {
+			costs[string(resource)] = cost
+		}
+	}
+	return costs
+}
+
+// nodeExtendedResourceCosts prices a node's capacity of every priced
+// extended resource, and the part of it not requested by pods.
+func nodeExtendedResourceCosts(node corev1.Node, pods []corev1.Pod, pricing internal.PricingConfig) (capacity map[string]money.Amount, idle map[string]money.Amount) {
+	if len(pricing.ExtendedResources) == 0 {
+		return nil, nil
+	}
+
+	requested := make(map[corev1.ResourceName]float64)
+	for _, pod := range pods {
+		if pod.Spec.NodeName != node.Name || !holdsResources(pod) {
+			continue
+		}
+		for resource, quantity := range podRequests(pod) {
+			requested[resource] += quantity.AsApproximateFloat64()
+		}
+	}
+
+	capacity, idle = make(map[string]money.Amount), make(map[string]money.Amount)
+	for resource, quantity := range node.Status.Capacity {
+		rate, ok := pricing.ExtendedResourceRate(string(resource), instanceType(node.Labels))
+		if !ok {
+			continue
+		}
+		total := quantity.AsApproximateFloat64()
+		if cost := money.FromFloat(rate).Mul(extendedResourceQuantity(resource, total)); cost.IsPositive() {
+			capacity[string(resource)] = cost
+		}
+		if cost := money.FromFloat(rate).Mul(extendedResourceQuantity(resource, max(0, total-requested[resource]))); cost.IsPositive() {
+			idle[string(resource)] = cost
+		}
+	}
+	return capacity, idle
+}
+
+// holdsResources reports whether a pod has been scheduled and has not
+// finished, so that the devices it requests are allocated to it.
+func holdsResources(pod corev1.Pod) bool {
+	return pod.Spec.NodeName != "" && pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed
+}
+
+// podRequests is what the scheduler reserves for a pod: the sum of its
+// containers and restartable init containers, or the largest regular init
+// container if that is more, plus the pod overhead. A container that sets
+// only a limit for a resource requests its limit, as the API server
+// defaults it for extended resources.
+func podRequests(pod corev1.Pod) corev1.ResourceList {
+	requests := make(corev1.ResourceList)
+	add := func(list corev1.ResourceList) {
+		for resource, quantity := range list {
+			total := requests[resource]
+			total.Add(quantity)
+			requests[resource] = total
+		}
+	}
+
+	for _, container := range pod.Spec.Containers {
+		add(containerRequests(container))
+	}
+	var initPeak corev1.ResourceList
+	for _, container := range pod.Spec.InitContainers {
+		if container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			add(containerRequests(container))
+			continue
+		}
+		for resource, quantity := range containerRequests(container) {
+			if initPeak == nil {
+				initPeak = make(corev1.ResourceList)
+			}
+			if peak, ok := initPeak[resource]; !ok || quantity.Cmp(peak) > 0 {
+				initPeak[resource] = quantity
+			}
+		}
+	}
+	for resource, quantity := range initPeak {
+		if total, ok := requests[resource]; !ok || quantity.Cmp(total) > 0 {
+			requests[resource] = quantity
+		}
+	}
+	add(pod.Spec.Overhead)
+
+	return requests
+}
+
+func containerRequests(container corev1.Container) corev1.ResourceList {
+	requests := make(corev1.ResourceList, len(container.Resources.Requests))
+	for resource, quantity := range container.Resources.Requests {
+		requests[resource] = quantity
+	}
+	for resource, quantity := range container.Resources.Limits {
+		if _, ok := requests[resource]; !ok {
+			requests[resource] = quantity
+		}
+	}
+	return requests
+}
+
+func sumCosts(costs map[string]money.Amount) money.Amount {
+	var total money.Amount
+	for _, cost := range costs {
+		total = total.Add(cost)
+	}
+	return total
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/externalcost"
+)
+
+const externalCostsFile = "external-costs.json"
+
+var ErrExternalCostNotFound = errors.New("external cost not found")
+
+// ExternalCostService holds cost line items from outside the cluster. Items
+// posted to the API are persisted under the storage path; items from the
+// configured files are reloaded on an interval and cannot be deleted
+// through the API.
+type ExternalCostService struct {
+	config *internal.ConfigStore
+	logger *slog.Logger
+
+	mu        sync.RWMutex
+	ingested  map[string]internal.ExternalCost
+	fromFiles []internal.ExternalCost
+}
+
+func NewExternalCostService(config *internal.ConfigStore, logger *slog.Logger) (*ExternalCostService, error) {
+	s := &ExternalCostService{
+		config:   config,
+		logger:   logger,
+		ingested: make(map[string]internal.ExternalCost),
+	}
+
+	storagePath := config.Current().ExternalCosts.StoragePath
+	if err := os.MkdirAll(storagePath, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create external cost storage directory: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(storagePath, externalCostsFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read external costs: %v", err)
+	}
+	if len(data) > 0 {
+		var items []internal.ExternalCost
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("failed to parse external costs: %v", err)
+		}
+		for _, item := range items {
+			s.ingested[item.ID] = item
+		}
+	}
+
+	return s, nil
+}
+
+// Run reloads the configured files immediately and then on the configured
+// interval until ctx is cancelled.
+func (s *ExternalCostService) Run(ctx context.Context) {
+	for {
+		if err := s.Reload(ctx); err != nil {
+			s.logger.ErrorContext(ctx, "failed to reload external cost files", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.config.Current().ExternalCosts.Interval):
+		}
+	}
+}
+
+// Reload re-reads every configured file. A file that fails to parse keeps
+// the whole reload from taking effect, so that a half-written file never
+// drops costs.
+func (s *ExternalCostService) Reload(ctx context.Context) error {
+	var items []internal.ExternalCost
+	for _, pattern := range s.config.Current().ExternalCosts.Files {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		for _, path := range paths {
+			fileItems, err := loadExternalCostFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to load %s: %v", path, err)
+			}
+			items = append(items, fileItems...)
+		}
+	}
+
+	s.mu.Lock()
+	s.fromFiles = items
+	s.mu.Unlock()
+
+	s.logger.DebugContext(ctx, "reloaded external cost files", "items", len(items))
+	return nil
+}
+
+// Ingest adds or replaces items by ID and persists them.
+func (s *ExternalCostService) Ingest(items []internal.ExternalCost) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ingested := make(map[string]internal.ExternalCost, len(s.ingested)+len(items))
+	for id, item := range s.ingested {
+		ingested[id] = item
+	}
+	for _, item := range items {
+		ingested[item.ID] = item
+	}
+
+	if err := s.persist(ingested); err != nil {
+		return err
+	}
+	s.ingested = ingested
+	return nil
+}
+
+// Delete removes an item that was posted to the API.
+func (s *ExternalCostService) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.ingested[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrExternalCostNotFound, id)
+	}
+
+	ingested := make(map[string]internal.ExternalCost, len(s.ingested))
+	for key, item := range s.ingested {
+		if key != id {
+			ingested[key] = item
+		}
+	}
+
+	if err := s.persist(ingested); err != nil {
+		return err
+	}
+	s.ingested = ingested
+	return nil
+}
+
+// List returns every item, from the API and from files, by start time.
+func (s *ExternalCostService) List() []internal.ExternalCost {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := make([]internal.ExternalCost, 0, len(s.ingested)+len(s.fromFiles))
+	for _, item := range s.ingested {
+		items = append(items, item)
+	}
+	items = append(items, s.fromFiles...)
+
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].Start.Equal(items[j].Start) {
+			return items[i].Start.Before(items[j].Start)
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items
+}
+
+// Currency returns the currency item costs are in, the base currency.
+func (s *ExternalCostService) Currency() string {
+	return s.config.Current().Currency.Base
+}
+
+// Active returns the items whose time range contains at.
+func (s *ExternalCostService) Active(at time.Time) []internal.ExternalCost {
+	items := s.List()
+	active := items[:0]
+	for _, item := range items {
+		if !at.Before(item.Start) && at.Before(item.End) {
+			active = append(active, item)
+		}
+	}
+	return active
+}
+
+func (s *ExternalCostService) persist(ingested map[string]internal.ExternalCost) error {
+	items := make([]internal.ExternalCost, 0, len(ingested))
+	for _, item := range ingested {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.config.Current().ExternalCosts.StoragePath, externalCostsFile), data); err != nil {
+		return fmt.Errorf("failed to persist external costs: %v", err)
+	}
+	return nil
+}
+
+func loadExternalCostFile(path string) ([]internal.ExternalCost, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return externalcost.ParseCSV(file)
+	}
+	return externalcost.ParseJSON(file)
+}
@@ -0,0 +1,486 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/observability"
+)
+
+const (
+	FederationModeHub   = "hub"
+	FederationModeSpoke = "spoke"
+
+	// maxClockSkew bounds how far in the future a snapshot may be dated.
+	maxClockSkew = 5 * time.Minute
+)
+
+var (
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+	ErrSourceConflict  = errors.New("cluster is reported by another source")
+
+	clusterNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+)
+
+type storedSnapshot struct {
+	Snapshot   internal.AllocationSnapshot `json:"snapshot"`
+	ReceivedAt time.Time                   `json:"received_at"`
+	LastError  string                      `json:"last_error,omitempty"`
+}
+
+// FederationService implements both sides of hub-and-spoke federation. A
+// spoke builds snapshots of its local clusters and pushes them to the hub;
+// a hub stores the latest snapshot per cluster on disk, optionally pulls
+// from its spokes, and serves the combined view.
+type FederationService struct {
+	clusters *ClusterService
+	config   *internal.ConfigStore
+	client   *http.Client
+	logger   *slog.Logger
+
+	mu        sync.RWMutex
+	snapshots map[string]*storedSnapshot
+}
+
+func NewFederationService(clusters *ClusterService, config *internal.ConfigStore, logger *slog.Logger) (*FederationService, error) {
+	s := &FederationService{
+		clusters: clusters,
+		config:   config,
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+		logger:    logger,
+		snapshots: make(map[string]*storedSnapshot),
+	}
+
+	federation := config.Current().Federation
+	if federation.Mode == FederationModeHub {
+		if err := s.load(federation.Hub.StoragePath); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *FederationService) Mode() string {
+	return s.config.Current().Federation.Mode
+}
+
+// Authenticate resolves a federation token to the peer that holds it. A
+// hub accepts the token of each spoke it lists and returns that spoke's
+// name; a spoke accepts its own token and returns an empty name.
+func (s *FederationService) Authenticate(token string) (string, bool) {
+	federation := s.config.Current().Federation
+	if federation.Mode == FederationModeSpoke {
+		return "", tokenMatches(token, federation.Token)
+	}
+
+	source, found := "", false
+	for _, spoke := range federation.Hub.Spokes {
+		if tokenMatches(token, spoke.Token) {
+			source, found = spoke.Name, true
+		}
+	}
+	return source, found
+}
+
+func tokenMatches(token, expected string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// BuildSnapshots captures the current allocation of every local cluster.
+func (s *FederationService) BuildSnapshots(ctx context.Context) ([]internal.AllocationSnapshot, error) {
+	ctx, span := observability.StartSpan(ctx, "FederationService.BuildSnapshots")
+	defer span.End()
+
+	cfg := s.config.Current()
+	source := cfg.Federation.Spoke.Name
+
+	results := fanOut(ctx, s.clusters.Clusters(), func(ctx context.Context, cluster *Cluster) (internal.AllocationSnapshot, error) {
+		namespaces, err := cluster.Cost.GetNamespaceCosts(ctx)
+		if err != nil {
+			return internal.AllocationSnapshot{}, err
+		}
+		nodes, err := cluster.Cost.GetNodeCosts(ctx)
+		if err != nil {
+			return internal.AllocationSnapshot{}, err
+		}
+		return newSnapshot(source, cluster.Name, cfg.Currency.Base, namespaces, nodes), nil
+	})
+
+	return collect(ctx, s.logger, results)
+}
+
+// RunSpoke pushes snapshots to the hub every interval until ctx is
+// cancelled. It does nothing if no hub URL is configured, in which case the
+// hub is expected to pull.
+func (s *FederationService) RunSpoke(ctx context.Context) {
+	for {
+		spoke := s.config.Current().Federation.Spoke
+		if spoke.HubURL == "" {
+			return
+		}
+
+		if err := s.Push(ctx, spoke.HubURL); err != nil {
+			s.logger.WarnContext(ctx, "failed to push snapshots to hub", "hub", spoke.HubURL, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(spoke.Interval):
+		}
+	}
+}
+
+func (s *FederationService) Push(ctx context.Context, hubURL string) (err error) {
+	start := time.Now()
+	defer func() {
+		observability.ObserveCollectorRun("federation_push", start, err)
+	}()
+
+	snapshots, err := s.BuildSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	if err := json.NewEncoder(gz).Encode(snapshots); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(hubURL, "/")+"/api/v1/federation/snapshots", &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Authorization", "Bearer "+s.config.Current().Federation.Token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("hub rejected snapshots with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// RunHub pulls snapshots from every configured spoke until ctx is cancelled.
+func (s *FederationService) RunHub(ctx context.Context) {
+	for {
+		hub := s.config.Current().Federation.Hub
+		if !slices.ContainsFunc(hub.Spokes, func(spoke internal.SpokeTarget) bool { return spoke.URL != "" }) {
+			return
+		}
+
+		for _, spoke := range hub.Spokes {
+			if spoke.URL == "" {
+				continue
+			}
+			if err := s.Pull(ctx, spoke); err != nil {
+				s.logger.WarnContext(ctx, "failed to pull snapshots from spoke", "spoke", spoke.Name, "error", err)
+				s.markError(spoke.Name, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(hub.PullInterval):
+		}
+	}
+}
+
+func (s *FederationService) Pull(ctx context.Context, spoke internal.SpokeTarget) (err error) {
+	start := time.Now()
+	defer func() {
+		observability.ObserveCollectorRun("federation_pull", start, err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(spoke.URL, "/")+"/api/v1/federation/snapshots", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+spoke.Token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("spoke returned status %d", resp.StatusCode)
+	}
+
+	var snapshots []internal.AllocationSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshots); err != nil {
+		return err
+	}
+
+	_, err = s.Ingest(spoke.Name, snapshots)
+	return err
+}
+
+// Ingest stores snapshots received from the spoke source, the name the
+// hub's configuration gives the spoke's credential; the source the
+// snapshots declare is ignored. A snapshot identical to the stored one, or
+// older than it, is skipped. It returns the number of snapshots that
+// replaced stored data.
+//
+// A cluster belongs to the source that first reported it: snapshots of it
+// from any other source are rejected with ErrSourceConflict, and nothing
+// of the batch is stored. To move a cluster to another spoke, delete its
+// file from the hub's storage path and restart the hub.
+func (s *FederationService) Ingest(source string, snapshots []internal.AllocationSnapshot) (int, error) {
+	for i := range snapshots {
+		snapshots[i].Source = source
+		if err := validateSnapshot(snapshots[i]); err != nil {
+			return 0, err
+		}
+	}
+
+	storagePath := s.config.Current().Federation.Hub.StoragePath
+	now := time.Now()
+	accepted := 0
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, snapshot := range snapshots {
+		if stored, exists := s.snapshots[snapshot.Cluster]; exists && stored.Snapshot.Source != snapshot.Source {
+			return 0, fmt.Errorf("%w: cluster %s belongs to %s, not %s", ErrSourceConflict, snapshot.Cluster, stored.Snapshot.Source, snapshot.Source)
+		}
+	}
+
+	for _, snapshot := range snapshots {
+		stored, exists := s.snapshots[snapshot.Cluster]
+		if exists && stored.Snapshot.ID == snapshot.ID {
+			// Unchanged data still proves the spoke is alive.
+			stored.ReceivedAt = now
+			stored.LastError = ""
+			continue
+		}
+		if exists && !snapshot.GeneratedAt.After(stored.Snapshot.GeneratedAt) {
+			continue
+		}
+
+		entry := &storedSnapshot{Snapshot: snapshot, ReceivedAt: now}
+		if err := persistSnapshot(storagePath, entry); err != nil {
+			return accepted, err
+		}
+		s.snapshots[snapshot.Cluster] = entry
+		accepted++
+	}
+
+	return accepted, nil
+}
+
+// View combines the latest snapshot of every cluster, converted by
+// converter at the rate in effect when each snapshot was generated.
+// Clusters whose data is older than the stale threshold are flagged, as is
+// the view as a whole. A cluster whose snapshot cannot be converted is
+// listed with the error and without costs.
+func (s *FederationService) View(converter *CurrencyConverter) internal.FederatedView {
+	staleAfter := s.config.Current().Federation.Hub.StaleAfter
+	now := time.Now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	view := internal.FederatedView{
+		Currency:   converter.Currency,
+		Clusters:   make([]internal.FederatedCluster, 0, len(s.snapshots)),
+		Namespaces: make([]internal.NamespaceCost, 0),
+		Timestamp:  now,
+	}
+
+	for _, stored := range s.snapshots {
+		snapshot := stored.Snapshot
+		namespaces := make([]internal.NamespaceCost, 0, len(snapshot.Namespaces))
+		lastError := stored.LastError
+		if rate, err := converter.RateFrom(snapshot.Currency, snapshot.GeneratedAt); err != nil {
+			lastError = err.Error()
+		} else {
+			for _, ns := range snapshot.Namespaces {
+				ns.Scale(rate)
+				namespaces = append(namespaces, ns)
+			}
+		}
+
+		cluster := internal.FederatedCluster{
+			Cluster:     snapshot.Cluster,
+			Source:      snapshot.Source,
+			SnapshotID:  snapshot.ID,
+			GeneratedAt: snapshot.GeneratedAt,
+			ReceivedAt:  stored.ReceivedAt,
+			Stale:       now.Sub(snapshot.GeneratedAt) > staleAfter && now.Sub(stored.ReceivedAt) > staleAfter,
+			LastError:   lastError,
+			Cost:        RollupNamespaces(snapshot.Cluster, namespaces),
+		}
+		view.Clusters = append(view.Clusters, cluster)
+		view.Stale = view.Stale || cluster.Stale
+
+		view.TotalCost.CPUCost = view.TotalCost.CPUCost.Add(cluster.Cost.CPUCost)
+		view.TotalCost.MemoryCost = view.TotalCost.MemoryCost.Add(cluster.Cost.MemoryCost)
+		view.TotalCost.StorageCost = view.TotalCost.StorageCost.Add(cluster.Cost.StorageCost)
+		view.TotalCost.NetworkCost = view.TotalCost.NetworkCost.Add(cluster.Cost.NetworkCost)
+		view.TotalCost.TotalCost = view.TotalCost.TotalCost.Add(cluster.Cost.TotalCost)
+		view.Namespaces = append(view.Namespaces, namespaces...)
+	}
+
+	sort.Slice(view.Clusters, func(i, j int) bool {
+		return view.Clusters[i].Cluster < view.Clusters[j].Cluster
+	})
+
+	return view
+}
+
+func (s *FederationService) markError(source string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.snapshots {
+		if stored.Snapshot.Source == source {
+			stored.LastError = err.Error()
+		}
+	}
+}
+
+func (s *FederationService) load(storagePath string) error {
+	if err := os.MkdirAll(storagePath, 0o755); err != nil {
+		return fmt.Errorf("failed to create federation storage: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(storagePath, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read snapshot %s: %v", file, err)
+		}
+
+		var stored storedSnapshot
+		if err := json.Unmarshal(data, &stored); err != nil {
+			s.logger.Warn("skipping corrupt snapshot", "file", file, "error", err)
+			continue
+		}
+		s.snapshots[stored.Snapshot.Cluster] = &stored
+	}
+
+	s.logger.Info("loaded federation snapshots", "count", len(s.snapshots))
+	return nil
+}
+
+// persistSnapshot writes entry atomically so a crash never leaves a
+// truncated snapshot behind.
+func persistSnapshot(storagePath string, entry *storedSnapshot) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(storagePath, entry.Snapshot.Cluster+".json"), data)
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the
+// same directory, so that readers never see a partial write.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, bytes.NewReader(data)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// newSnapshot builds a snapshot with timestamps stripped from its contents,
+// so that the content hash only changes when costs do.
+func newSnapshot(source, cluster, currency string, namespaces []internal.NamespaceCost, nodes []internal.NodeCost) internal.AllocationSnapshot {
+	for i := range namespaces {
+		namespaces[i].Timestamp = time.Time{}
+		for j := range namespaces[i].Pods {
+			namespaces[i].Pods[j].Timestamp = time.Time{}
+		}
+	}
+	for i := range nodes {
+		nodes[i].Timestamp = time.Time{}
+	}
+
+	snapshot := internal.AllocationSnapshot{
+		Source:      source,
+		Cluster:     cluster,
+		Currency:    currency,
+		GeneratedAt: time.Now(),
+		Namespaces:  namespaces,
+		Nodes:       nodes,
+	}
+	snapshot.ID = snapshotID(snapshot)
+
+	return snapshot
+}
+
+func snapshotID(snapshot internal.AllocationSnapshot) string {
+	hash := sha256.New()
+	hash.Write([]byte(snapshot.Cluster))
+	hash.Write([]byte(snapshot.Currency))
+	json.NewEncoder(hash).Encode(snapshot.Namespaces)
+	json.NewEncoder(hash).Encode(snapshot.Nodes)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func validateSnapshot(snapshot internal.AllocationSnapshot) error {
+	if !clusterNamePattern.MatchString(snapshot.Cluster) {
+		return fmt.Errorf("%w: invalid cluster name %q", ErrInvalidSnapshot, snapshot.Cluster)
+	}
+	if snapshot.Source == "" {
+		return fmt.Errorf("%w: cluster %s has no source", ErrInvalidSnapshot, snapshot.Cluster)
+	}
+	if snapshot.GeneratedAt.IsZero() || snapshot.GeneratedAt.After(time.Now().Add(maxClockSkew)) {
+		return fmt.Errorf("%w: cluster %s has an invalid generated_at", ErrInvalidSnapshot, snapshot.Cluster)
+	}
+	if snapshot.ID != snapshotID(snapshot) {
+		return fmt.Errorf("%w: cluster %s content does not match its id", ErrInvalidSnapshot, snapshot.Cluster)
+	}
+	return nil
+}
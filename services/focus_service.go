@@ -0,0 +1,607 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/focus"
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+	"github.com/SinghaAnirban005/KuBudget/pkg/observability"
+)
+
+const (
+	focusProviderName = "Kubernetes"
+	focusPublisher    = "KuBudget"
+	focusServiceName  = "Kubernetes"
+	focusFilePrefix   = "focus-"
+	focusFileSuffix   = ".ndjson"
+	focusGapSuffix    = ".gaps"
+
+	// focusRetryInterval is how soon an hour that failed to record is
+	// retried.
+	focusRetryInterval = 5 * time.Minute
+)
+
+var (
+	ErrPeriodNotFound = errors.New("billing period not found")
+	ErrLedgerGaps     = errors.New("billing period has unrecorded hours")
+)
+
+// focusGap is an hour the ledger has no charges for.
+type focusGap struct {
+	ChargePeriodStart time.Time `json:"ChargePeriodStart"`
+	ChargePeriodEnd   time.Time `json:"ChargePeriodEnd"`
+}
+
+// FocusService keeps a FOCUS billing ledger. At the top of every hour it
+// charges the hour that just ended at the rates sampled then, and appends
+// the records to the file of the billing period the hour belongs to.
+type FocusService struct {
+	clusters *ClusterService
+	config   *internal.ConfigStore
+	logger   *slog.Logger
+
+	// mu serializes appends so that an hour is never recorded twice.
+	mu           sync.Mutex
+	lastRecorded time.Time
+}
+
+func NewFocusService(clusters *ClusterService, config *internal.ConfigStore, logger *slog.Logger) (*FocusService, error) {
+	s := &FocusService{
+		clusters: clusters,
+		config:   config,
+		logger:   logger,
+	}
+
+	storagePath := config.Current().Focus.StoragePath
+	if err := os.MkdirAll(storagePath, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create FOCUS storage directory: %v", err)
+	}
+
+	last, err := s.lastChargePeriodEnd()
+	if err != nil {
+		return nil, err
+	}
+	s.lastRecorded = last
+
+	return s, nil
+}
+
+// Run records each hour as it ends until ctx is cancelled. An hour that
+// fails to record, because a cluster or Prometheus was unreachable, is
+// retried every few minutes until the next hour ends. Costs are sampled,
+// not kept, so an hour still unrecorded by then, or that passed while the
+// process was down, cannot be charged and is recorded as a gap instead.
+func (s *FocusService) Run(ctx context.Context) {
+	for {
+		wait := time.Until(time.Now().UTC().Truncate(time.Hour).Add(time.Hour))
+		if err := s.backfill(ctx); err != nil {
+			wait = min(wait, focusRetryInterval)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// backfill records the hour that just ended, after recording the hours
+// between it and the last recorded one as gaps. An empty ledger starts with
+// the hour that begins now.
+func (s *FocusService) backfill(ctx context.Context) error {
+	now := time.Now().UTC().Truncate(time.Hour)
+	last := s.LastRecorded().Truncate(time.Hour)
+	if last.IsZero() {
+		s.mu.Lock()
+		s.lastRecorded = now
+		s.mu.Unlock()
+		return nil
+	}
+	if !now.After(last) {
+		return nil
+	}
+
+	if missed := now.Add(-time.Hour); missed.After(last) {
+		if err := s.recordGap(last, missed); err != nil {
+			s.logger.ErrorContext(ctx, "failed to record FOCUS ledger gap", "charge_period_start", last, "charge_period_end", missed, "error", err)
+			return err
+		}
+		s.logger.WarnContext(ctx, "recorded FOCUS ledger gap, its hours are not charged", "charge_period_start", last, "charge_period_end", missed)
+	}
+
+	count, err := s.Record(ctx, now.Add(-time.Hour), now)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to record FOCUS charges", "charge_period_end", now, "error", err)
+		return err
+	}
+	s.logger.InfoContext(ctx, "recorded FOCUS charges", "charge_period_end", now, "records", count)
+	return nil
+}
+
+// recordGap records every hour of [start, end) as a gap in the gap file of
+// the billing period it belongs to.
+func (s *FocusService) recordGap(start, end time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lastRecorded.After(start) {
+		start = s.lastRecorded
+	}
+	storagePath := s.config.Current().Focus.StoragePath
+	for start.Before(end) {
+		period := focus.PeriodName(start)
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		for ; start.Before(end) && focus.PeriodName(start) == period; start = start.Add(time.Hour) {
+			if err := encoder.Encode(focusGap{ChargePeriodStart: start, ChargePeriodEnd: start.Add(time.Hour)}); err != nil {
+				return fmt.Errorf("failed to encode FOCUS ledger gap: %v", err)
+			}
+		}
+		if err := appendFile(s.gapPath(storagePath, period), buf.Bytes()); err != nil {
+			return fmt.Errorf("failed to write FOCUS ledger gaps: %v", err)
+		}
+		s.lastRecorded = start
+	}
+	return nil
+}
+
+// Gaps returns the start of every hour of a billing period the ledger has
+// no charges for, oldest first. Deleting the period's gap file accepts them
+// as uncharged.
+func (s *FocusService) Gaps(period string) ([]time.Time, error) {
+	if _, _, err := focus.ParsePeriod(period); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(s.gapPath(s.config.Current().Focus.StoragePath, period))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var gaps []time.Time
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var gap focusGap
+		if err := json.Unmarshal(line, &gap); err != nil {
+			continue
+		}
+		gaps = append(gaps, gap.ChargePeriodStart)
+	}
+	return gaps, nil
+}
+
+// LastRecorded returns the end of the latest hour in the ledger, charged
+// or recorded as a gap, or the time recording started when the ledger was
+// empty.
+func (s *FocusService) LastRecorded() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastRecorded
+}
+
+// Record charges every configured cluster for [start, end) and appends the
+// records to the ledger. Periods that end at or before the last recorded
+// one are skipped and report zero records.
+func (s *FocusService) Record(ctx context.Context, start, end time.Time) (int, error) {
+	ctx, span := observability.StartSpan(ctx, "FocusService.Record")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !end.After(s.lastRecorded) {
+		return 0, nil
+	}
+
+	clusters := s.clusters.Clusters()
+	namespaces, err := s.clusters.GetNamespaceCosts(ctx, clusters)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get namespace costs: %v", err)
+	}
+	nodes, err := s.clusters.GetNodeCosts(ctx, clusters)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get node costs: %v", err)
+	}
+
+	cfg := s.config.Current()
+	converter, err := s.clusters.Converter(cfg.Focus.Currency)
+	if err != nil {
+		return 0, err
+	}
+	records, err := FocusRecords(cfg, converter, namespaces, nodes, start, end)
+	if err != nil {
+		return 0, err
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i := range records {
+		if err := encoder.Encode(&records[i]); err != nil {
+			return 0, fmt.Errorf("failed to encode FOCUS record: %v", err)
+		}
+	}
+
+	path := s.periodPath(cfg.Focus.StoragePath, focus.PeriodName(start))
+	if err := appendFile(path, buf.Bytes()); err != nil {
+		return 0, fmt.Errorf("failed to write FOCUS ledger: %v", err)
+	}
+	s.lastRecorded = end
+
+	return len(records), nil
+}
+
+// Periods lists the billing periods that have a ledger file, newest first.
+func (s *FocusService) Periods() ([]string, error) {
+	return s.periods(focusFileSuffix)
+}
+
+// periods lists the billing periods that have a file ending in suffix,
+// newest first.
+func (s *FocusService) periods(suffix string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(s.config.Current().Focus.StoragePath, focusFilePrefix+"*"+suffix))
+	if err != nil {
+		return nil, err
+	}
+
+	periods := make([]string, 0, len(matches))
+	for _, match := range matches {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), focusFilePrefix), suffix)
+		if _, _, err := focus.ParsePeriod(name); err == nil {
+			periods = append(periods, name)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(periods)))
+	return periods, nil
+}
+
+// Scan calls fn for every record of a billing period in ledger order and
+// stops at the first error fn returns. Lines left incomplete by a crash
+// are skipped. It returns ErrPeriodNotFound when the period has no ledger
+// file.
+func (s *FocusService) Scan(period string, fn func(*focus.Record) error) error {
+	if _, _, err := focus.ParsePeriod(period); err != nil {
+		return err
+	}
+
+	file, err := os.Open(s.periodPath(s.config.Current().Focus.StoragePath, period))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrPeriodNotFound, period)
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record focus.Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			s.logger.Warn("skipping corrupt FOCUS ledger line", "period", period, "error", err)
+			continue
+		}
+		if err := fn(&record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *FocusService) periodPath(storagePath, period string) string {
+	return filepath.Join(storagePath, focusFilePrefix+period+focusFileSuffix)
+}
+
+func (s *FocusService) gapPath(storagePath, period string) string {
+	return filepath.Join(storagePath, focusFilePrefix+period+focusGapSuffix)
+}
+
+// lastChargePeriodEnd finds the end of the latest hour already in the
+// ledger, charged or recorded as a gap, so that a restart does not record
+// it again.
+func (s *FocusService) lastChargePeriodEnd() (time.Time, error) {
+	var last time.Time
+	periods, err := s.Periods()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(periods) > 0 {
+		err := s.Scan(periods[0], func(record *focus.Record) error {
+			if record.ChargePeriodEnd.After(last) {
+				last = record.ChargePeriodEnd
+			}
+			return nil
+		})
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	periods, err = s.periods(focusGapSuffix)
+	if err != nil || len(periods) == 0 {
+		return last, err
+	}
+	gaps, err := s.Gaps(periods[0])
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, gap := range gaps {
+		if end := gap.Add(time.Hour); end.After(last) {
+			last = end
+		}
+	}
+	return last, nil
+}
+
+// FocusRecords maps the costs of namespaces and nodes over [start, end) to
+// FOCUS records: one per pod and resource, plus one per node for capacity
+// no pod is charged for. Pod and node costs are hourly rates at base prices
+// and are scaled to the length of the charge period and by the multiplier
+// of the pricing period in effect, and converted by converter at the rate
+// in effect at the start of each charge period. [start, end) is split into
+// one charge period per pricing period it spans. Zero-cost records are
+// omitted.
+func FocusRecords(cfg *internal.Config, converter *CurrencyConverter, namespaces []internal.NamespaceCost, nodes []internal.NodeCost, start, end time.Time) ([]focus.Record, error) {
+	start, end = start.UTC(), end.UTC()
+
+	clusters := make(map[string]bool)
+	for _, ns := range namespaces {
+		clusters[ns.Cluster] = true
+	}
+	for _, node := range nodes {
+		clusters[node.Cluster] = true
+	}
+	bounds := []time.Time{end}
+	for cluster := range clusters {
+		for _, segment := range cfg.PricingFor(cluster).PricingSegments(start, end) {
+			bounds = append(bounds, segment.End)
+		}
+	}
+	slices.SortFunc(bounds, time.Time.Compare)
+	bounds = slices.CompactFunc(bounds, time.Time.Equal)
+
+	var records []focus.Record
+	for _, bound := range bounds {
+		rate, err := converter.Rate(start)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, focusChargePeriod(cfg, converter.Currency, rate, namespaces, nodes, start, bound.UTC())...)
+		start = bound.UTC()
+	}
+	return records, nil
+}
+
+// focusChargePeriod maps costs to the records of one charge period, which
+// lies within a single pricing period of every cluster, in currency at
+// rate.
+func focusChargePeriod(cfg *internal.Config, currency string, rate float64, namespaces []internal.NamespaceCost, nodes []internal.NodeCost, start, end time.Time) []focus.Record {
+	hours := end.Sub(start).Hours()
+	periodStart, periodEnd := focus.BillingPeriod(start)
+
+	base := focus.Record{
+		BillingAccountId:   cfg.Focus.BillingAccountID,
+		BillingAccountName: cfg.Focus.BillingAccountName,
+		BillingCurrency:    currency,
+		BillingPeriodStart: periodStart,
+		BillingPeriodEnd:   periodEnd,
+		ChargeCategory:     "Usage",
+		ChargeFrequency:    "Usage-Based",
+		ChargePeriodStart:  start,
+		ChargePeriodEnd:    end,
+		InvoiceIssuerName:  cfg.Focus.InvoiceIssuer,
+		PricingCategory:    "Standard",
+		ProviderName:       focusProviderName,
+		PublisherName:      focusPublisher,
+		ServiceName:        focusServiceName,
+	}
+
+	// Costs are after discounts and reconciliation, which are the contract;
+	// list is what the same usage costs at the configured prices.
+	var records []focus.Record
+	add := func(record focus.Record, cost, list money.Amount) {
+		if !cost.IsPositive() {
+			return
+		}
+		record.BilledCost = cost
+		record.EffectiveCost = cost
+		record.ListCost = list
+		record.ContractedCost = cost
+		records = append(records, record)
+	}
+
+	type nodeKey struct{ cluster, node string }
+	allocated := make(map[nodeKey]money.Amount)
+	allocatedList := make(map[nodeKey]money.Amount)
+
+	for _, ns := range namespaces {
+		pricing := cfg.PricingFor(ns.Cluster)
+		period, multiplier := pricing.PricingPeriodAt(start)
+		// Unit prices are converted along with costs.
+		scale := money.FromFloat(multiplier).Mul(rate)
+		priced := scale.Mul(hours)
+
+		for _, pod := range ns.Pods {
+			key := nodeKey{pod.Cluster, pod.Node}
+			allocated[key] = money.Sum(allocated[key], pod.CPUCost, pod.MemoryCost, pod.EphemeralStorageCost)
+			allocatedList[key] = money.Sum(allocatedList[key], listCost(pod.CPUCost.Add(pod.MemoryCost), pod.NodeRate), pod.EphemeralStorageCost)
+
+			record := base
+			record.ResourceId = pod.Cluster + "/" + pod.Namespace + "/" + pod.Name
+			record.ResourceName = pod.Name
+			record.ResourceType = "Pod"
+			record.SubAccountId = pod.Cluster
+			record.SubAccountName = pod.Cluster
+			record.Tags = podTags(pod, period)
+
+			cpu := record
+			cpu.ChargeDescription = "CPU usage of pod " + pod.Namespace + "/" + pod.Name
+			cpu.ServiceCategory = "Compute"
+			cpu.SkuId = "kubudget-cpu"
+			cpu.SkuPriceId = "kubudget-cpu-standard"
+			cpu.ConsumedQuantity = ptr(pod.CPUUsage * hours)
+			cpu.ConsumedUnit = ptr("vCPU-Hours")
+			cpu.PricingQuantity = cpu.ConsumedQuantity
+			cpu.PricingUnit = cpu.ConsumedUnit
+			cpu.ListUnitPrice = ptr(money.FromFloat(pricing.CPUCostPerHour).MulAmount(scale))
+			cpu.ContractedUnitPrice = ptr(cpu.ListUnitPrice.Mul(contracted(pod.NodeRate)))
+			add(cpu, pod.CPUCost.MulAmount(priced), listCost(pod.CPUCost, pod.NodeRate).MulAmount(priced))
+
+			memory := record
+			memory.ChargeDescription = "Memory usage of pod " + pod.Namespace + "/" + pod.Name
+			memory.ServiceCategory = "Compute"
+			memory.SkuId = "kubudget-memory"
+			memory.SkuPriceId = "kubudget-memory-standard"
+			memory.ConsumedQuantity = ptr(float64(pod.MemoryUsage) / (1024 * 1024 * 1024) * hours)
+			memory.ConsumedUnit = ptr("GB-Hours")
+			memory.PricingQuantity = memory.ConsumedQuantity
+			memory.PricingUnit = memory.ConsumedUnit
+			memory.ListUnitPrice = ptr(money.FromFloat(pricing.MemoryCostPerGB).MulAmount(scale))
+			memory.ContractedUnitPrice = ptr(memory.ListUnitPrice.Mul(contracted(pod.NodeRate)))
+			add(memory, pod.MemoryCost.MulAmount(priced), listCost(pod.MemoryCost, pod.NodeRate).MulAmount(priced))
+
+			storage := record
+			storage.ChargeDescription = "Storage of pod " + pod.Namespace + "/" + pod.Name
+			storage.ServiceCategory = "Storage"
+			storage.SkuId = "kubudget-storage"
+			storage.SkuPriceId = "kubudget-storage-standard"
+			add(storage, pod.StorageCost.MulAmount(priced), listCost(pod.StorageCost, pod.StorageRate).MulAmount(priced))
+
+			ephemeral := record
+			ephemeral.ChargeDescription = "Ephemeral storage of pod " + pod.Namespace + "/" + pod.Name
+			ephemeral.ServiceCategory = "Storage"
+			ephemeral.SkuId = "kubudget-ephemeral-storage"
+			ephemeral.SkuPriceId = "kubudget-ephemeral-storage-standard"
+			ephemeral.ListUnitPrice = ptr(money.FromFloat(pricing.EphemeralStorageCostPerGB).MulAmount(scale))
+			ephemeral.ContractedUnitPrice = ephemeral.ListUnitPrice
+			add(ephemeral, pod.EphemeralStorageCost.MulAmount(priced), pod.EphemeralStorageCost.MulAmount(priced))
+
+			for _, class := range slices.Sorted(maps.Keys(pod.NetworkEgress)) {
+				network := record
+				network.ChargeDescription = strings.ReplaceAll(class, "_", "-") + " egress of pod " + pod.Namespace + "/" + pod.Name
+				network.ServiceCategory = "Networking"
+				network.SkuId = "kubudget-network"
+				network.SkuPriceId = "kubudget-network-" + strings.ReplaceAll(class, "_", "-")
+				network.ListUnitPrice = ptr(money.FromFloat(networkRate(pricing.Network, class)).MulAmount(scale))
+				network.ContractedUnitPrice = network.ListUnitPrice
+				add(network, pod.NetworkEgress[class].MulAmount(priced), pod.NetworkEgress[class].MulAmount(priced))
+			}
+
+			for _, resource := range slices.Sorted(maps.Keys(pod.ExtendedResources)) {
+				extended := record
+				extended.ChargeDescription = resource + " requested by pod " + pod.Namespace + "/" + pod.Name
+				extended.ServiceCategory = "Compute"
+				extended.SkuId = "kubudget-" + resource
+				extended.SkuPriceId = "kubudget-" + resource + "-standard"
+				cost := pod.ExtendedResources[resource]
+				add(extended, cost.MulAmount(priced), listCost(cost, pod.NodeRate).MulAmount(priced))
+			}
+		}
+	}
+
+	for _, node := range nodes {
+		period, multiplier := cfg.PricingFor(node.Cluster).PricingPeriodAt(start)
+		priced := money.FromFloat(multiplier).Mul(rate).Mul(hours)
+		key := nodeKey{node.Cluster, node.Name}
+		idle := money.Sum(node.CPUCost, node.MemoryCost, node.BootDiskCost).Sub(allocated[key])
+		idleList := money.Sum(listCost(node.CPUCost.Add(node.MemoryCost), node.Rate), node.BootDiskCost).Sub(allocatedList[key])
+
+		record := base
+		record.ChargeDescription = "Capacity of node " + node.Name + " not used by any pod"
+		record.ResourceId = node.Cluster + "/nodes/" + node.Name
+		record.ResourceName = node.Name
+		record.ResourceType = "Node"
+		record.ServiceCategory = "Compute"
+		record.SkuId = "kubudget-idle"
+		record.SkuPriceId = "kubudget-idle-standard"
+		record.SubAccountId = node.Cluster
+		record.SubAccountName = node.Cluster
+		record.Tags = map[string]string{"kubudget/node": node.Name, "kubudget/pricing-period": period}
+		add(record, idle.MulAmount(priced), idleList.MulAmount(priced))
+
+		extended := record
+		extended.ChargeDescription = "Extended resources of node " + node.Name + " not requested by any pod"
+		extended.SkuId = "kubudget-extended-idle"
+		extended.SkuPriceId = "kubudget-extended-idle-standard"
+		add(extended, node.ExtendedIdleCost.MulAmount(priced), listCost(node.ExtendedIdleCost, node.Rate).MulAmount(priced))
+	}
+
+	return records
+}
+
+// listCost undoes the factor that adjustments scaled cost by. A factor of
+// zero, fully credited or from a spoke that predates rates, leaves cost as
+// it is.
+func listCost(cost money.Amount, rate float64) money.Amount {
+	if rate <= 0 {
+		return cost
+	}
+	return cost.Div(rate)
+}
+
+// contracted is the factor between contracted and list unit prices.
+func contracted(rate float64) float64 {
+	if rate <= 0 {
+		return 1
+	}
+	return rate
+}
+
+// podTags are the pod's labels plus KuBudget's own tags, which win on
+// conflict so that records can always be grouped by namespace, workload
+// and pricing period.
+func podTags(pod internal.PodCost, period string) map[string]string {
+	tags := make(map[string]string, len(pod.Labels)+5)
+	for key, value := range pod.Labels {
+		tags[key] = value
+	}
+	tags["kubudget/namespace"] = pod.Namespace
+	tags["kubudget/node"] = pod.Node
+	tags["kubudget/pricing-period"] = period
+	if pod.Controller != "" {
+		tags["kubudget/controller-kind"] = pod.ControllerKind
+		tags["kubudget/controller"] = pod.Controller
+	}
+	return tags
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// appendFile appends data to path in a single write and syncs it, so that
+// a crash leaves at most one partial hour at the end of the file.
+func appendFile(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
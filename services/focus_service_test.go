@@ -0,0 +1,66 @@
+package services
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+)
+
+func TestRecordGap(t *testing.T) {
+	cfg := &internal.Config{}
+	cfg.Focus.StoragePath = t.TempDir()
+	config := internal.NewConfigStore(cfg)
+	logger := slog.New(slog.DiscardHandler)
+
+	s, err := NewFocusService(nil, config, logger)
+	if err != nil {
+		t.Fatalf("NewFocusService: %v", err)
+	}
+
+	// Three hours across the end of September, recorded twice: the second
+	// time is a no-op.
+	start := time.Date(2026, 9, 30, 22, 0, 0, 0, time.UTC)
+	end := start.Add(3 * time.Hour)
+	for range 2 {
+		if err := s.recordGap(start, end); err != nil {
+			t.Fatalf("recordGap: %v", err)
+		}
+	}
+
+	tests := []struct {
+		period string
+		want   []time.Time
+	}{
+		{"2026-09", []time.Time{start, start.Add(time.Hour)}},
+		{"2026-10", []time.Time{start.Add(2 * time.Hour)}},
+		{"2026-11", nil},
+	}
+
+	for _, tt := range tests {
+		gaps, err := s.Gaps(tt.period)
+		if err != nil {
+			t.Fatalf("Gaps(%s): %v", tt.period, err)
+		}
+		if len(gaps) != len(tt.want) {
+			t.Fatalf("Gaps(%s) = %v, want %v", tt.period, gaps, tt.want)
+		}
+		for i := range gaps {
+			if !gaps[i].Equal(tt.want[i]) {
+				t.Errorf("Gaps(%s)[%d] = %s, want %s", tt.period, i, gaps[i], tt.want[i])
+			}
+		}
+	}
+
+	if got := s.LastRecorded(); !got.Equal(end) {
+		t.Errorf("LastRecorded = %s, want %s", got, end)
+	}
+	restarted, err := NewFocusService(nil, config, logger)
+	if err != nil {
+		t.Fatalf("NewFocusService after restart: %v", err)
+	}
+	if got := restarted.LastRecorded(); !got.Equal(end) {
+		t.Errorf("LastRecorded after restart = %s, want %s", got, end)
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/observability"
+)
+
+const (
+	CheckStatusOK       = "ok"
+	CheckStatusDegraded = "degraded"
+	CheckStatusFail     = "fail"
+)
+
+// healthCheck checks one dependency. Checks of a cluster's dependencies
+// name the cluster; process-level checks do not.
+type healthCheck struct {
+	name    string
+	cluster string
+	check   func(ctx context.Context) error
+}
+
+// HealthService runs dependency checks for the readiness and startup probes.
+// Results are cached for a short TTL so that frequent probes from several
+// kubelets do not hammer the API server and Prometheus.
+type HealthService struct {
+	checks []healthCheck
+	config *internal.ConfigStore
+
+	mu    sync.Mutex
+	cache map[string]internal.CheckResult
+
+	started atomic.Bool
+}
+
+// NewHealthService registers the dependency checks of every cluster. Checks
+// are named "<dependency>:<cluster>" so a single unreachable cluster is
+// identifiable in the probe response.
+func NewHealthService(clusters *ClusterService, costExporter *CostExporter, config *internal.ConfigStore) *HealthService {
+	checks := make([]healthCheck, 0, 3*len(clusters.Clusters())+1)
+	for _, cluster := range clusters.Clusters() {
+		checks = append(checks,
+			healthCheck{name: "kubernetes:" + cluster.Name, cluster: cluster.Name, check: cluster.K8sClient.ServerVersion},
+			healthCheck{name: "prometheus:" + cluster.Name, cluster: cluster.Name, check: cluster.PromClient.Ready},
+			healthCheck{name: "metrics_server:" + cluster.Name, cluster: cluster.Name, check: cluster.K8sClient.MetricsAPIAvailable},
+		)
+	}
+	checks = append(checks, healthCheck{name: "collector", check: func(ctx context.Context) error {
+		return checkCollector(costExporter, config.Current().Collectors.Interval)
+	}})
+
+	return &HealthService{
+		checks: checks,
+		config: config,
+		cache:  make(map[string]internal.CheckResult),
+	}
+}
+
+// Ready runs every dependency check. The instance is ready while every
+// process-level check passes and at least one cluster has all of its
+// checks passing, since the healthy clusters are still served. It is
+// degraded when any cluster check fails; those checks report
+// CheckStatusDegraded rather than CheckStatusFail unless every cluster is
+// down.
+func (s *HealthService) Ready(ctx context.Context) (results map[string]internal.CheckResult, ready, degraded bool) {
+	results = make(map[string]internal.CheckResult, len(s.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, hc := range s.checks {
+		wg.Add(1)
+		go func(hc healthCheck) {
+			defer wg.Done()
+			result := s.run(ctx, hc)
+
+			mu.Lock()
+			results[hc.name] = result
+			mu.Unlock()
+		}(hc)
+	}
+	wg.Wait()
+
+	ready = true
+	down := make(map[string]bool)
+	for _, hc := range s.checks {
+		failed := results[hc.name].Status != CheckStatusOK
+		switch {
+		case hc.cluster != "":
+			down[hc.cluster] = down[hc.cluster] || failed
+		case failed:
+			ready = false
+		}
+	}
+
+	healthy := 0
+	for _, isDown := range down {
+		if !isDown {
+			healthy++
+		}
+	}
+	if len(down) > 0 && healthy == 0 {
+		ready = false
+	}
+	degraded = healthy < len(down)
+
+	if ready {
+		for _, hc := range s.checks {
+			if result := results[hc.name]; hc.cluster != "" && result.Status == CheckStatusFail {
+				result.Status = CheckStatusDegraded
+				results[hc.name] = result
+			}
+		}
+	}
+
+	return results, ready, degraded
+}
+
+// Started reports whether startup has completed. Once the service has been
+// ready a single time it stays started, so that transient outages are
+// handled by readiness rather than by restarting the pod.
+func (s *HealthService) Started(ctx context.Context) (map[string]internal.CheckResult, bool) {
+	if s.started.Load() {
+		return nil, true
+	}
+
+	results, ready, _ := s.Ready(ctx)
+	if ready {
+		s.started.Store(true)
+	}
+
+	return results, ready
+}
+
+func (s *HealthService) run(ctx context.Context, hc healthCheck) internal.CheckResult {
+	s.mu.Lock()
+	cached, exists := s.cache[hc.name]
+	s.mu.Unlock()
+
+	server := s.config.Current().Server
+
+	fresh := exists && time.Since(cached.CheckedAt) < server.HealthCacheTTL
+	observability.ObserveCacheLookup("health_check", fresh)
+	if fresh {
+		return cached
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, server.HealthTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := hc.check(checkCtx)
+
+	result := internal.CheckResult{
+		Status:    CheckStatusOK,
+		Duration:  time.Since(start).String(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = CheckStatusFail
+		result.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	s.cache[hc.name] = result
+	s.mu.Unlock()
+
+	return result
+}
+
+// checkCollector fails until the cost exporter has completed its first
+// refresh, and again if refreshes have stalled for several intervals.
+func checkCollector(costExporter *CostExporter, interval time.Duration) error {
+	lastRefresh := costExporter.LastRefresh()
+	if lastRefresh.IsZero() {
+		return fmt.Errorf("cost collector has not completed its initial sync")
+	}
+
+	if age := time.Since(lastRefresh); age > 3*interval {
+		return fmt.Errorf("cost collector last synced %s ago", age.Round(time.Second))
+	}
+
+	return nil
+}
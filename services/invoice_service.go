@@ -0,0 +1,578 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/focus"
+	"github.com/SinghaAnirban005/KuBudget/pkg/invoice"
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+	"github.com/SinghaAnirban005/KuBudget/pkg/observability"
+	"github.com/google/uuid"
+)
+
+const adjustmentsFile = "adjustments.json"
+
+var (
+	ErrPeriodClosed      = errors.New("billing period already closed")
+	ErrPeriodOpen        = errors.New("billing period is still open")
+	ErrInvoiceNotFound   = errors.New("invoice not found")
+	ErrInvalidAdjustment = errors.New("invalid adjustment")
+
+	invoiceNumberPattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+)
+
+// InvoiceService closes billing periods of the FOCUS ledger into one
+// chargeback invoice per cost center. The invoices of a period are written
+// together, read-only, into a directory of their own that is never written
+// again: a closed period cannot be closed twice or adjusted, and an invoice
+// whose contents no longer match its hash is refused when read.
+type InvoiceService struct {
+	focus  *FocusService
+	config *internal.ConfigStore
+	logger *slog.Logger
+
+	// mu serializes closing periods and adding adjustments, so that an
+	// adjustment is either on an invoice or refused.
+	mu sync.Mutex
+}
+
+func NewInvoiceService(focusService *FocusService, config *internal.ConfigStore, logger *slog.Logger) (*InvoiceService, error) {
+	storagePath := config.Current().Invoicing.StoragePath
+	if err := os.MkdirAll(storagePath, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create invoice storage directory: %v", err)
+	}
+
+	return &InvoiceService{
+		focus:  focusService,
+		config: config,
+		logger: logger,
+	}, nil
+}
+
+// Run closes every ledger period that ended at least close_after ago and
+// is not closed yet, until ctx is cancelled.
+func (s *InvoiceService) Run(ctx context.Context) {
+	for {
+		s.closeDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.config.Current().Invoicing.Interval):
+		}
+	}
+}
+
+func (s *InvoiceService) closeDue(ctx context.Context) {
+	periods, err := s.focus.Periods()
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list billing periods", "error", err)
+		return
+	}
+
+	cfg := s.config.Current().Invoicing
+	for _, period := range periods {
+		_, end, err := focus.ParsePeriod(period)
+		if err != nil || time.Now().Before(end.Add(cfg.CloseAfter)) || s.closed(cfg.StoragePath, period) {
+			continue
+		}
+		invoices, err := s.Close(ctx, period)
+		if errors.Is(err, ErrPeriodClosed) {
+			continue
+		}
+		if errors.Is(err, ErrPeriodOpen) {
+			s.logger.InfoContext(ctx, "billing period not ready to close", "period", period, "reason", err)
+			continue
+		}
+		if errors.Is(err, ErrLedgerGaps) {
+			s.logger.WarnContext(ctx, "billing period has unrecorded hours and is not closed", "period", period, "reason", err)
+			continue
+		}
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to close billing period", "period", period, "error", err)
+			continue
+		}
+		s.logger.InfoContext(ctx, "closed billing period", "period", period, "invoices", len(invoices))
+	}
+}
+
+// Close issues the invoices of a billing period. Until close_after has
+// passed since the period ended and the ledger has recorded every hour of
+// it, the period is still open and Close returns ErrPeriodOpen. It returns
+// ErrLedgerGaps while hours of the period are recorded as gaps, and
+// ErrPeriodClosed when the period already has invoices. ErrPeriodNotFound
+// is only returned by the ledger scan, once the period is closable, when
+// the ledger holds no records for it.
+func (s *InvoiceService) Close(ctx context.Context, period string) ([]invoice.Summary, error) {
+	_, span := observability.StartSpan(ctx, "InvoiceService.Close")
+	defer span.End()
+
+	_, end, err := focus.ParsePeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	if closable := end.Add(s.config.Current().Invoicing.CloseAfter); time.Now().Before(closable) {
+		return nil, fmt.Errorf("%w: %s can be closed from %s", ErrPeriodOpen, period, closable.Format(time.RFC3339))
+	}
+	if recorded := s.focus.LastRecorded(); recorded.Before(end) {
+		return nil, fmt.Errorf("%w: the FOCUS ledger has only recorded %s up to %s", ErrPeriodOpen, period, recorded.Format(time.RFC3339))
+	}
+	gaps, err := s.focus.Gaps(period)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FOCUS ledger gaps: %v", err)
+	}
+	if len(gaps) > 0 {
+		return nil, fmt.Errorf("%w: the FOCUS ledger has no charges for %d hours of %s, from %s", ErrLedgerGaps, len(gaps), period, gaps[0].Format(time.RFC3339))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg := s.config.Current()
+	storagePath := cfg.Invoicing.StoragePath
+	if s.closed(storagePath, period) {
+		return nil, fmt.Errorf("%w: %s", ErrPeriodClosed, period)
+	}
+
+	adjustments, err := s.loadAdjustments(storagePath)
+	if err != nil {
+		return nil, err
+	}
+	invoices, err := buildInvoices(cfg, period, adjustments, time.Now().UTC(), func(fn func(*focus.Record) error) error {
+		return s.focus.Scan(period, fn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeInvoices(storagePath, period, invoices); err != nil {
+		return nil, fmt.Errorf("failed to write invoices: %v", err)
+	}
+
+	summaries := make([]invoice.Summary, len(invoices))
+	for i := range invoices {
+		summaries[i] = invoices[i].Summary()
+	}
+	return summaries, nil
+}
+
+// Invoices lists the invoices of a closed period, or of every closed
+// period when period is empty, newest period first and by number within a
+// period.
+func (s *InvoiceService) Invoices(period string) ([]invoice.Summary, error) {
+	storagePath := s.config.Current().Invoicing.StoragePath
+	periods := []string{period}
+	if period == "" {
+		var err error
+		if periods, err = s.closedPeriods(storagePath); err != nil {
+			return nil, err
+		}
+	}
+
+	summaries := make([]invoice.Summary, 0)
+	for _, period := range periods {
+		paths, err := filepath.Glob(filepath.Join(storagePath, period, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			inv, err := readInvoice(path)
+			if err != nil {
+				return nil, err
+			}
+			summaries = append(summaries, inv.Summary())
+		}
+	}
+	return summaries, nil
+}
+
+// Invoice reads an invoice by number and verifies its content hash.
+func (s *InvoiceService) Invoice(number string) (*invoice.Invoice, error) {
+	if !invoiceNumberPattern.MatchString(number) {
+		return nil, fmt.Errorf("%w: %s", ErrInvoiceNotFound, number)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(s.config.Current().Invoicing.StoragePath, "*", number+".json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvoiceNotFound, number)
+	}
+	return readInvoice(paths[0])
+}
+
+// Adjustments lists the adjustments of a period, or of every period when
+// period is empty, in the order they were added.
+func (s *InvoiceService) Adjustments(period string) ([]invoice.Adjustment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	adjustments, err := s.loadAdjustments(s.config.Current().Invoicing.StoragePath)
+	if err != nil {
+		return nil, err
+	}
+	if period == "" {
+		return adjustments, nil
+	}
+	return slices.DeleteFunc(adjustments, func(adjustment invoice.Adjustment) bool {
+		return adjustment.Period != period
+	}), nil
+}
+
+// AddAdjustment records a credit or charge to be added to a cost center's
+// invoice when its period is closed, and returns it with its ID. Periods
+// that are already closed cannot be adjusted.
+func (s *InvoiceService) AddAdjustment(adjustment invoice.Adjustment) (invoice.Adjustment, error) {
+	cfg := s.config.Current().Invoicing
+	if _, _, err := focus.ParsePeriod(adjustment.Period); err != nil {
+		return invoice.Adjustment{}, fmt.Errorf("%w: %v", ErrInvalidAdjustment, err)
+	}
+	if adjustment.CostCenter != cfg.Unallocated && !slices.ContainsFunc(cfg.CostCenters, func(center internal.CostCenterConfig) bool {
+		return center.Name == adjustment.CostCenter
+	}) {
+		return invoice.Adjustment{}, fmt.Errorf("%w: unknown cost center %q", ErrInvalidAdjustment, adjustment.CostCenter)
+	}
+	if strings.TrimSpace(adjustment.Description) == "" {
+		return invoice.Adjustment{}, fmt.Errorf("%w: description is required", ErrInvalidAdjustment)
+	}
+	if adjustment.Amount.IsZero() {
+		return invoice.Adjustment{}, fmt.Errorf("%w: amount must not be zero", ErrInvalidAdjustment)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed(cfg.StoragePath, adjustment.Period) {
+		return invoice.Adjustment{}, fmt.Errorf("%w: %s", ErrPeriodClosed, adjustment.Period)
+	}
+
+	adjustments, err := s.loadAdjustments(cfg.StoragePath)
+	if err != nil {
+		return invoice.Adjustment{}, err
+	}
+	adjustment.ID = uuid.NewString()
+	adjustment.CreatedAt = time.Now().UTC()
+	adjustments = append(adjustments, adjustment)
+
+	data, err := json.MarshalIndent(adjustments, "", "  ")
+	if err != nil {
+		return invoice.Adjustment{}, err
+	}
+	if err := writeFileAtomic(filepath.Join(cfg.StoragePath, adjustmentsFile), data); err != nil {
+		return invoice.Adjustment{}, fmt.Errorf("failed to store adjustment: %v", err)
+	}
+	return adjustment, nil
+}
+
+func (s *InvoiceService) closed(storagePath, period string) bool {
+	_, err := os.Stat(filepath.Join(storagePath, period))
+	return err == nil
+}
+
+// closedPeriods lists the periods that have invoices, newest first.
+func (s *InvoiceService) closedPeriods(storagePath string) ([]string, error) {
+	entries, err := os.ReadDir(storagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var periods []string
+	for _, entry := range entries {
+		if _, _, err := focus.ParsePeriod(entry.Name()); entry.IsDir() && err == nil {
+			periods = append(periods, entry.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(periods)))
+	return periods, nil
+}
+
+func (s *InvoiceService) loadAdjustments(storagePath string) ([]invoice.Adjustment, error) {
+	data, err := os.ReadFile(filepath.Join(storagePath, adjustmentsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var adjustments []invoice.Adjustment
+	if err := json.Unmarshal(data, &adjustments); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", adjustmentsFile, err)
+	}
+	return adjustments, nil
+}
+
+// invoiceCharge keys the usage line items of an invoice.
+type invoiceCharge struct {
+	namespace, workload, resource string
+}
+
+// buildInvoices charges every pod record of a period to its cost center
+// and splits the costs of shared namespaces and of idle node capacity
+// between cost centers in proportion to their rounded usage. Without any
+// usage, both go to the unallocated cost center. Every line item is
+// rounded, so that each invoice totals exactly the sum of its lines.
+func buildInvoices(cfg *internal.Config, period string, adjustments []invoice.Adjustment, issuedAt time.Time, scan func(func(*focus.Record) error) error) ([]invoice.Invoice, error) {
+	invoicing := cfg.Invoicing
+	rounding := invoicing.Presentation(cfg.Currency)
+	start, end, err := focus.ParsePeriod(period)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make(map[string]map[invoiceCharge]money.Amount)
+	shared := make(map[string]money.Amount)
+	var idle money.Amount
+	currency := ""
+	err = scan(func(record *focus.Record) error {
+		if currency == "" {
+			currency = record.BillingCurrency
+		} else if record.BillingCurrency != currency {
+			return fmt.Errorf("billing period %s mixes %s and %s charges", period, currency, record.BillingCurrency)
+		}
+
+		switch record.ResourceType {
+		case "Pod":
+			namespace := record.Tags["kubudget/namespace"]
+			if matchesAny(invoicing.SharedNamespaces, namespace) {
+				shared[namespace] = shared[namespace].Add(record.BilledCost)
+				return nil
+			}
+			center := costCenter(invoicing, namespace, record.Tags)
+			if usage[center] == nil {
+				usage[center] = make(map[invoiceCharge]money.Amount)
+			}
+			charge := invoiceCharge{namespace, recordWorkload(record), recordResource(record)}
+			usage[center][charge] = usage[center][charge].Add(record.BilledCost)
+		case "Node":
+			idle = idle.Add(record.BilledCost)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[string][]invoice.LineItem)
+	for center, charges := range usage {
+		for _, charge := range slices.SortedFunc(maps.Keys(charges), compareCharges) {
+			amount := rounding.Round(charges[charge])
+			if amount.IsZero() {
+				continue
+			}
+			items[center] = append(items[center], invoice.LineItem{
+				Category:    invoice.CategoryUsage,
+				Namespace:   charge.namespace,
+				Workload:    charge.workload,
+				Resource:    charge.resource,
+				Description: charge.resource + " of " + charge.namespace + "/" + charge.workload,
+				Amount:      amount,
+			})
+		}
+	}
+
+	// Pools are split by the rounded usage subtotals, which are what the
+	// invoices show.
+	centers := slices.Sorted(maps.Keys(items))
+	weights := make([]money.Amount, len(centers))
+	for i, center := range centers {
+		for _, item := range items[center] {
+			weights[i] = weights[i].Add(item.Amount)
+		}
+		if !weights[i].IsPositive() {
+			weights[i] = money.Zero
+		}
+	}
+	allocate := func(category, description string, pool money.Amount) {
+		shares := rounding.Allocate(pool, weights)
+		if shares == nil {
+			centers, shares = []string{invoicing.Unallocated}, []money.Amount{rounding.Round(pool)}
+		}
+		for i, center := range centers {
+			if shares[i].IsZero() {
+				continue
+			}
+			items[center] = append(items[center], invoice.LineItem{
+				Category:    category,
+				Description: description,
+				Amount:      shares[i],
+			})
+		}
+	}
+	for _, namespace := range slices.Sorted(maps.Keys(shared)) {
+		allocate(invoice.CategoryShared, "Share of shared namespace "+namespace, shared[namespace])
+	}
+	allocate(invoice.CategoryIdle, "Share of idle node capacity", idle)
+
+	for _, center := range invoicing.CostCenters {
+		if center.Discount == 0 || len(items[center.Name]) == 0 {
+			continue
+		}
+		var charged money.Amount
+		for _, item := range items[center.Name] {
+			charged = charged.Add(item.Amount)
+		}
+		discount := rounding.Round(charged.Mul(center.Discount)).Neg()
+		if discount.IsZero() {
+			continue
+		}
+		items[center.Name] = append(items[center.Name], invoice.LineItem{
+			Category:    invoice.CategoryDiscount,
+			Description: money.FromFloat(center.Discount).Mul(100).String() + "% discount",
+			Amount:      discount,
+		})
+	}
+
+	for _, adjustment := range adjustments {
+		if adjustment.Period != period {
+			continue
+		}
+		items[adjustment.CostCenter] = append(items[adjustment.CostCenter], invoice.LineItem{
+			Category:    invoice.CategoryAdjustment,
+			Description: adjustment.Description,
+			Amount:      rounding.Round(adjustment.Amount),
+		})
+	}
+
+	invoices := make([]invoice.Invoice, 0, len(items))
+	for i, center := range slices.Sorted(maps.Keys(items)) {
+		inv := invoice.Invoice{
+			Number:      invoice.Number(invoicing.NumberPrefix, period, i+1),
+			CostCenter:  center,
+			Period:      period,
+			PeriodStart: start,
+			PeriodEnd:   end,
+			Issuer:      cfg.Focus.InvoiceIssuer,
+			Account:     cfg.Focus.BillingAccountName,
+			Currency:    currency,
+			Precision:   rounding.Places,
+			IssuedAt:    issuedAt,
+			LineItems:   items[center],
+		}
+		if inv.Currency == "" {
+			inv.Currency = cmp.Or(cfg.Focus.Currency, cfg.Currency.Base)
+		}
+		if err := inv.Seal(); err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, inv)
+	}
+	return invoices, nil
+}
+
+// costCenter returns the first cost center whose namespaces and labels
+// match a pod, or the unallocated cost center.
+func costCenter(cfg internal.InvoicingConfig, namespace string, tags map[string]string) string {
+	for _, center := range cfg.CostCenters {
+		if len(center.Namespaces) > 0 && !matchesAny(center.Namespaces, namespace) {
+			continue
+		}
+		matched := true
+		for key, value := range center.Labels {
+			if tags[key] != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return center.Name
+		}
+	}
+	return cfg.Unallocated
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// recordWorkload names the controller of a pod record, or the pod itself
+// when it has none.
+func recordWorkload(record *focus.Record) string {
+	if controller := record.Tags["kubudget/controller"]; controller != "" {
+		return record.Tags["kubudget/controller-kind"] + "/" + controller
+	}
+	return "Pod/" + record.ResourceName
+}
+
+// recordResource names the resource a record charges for, such as cpu or
+// network-cross-zone.
+func recordResource(record *focus.Record) string {
+	if record.SkuId == "kubudget-network" {
+		return strings.TrimPrefix(record.SkuPriceId, "kubudget-")
+	}
+	return strings.TrimPrefix(record.SkuId, "kubudget-")
+}
+
+func compareCharges(a, b invoiceCharge) int {
+	if c := strings.Compare(a.namespace, b.namespace); c != 0 {
+		return c
+	}
+	if c := strings.Compare(a.workload, b.workload); c != 0 {
+		return c
+	}
+	return strings.Compare(a.resource, b.resource)
+}
+
+// writeInvoices writes the invoices of a period read-only into a temporary
+// directory and renames it into place, so that a period is either closed
+// with all its invoices or not at all.
+func writeInvoices(storagePath, period string, invoices []invoice.Invoice) error {
+	tmp, err := os.MkdirTemp(storagePath, ".tmp-"+period+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	for i := range invoices {
+		data, err := json.MarshalIndent(&invoices[i], "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(tmp, invoices[i].Number+".json"), data, 0o440); err != nil {
+			return err
+		}
+	}
+	closed := filepath.Join(storagePath, period)
+	if err := os.Rename(tmp, closed); err != nil {
+		return err
+	}
+	return os.Chmod(closed, 0o550)
+}
+
+func readInvoice(path string) (*invoice.Invoice, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var inv invoice.Invoice
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return nil, fmt.Errorf("failed to parse invoice %s: %v", filepath.Base(path), err)
+	}
+	if err := invoice.Verify(&inv); err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
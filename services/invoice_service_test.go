@@ -0,0 +1,149 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/focus"
+	"github.com/SinghaAnirban005/KuBudget/pkg/invoice"
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+)
+
+// TestBuildInvoices checks, under every rounding mode, that each invoice's
+// total is the sum of its rounded line items and that the shared and idle
+// pools are split without losing or inventing a cent.
+func TestBuildInvoices(t *testing.T) {
+	records := []focus.Record{
+		podRecord("api", "api-7d9f", "cpu", "1.001"),
+		podRecord("api", "api-7d9f", "memory", "0.3335"),
+		podRecord("billing", "billing-0", "cpu", "2.0049"),
+		podRecord("batch", "batch-x1", "cpu", "3.333"),
+		podRecord("batch", "batch-x1", "network", "0.0049"),
+		podRecord("scratch", "debug", "cpu", "0.0151"),
+		podRecord("monitoring", "prometheus-0", "cpu", "10"),
+		podRecord("monitoring", "prometheus-0", "memory", "0.005"),
+		nodeRecord("node-a", "4.4444"),
+		nodeRecord("node-b", "3.3333"),
+	}
+	adjustments := []invoice.Adjustment{
+		{Period: "2026-09", CostCenter: "batch", Description: "Credit for the outage", Amount: mustParse("-0.505")},
+		{Period: "2026-08", CostCenter: "batch", Description: "Last month", Amount: mustParse("-100")},
+	}
+
+	for _, mode := range money.Modes {
+		cfg := testInvoicingConfig(mode)
+		rounding := cfg.Invoicing.Presentation(cfg.Currency)
+
+		invoices, err := buildInvoices(cfg, "2026-09", adjustments, time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC), scanRecords(records))
+		if err != nil {
+			t.Fatalf("%s: buildInvoices: %v", mode, err)
+		}
+		if len(invoices) != 3 {
+			t.Fatalf("%s: got %d invoices, want 3", mode, len(invoices))
+		}
+
+		var shared, idle money.Amount
+		for _, inv := range invoices {
+			var sum money.Amount
+			for _, item := range inv.LineItems {
+				if !rounding.Round(item.Amount).Equal(item.Amount) {
+					t.Errorf("%s: %s line %q is not rounded: %s", mode, inv.CostCenter, item.Description, item.Amount)
+				}
+				sum = sum.Add(item.Amount)
+			}
+			if !inv.Total.Equal(sum) {
+				t.Errorf("%s: %s total %s, line items add up to %s", mode, inv.CostCenter, inv.Total, sum)
+			}
+			if err := invoice.Verify(&inv); err != nil {
+				t.Errorf("%s: %s: %v", mode, inv.CostCenter, err)
+			}
+			shared = shared.Add(inv.SharedCost)
+			idle = idle.Add(inv.IdleCost)
+		}
+
+		if want := rounding.Round(mustParse("10.005")); !shared.Equal(want) {
+			t.Errorf("%s: shared lines add up to %s, want %s", mode, shared, want)
+		}
+		if want := rounding.Round(mustParse("7.7777")); !idle.Equal(want) {
+			t.Errorf("%s: idle lines add up to %s, want %s", mode, idle, want)
+		}
+	}
+}
+
+func TestBuildInvoicesUnallocatedPools(t *testing.T) {
+	cfg := testInvoicingConfig(money.HalfEven)
+	records := []focus.Record{
+		podRecord("monitoring", "prometheus-0", "cpu", "1.234"),
+		nodeRecord("node-a", "2.345"),
+	}
+
+	invoices, err := buildInvoices(cfg, "2026-09", nil, time.Now(), scanRecords(records))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invoices) != 1 || invoices[0].CostCenter != cfg.Invoicing.Unallocated {
+		t.Fatalf("got %d invoices, want one for %s", len(invoices), cfg.Invoicing.Unallocated)
+	}
+	if inv := invoices[0]; !inv.SharedCost.Equal(mustParse("1.23")) || !inv.IdleCost.Equal(mustParse("2.34")) {
+		t.Errorf("unallocated shared %s and idle %s, want 1.23 and 2.34", inv.SharedCost, inv.IdleCost)
+	}
+}
+
+func testInvoicingConfig(mode string) *internal.Config {
+	return &internal.Config{
+		Currency: internal.CurrencyConfig{Base: "USD", Precision: 2, Rounding: mode},
+		Focus:    internal.FocusConfig{InvoiceIssuer: "Platform Engineering"},
+		Invoicing: internal.InvoicingConfig{
+			NumberPrefix:     "INV",
+			Precision:        2,
+			Unallocated:      "unallocated",
+			SharedNamespaces: []string{"monitoring"},
+			CostCenters: []internal.CostCenterConfig{
+				{Name: "product", Namespaces: []string{"api", "billing"}},
+				{Name: "batch", Namespaces: []string{"batch"}, Discount: 0.15},
+			},
+		},
+	}
+}
+
+func podRecord(namespace, pod, resource, cost string) focus.Record {
+	return focus.Record{
+		BillingCurrency: "USD",
+		BilledCost:      mustParse(cost),
+		ResourceName:    pod,
+		ResourceType:    "Pod",
+		SkuId:           "kubudget-" + resource,
+		Tags:            map[string]string{"kubudget/namespace": namespace},
+	}
+}
+
+func nodeRecord(node, cost string) focus.Record {
+	return focus.Record{
+		BillingCurrency: "USD",
+		BilledCost:      mustParse(cost),
+		ResourceName:    node,
+		ResourceType:    "Node",
+		SkuId:           "kubudget-idle",
+	}
+}
+
+func scanRecords(records []focus.Record) func(func(*focus.Record) error) error {
+	return func(fn func(*focus.Record) error) error {
+		for i := range records {
+			if err := fn(&records[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// mustParse parses a test amount.
+func mustParse(s string) money.Amount {
+	a, err := money.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
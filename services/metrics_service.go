@@ -3,69 +3,71 @@ package services
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/SinghaAnirban005/KuBudget/internal"
 	"github.com/SinghaAnirban005/KuBudget/pkg/kubernetes"
+	"github.com/SinghaAnirban005/KuBudget/pkg/observability"
 	"github.com/SinghaAnirban005/KuBudget/pkg/prometheus"
 )
 
+// MetricsService reports resource metrics for a single cluster.
 type MetricsService struct {
+	cluster    string
 	k8sClient  *kubernetes.Client
 	promClient *prometheus.Client
+	logger     *slog.Logger
 }
 
-func NewMetricsService(k8sClient *kubernetes.Client, promClient *prometheus.Client) *MetricsService {
+func NewMetricsService(cluster string, k8sClient *kubernetes.Client, promClient *prometheus.Client, logger *slog.Logger) *MetricsService {
 	return &MetricsService{
+		cluster:    cluster,
 		k8sClient:  k8sClient,
 		promClient: promClient,
+		logger:     logger,
 	}
 }
 
 func (s *MetricsService) GetPrometheusMetrics(ctx context.Context, namespace, pod string) (*internal.PromMetrics, error) {
+	ctx, span := observability.StartSpan(ctx, "MetricsService.GetPrometheusMetrics")
+	defer span.End()
+
 	now := time.Now()
 
-	cpuQuery := "rate(container_cpu_usage_seconds_total[5m])"
-	if namespace != "" {
-		cpuQuery = fmt.Sprintf(`rate(container_cpu_usage_seconds_total{namespace="%s"}[5m])`, namespace)
-	}
-	if pod != "" {
-		cpuQuery = fmt.Sprintf(`rate(container_cpu_usage_seconds_total{namespace="%s",pod="%s"}[5m])`, namespace, pod)
+	selector := func(metric string) *prometheus.Selector {
+		return prometheus.NewSelector(metric).EqIfSet("namespace", namespace).EqIfSet("pod", pod)
 	}
 
+	cpuQuery := prometheus.Rate(selector("container_cpu_usage_seconds_total"), 5*time.Minute)
 	cpuResult, err := s.promClient.Query(ctx, cpuQuery)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get CPU metrics: %v", err)
 	}
 
-	memQuery := "container_memory_usage_bytes"
-	if namespace != "" {
-		memQuery = fmt.Sprintf(`container_memory_usage_bytes{namespace="%s"}`, namespace)
-	}
-	if pod != "" {
-		memQuery = fmt.Sprintf(`container_memory_usage_bytes{namespace="%s",pod="%s"}`, namespace, pod)
-	}
-
+	memQuery := selector("container_memory_usage_bytes").String()
 	memResult, err := s.promClient.Query(ctx, memQuery)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get memory metrics: %v", err)
 	}
 
-	netRxQuery := "rate(container_network_receive_bytes_total[5m])"
-	netTxQuery := "rate(container_network_transmit_bytes_total[5m])"
-	if namespace != "" {
-		netRxQuery = fmt.Sprintf(`rate(container_network_receive_bytes_total{namespace="%s"}[5m])`, namespace)
-		netTxQuery = fmt.Sprintf(`rate(container_network_transmit_bytes_total{namespace="%s"}[5m])`, namespace)
+	netRxQuery := prometheus.Rate(selector("container_network_receive_bytes_total"), 5*time.Minute)
+	netTxQuery := prometheus.Rate(selector("container_network_transmit_bytes_total"), 5*time.Minute)
+	netRxResult, err := s.promClient.Query(ctx, netRxQuery)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to get network receive metrics", "error", err)
+	}
+	netTxResult, err := s.promClient.Query(ctx, netTxQuery)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to get network transmit metrics", "error", err)
 	}
-
-	netRxResult, _ := s.promClient.Query(ctx, netRxQuery)
-	netTxResult, _ := s.promClient.Query(ctx, netTxQuery)
 
 	cpuMetrics := s.convertToMetricPoints(cpuResult)
 	memMetrics := s.convertToMetricPoints(memResult)
 	networkMetrics := append(s.convertToMetricPoints(netRxResult), s.convertToMetricPoints(netTxResult)...)
 
 	return &internal.PromMetrics{
+		Cluster:        s.cluster,
 		CPUMetrics:     cpuMetrics,
 		MemoryMetrics:  memMetrics,
 		NetworkMetrics: networkMetrics,
@@ -75,20 +77,24 @@ func (s *MetricsService) GetPrometheusMetrics(ctx context.Context, namespace, po
 }
 
 func (s *MetricsService) GetClusterMetrics(ctx context.Context) (*internal.ClusterMetrics, error) {
-	nodes, err := s.k8sClient.GetNodes()
+	ctx, span := observability.StartSpan(ctx, "MetricsService.GetClusterMetrics")
+	defer span.End()
+
+	nodes, err := s.k8sClient.GetNodes(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get nodes %v", err)
 	}
 
-	namespaces, err := s.k8sClient.GetNamespaces()
+	namespaces, err := s.k8sClient.GetNamespaces(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get namespaces: %v", err)
 	}
 
 	totalPods := 0
 	for _, ns := range namespaces.Items {
-		pods, err := s.k8sClient.GetPods(ns.Name)
+		pods, err := s.k8sClient.GetPods(ctx, ns.Name)
 		if err != nil {
+			s.logger.WarnContext(ctx, "skipping namespace", "namespace", ns.Name, "error", err)
 			continue
 		}
 		totalPods += len(pods.Items)
@@ -96,7 +102,9 @@ func (s *MetricsService) GetClusterMetrics(ctx context.Context) (*internal.Clust
 
 	cpuQuery := `(1 - avg(rate(node_cpu_seconds_total{mode="idle"}[5m]))) * 100`
 	cpuResult, err := s.promClient.Query(ctx, cpuQuery)
-	fmt.Println("Hi there")
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to get cluster CPU utilization", "error", err)
+	}
 	var cpuUtilization float64
 	if err == nil && len(cpuResult.Data.Result) > 0 {
 		cpuUtilization = cpuResult.Data.Result[0].Value
@@ -104,6 +112,9 @@ func (s *MetricsService) GetClusterMetrics(ctx context.Context) (*internal.Clust
 
 	memQuery := `(1 - (node_memory_MemAvailable_bytes / node_memory_MemTotal_bytes)) * 100`
 	memResult, err := s.promClient.Query(ctx, memQuery)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to get cluster memory utilization", "error", err)
+	}
 	var memUtilization float64
 	if err == nil && len(memResult.Data.Result) > 0 {
 		memUtilization = float64(memResult.Data.Result[0].Value)
@@ -111,12 +122,14 @@ func (s *MetricsService) GetClusterMetrics(ctx context.Context) (*internal.Clust
 
 	resourceUsage, err := s.GetResourceUsage(ctx, "", "")
 	if err != nil {
+		s.logger.WarnContext(ctx, "failed to get cluster resource usage", "error", err)
 		resourceUsage = &internal.ResourceUsage{
 			Timestamp: time.Now(),
 		}
 	}
 
 	return &internal.ClusterMetrics{
+		Cluster:            s.cluster,
 		TotalNodes:         len(nodes.Items),
 		TotalPods:          totalPods,
 		TotalNamespaces:    len(namespaces.Items),
@@ -130,18 +143,24 @@ func (s *MetricsService) GetClusterMetrics(ctx context.Context) (*internal.Clust
 }
 
 func (s *MetricsService) GetResourceUsage(ctx context.Context, namespace, pod string) (*internal.ResourceUsage, error) {
+	ctx, span := observability.StartSpan(ctx, "MetricsService.GetResourceUsage")
+	defer span.End()
+
 	cpuUsage, err := s.promClient.GetCPUUsage(ctx, namespace, pod)
 	if err != nil {
+		s.logger.DebugContext(ctx, "failed to get CPU usage, assuming zero", "namespace", namespace, "pod", pod, "error", err)
 		cpuUsage = 0
 	}
 
 	memoryUsage, err := s.promClient.GetMemoryUsage(ctx, namespace, pod)
 	if err != nil {
+		s.logger.DebugContext(ctx, "failed to get memory usage, assuming zero", "namespace", namespace, "pod", pod, "error", err)
 		memoryUsage = 0
 	}
 
 	rxBytes, txBytes, err := s.promClient.GetNetworkIO(ctx, namespace, pod)
 	if err != nil {
+		s.logger.DebugContext(ctx, "failed to get network I/O, assuming zero", "namespace", namespace, "pod", pod, "error", err)
 		rxBytes = 0
 		txBytes = 0
 	}
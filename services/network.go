@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"maps"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/money"
+	"github.com/SinghaAnirban005/KuBudget/pkg/prometheus"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Traffic classes of pod egress.
+const (
+	trafficIntraZone    = "intra_zone"
+	trafficCrossZone    = "cross_zone"
+	trafficInternet     = "internet"
+	trafficUnclassified = "unclassified"
+)
+
+// costInputs is what pricing a cluster's pods needs beyond each pod's own
+// metrics. It is gathered once per request.
+type costInputs struct {
+	// instanceTypes maps node names to instance types, and is nil unless
+	// extended resources are priced per instance type.
+	instanceTypes map[string]string
+	// egress holds the bytes per second each pod, keyed namespace/pod,
+	// sends by traffic class, and is nil without a flow metric.
+	egress map[string]map[string]float64
+	// storage holds the GB of persistent volume claims each pod, keyed
+	// namespace/pod, mounts, and is nil unless storage is priced.
+	storage map[string]float64
+}
+
+// costInputs lists what the current configuration needs. Failures are
+// logged and leave the affected input empty, so that pods are still priced.
+func (s *CostService) costInputs(ctx context.Context, namespace string) *costInputs {
+	cfg := s.config.Current()
+	pricing := cfg.PricingFor(s.cluster)
+	inputs := &costInputs{}
+	if pricing.StorageCostPerGB > 0 {
+		inputs.storage = s.claimedStorage(ctx, namespace)
+	}
+
+	perType := false
+	for _, resource := range pricing.ExtendedResources {
+		perType = perType || len(resource.InstanceTypes) > 0
+	}
+	flows := cfg.Network.FlowMetric != ""
+	if !perType && !flows {
+		return inputs
+	}
+
+	nodes, err := s.k8sClient.GetNodes(ctx)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to get nodes, using default extended resource rates and unclassified network traffic", "error", err)
+		return inputs
+	}
+	if perType {
+		inputs.instanceTypes = make(map[string]string, len(nodes.Items))
+		for _, node := range nodes.Items {
+			inputs.instanceTypes[node.Name] = instanceType(node.Labels)
+		}
+	}
+	if !flows {
+		return inputs
+	}
+
+	pods, err := s.k8sClient.GetPods(ctx, "")
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to get pods, leaving network traffic unclassified", "error", err)
+		return inputs
+	}
+	samples, err := s.promClient.QueryVector(ctx, flowQuery(cfg.Network, namespace))
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to query network flows, leaving network traffic unclassified", "error", err)
+		return inputs
+	}
+	inputs.egress = classifyFlows(samples, newNetworkTopology(nodes.Items, pods.Items), cfg.Network)
+	return inputs
+}
+
+// networkCost prices the bytes a pod sends by traffic class. Without a flow
+// metric, the pod's transmitted bytes from cAdvisor are unclassified.
+func (s *CostService) networkCost(ctx context.Context, pod corev1.Pod, inputs *costInputs) (money.Amount, map[string]money.Amount) {
+	cfg := s.config.Current()
+	pricing := cfg.PricingFor(s.cluster).Network
+
+	egress := inputs.egress[pod.Namespace+"/"+pod.Name]
+	if cfg.Network.FlowMetric == "" {
+		_, txBytes, err := s.promClient.GetNetworkIO(ctx, pod.Namespace, pod.Name)
+		if err != nil {
+			s.logger.DebugContext(ctx, "failed to get network I/O, assuming zero", "namespace", pod.Namespace, "pod", pod.Name, "error", err)
+			txBytes = 0
+		}
+		egress = map[string]float64{trafficUnclassified: txBytes}
+	}
+
+	var total money.Amount
+	costs := make(map[string]money.Amount, len(egress))
+	for class, bytesPerSecond := range egress {
+		cost := money.FromFloat(networkRate(pricing, class)).Mul(bytesPerSecond * time.Hour.Seconds() / bytesPerGB)
+		if cost.IsPositive() {
+			costs[class] = cost
+			total = total.Add(cost)
+		}
+	}
+	if len(costs) == 0 {
+		return money.Zero, nil
+	}
+	return total, costs
+}
+
+// networkRate is the price per GB of a traffic class.
+func networkRate(pricing internal.NetworkPricing, class string) float64 {
+	switch class {
+	case trafficIntraZone:
+		return pricing.IntraZoneCostPerGB
+	case trafficInternet:
+		return pricing.InternetEgressCostPerGB
+	default:
+		return pricing.CrossZoneCostPerGB
+	}
+}
+
+// flowQuery sums the byte rate of the flow metric by sending pod and by
+// destination, optionally for one namespace.
+func flowQuery(cfg internal.NetworkConfig, namespace string) string {
+	selector := prometheus.NewSelector(cfg.FlowMetric).EqIfSet(cfg.SourceNamespaceLabel, namespace)
+	for _, label := range slices.Sorted(maps.Keys(cfg.Matchers)) {
+		selector.Eq(label, cfg.Matchers[label])
+	}
+
+	by := []string{cfg.SourceNamespaceLabel, cfg.SourcePodLabel}
+	for _, label := range []string{cfg.DestinationZoneLabel, cfg.DestinationNamespaceLabel, cfg.DestinationPodLabel, cfg.DestinationIPLabel} {
+		if label != "" {
+			by = append(by, label)
+		}
+	}
+	return "sum by (" + strings.Join(by, ", ") + ") (" + prometheus.Rate(selector, 5*time.Minute) + ")"
+}
+
+// networkTopology places pods, pod IPs and node IPs in zones by the
+// topology labels of their nodes.
+type networkTopology struct {
+	pods map[string]string
+	ips  map[netip.Addr]string
+}
+
+func newNetworkTopology(nodes []corev1.Node, pods []corev1.Pod) *networkTopology {
+	t := &networkTopology{
+		pods: make(map[string]string, len(pods)),
+		ips:  make(map[netip.Addr]string, len(pods)+len(nodes)),
+	}
+
+	zones := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		zone := nodeZone(node.Labels)
+		zones[node.Name] = zone
+		for _, address := range node.Status.Addresses {
+			if ip, err := netip.ParseAddr(address.Address); err == nil && zone != "" {
+				t.ips[ip.Unmap()] = zone
+			}
+		}
+	}
+
+	for _, pod := range pods {
+		zone := zones[pod.Spec.NodeName]
+		if zone == "" {
+			continue
+		}
+		t.pods[pod.Namespace+"/"+pod.Name] = zone
+		if pod.Spec.HostNetwork {
+			continue
+		}
+		for _, podIP := range pod.Status.PodIPs {
+			if ip, err := netip.ParseAddr(podIP.IP); err == nil {
+				t.ips[ip.Unmap()] = zone
+			}
+		}
+	}
+	return t
+}
+
+// classify returns the traffic class of a flow from a pod in sourceZone to
+// the destination described by labels. The destination is located by its
+// zone label, then its pod, then its IP. Public IPs and flows without any
+// in-cluster destination are internet egress. Private destinations whose
+// zone is unknown, and flows from pods whose zone is unknown, are priced
+// as cross-zone.
+func (t *networkTopology) classify(sourceZone string, labels map[string]string, cfg internal.NetworkConfig) string {
+	destZone := labels[cfg.DestinationZoneLabel]
+	namespace, pod := labels[cfg.DestinationNamespaceLabel], labels[cfg.DestinationPodLabel]
+	if destZone == "" && namespace != "" && pod != "" {
+		destZone = t.pods[namespace+"/"+pod]
+	}
+
+	inCluster := destZone != "" || namespace != ""
+	if destZone == "" && cfg.DestinationIPLabel != "" {
+		if ip, err := netip.ParseAddr(labels[cfg.DestinationIPLabel]); err == nil {
+			ip = ip.Unmap()
+			destZone = t.ips[ip]
+			inCluster = inCluster || destZone != "" || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast()
+		}
+	}
+
+	switch {
+	case !inCluster:
+		return trafficInternet
+	case sourceZone != "" && destZone == sourceZone:
+		return trafficIntraZone
+	default:
+		return trafficCrossZone
+	}
+}
+
+// classifyFlows sums flow samples into bytes per second by sending pod and
+// traffic class.
+func classifyFlows(samples []prometheus.VectorSample, topology *networkTopology, cfg internal.NetworkConfig) map[string]map[string]float64 {
+	egress := make(map[string]map[string]float64)
+	for _, sample := range samples {
+		namespace, pod := sample.Labels[cfg.SourceNamespaceLabel], sample.Labels[cfg.SourcePodLabel]
+		if namespace == "" || pod == "" || sample.Value <= 0 {
+			continue
+		}
+		key := namespace + "/" + pod
+		class := topology.classify(topology.pods[key], sample.Labels, cfg)
+		if egress[key] == nil {
+			egress[key] = make(map[string]float64)
+		}
+		egress[key][class] += sample.Value
+	}
+	return egress
+}
+
+// nodeZone returns the zone of a node from its stable or beta topology
+// label.
+func nodeZone(labels map[string]string) string {
+	if zone := labels[corev1.LabelTopologyZone]; zone != "" {
+		return zone
+	}
+	return labels[corev1.LabelFailureDomainBetaZone]
+}
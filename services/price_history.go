@@ -0,0 +1,269 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/observability"
+)
+
+const priceVersionsFile = "price-versions.json"
+
+// priceCheckInterval is how often the configuration is checked for new
+// pricing. A hot-reloaded change takes effect from when it is noticed.
+const priceCheckInterval = 15 * time.Second
+
+var ErrPriceVersionNotFound = errors.New("price version not found")
+
+// PriceHistory holds the price versions, oldest first. Cost history is
+// priced with the version in effect at each point; without versions, or
+// for clusters a version does not cover, the current pricing applies.
+type PriceHistory struct {
+	mu       sync.RWMutex
+	versions []internal.PriceVersion
+}
+
+func NewPriceHistory() *PriceHistory {
+	return &PriceHistory{}
+}
+
+// Set replaces the versions.
+func (h *PriceHistory) Set(versions []internal.PriceVersion) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.versions = versions
+}
+
+// Versions returns the versions, oldest first.
+func (h *PriceHistory) Versions() []internal.PriceVersion {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.versions
+}
+
+// Version returns the version with the given ID.
+func (h *PriceHistory) Version(id int) (internal.PriceVersion, error) {
+	for _, version := range h.Versions() {
+		if version.ID == id {
+			return version, nil
+		}
+	}
+	return internal.PriceVersion{}, fmt.Errorf("%w: %d", ErrPriceVersionNotFound, id)
+}
+
+// PricingAt returns the pricing of cluster in effect at t. Times before
+// the first version use the first version, the oldest rates known.
+func (h *PriceHistory) PricingAt(cfg *internal.Config, cluster string, t time.Time) internal.PricingConfig {
+	versions := h.Versions()
+	i := sort.Search(len(versions), func(i int) bool {
+		return versions[i].EffectiveFrom.After(t)
+	})
+	if i > 0 {
+		i--
+	}
+	if i < len(versions) {
+		if pricing, ok := versions[i].Pricing[cluster]; ok {
+			return pricing
+		}
+	}
+	return cfg.PricingFor(cluster)
+}
+
+// PriceHistoryService records a new price version whenever the configured
+// pricing of any cluster changes, persists the versions under the storage
+// path and publishes them to the cluster service.
+type PriceHistoryService struct {
+	clusters *ClusterService
+	config   *internal.ConfigStore
+	logger   *slog.Logger
+
+	mu sync.Mutex
+}
+
+// NewPriceHistoryService loads the stored versions and records the current
+// pricing if it differs from the latest of them.
+func NewPriceHistoryService(clusters *ClusterService, config *internal.ConfigStore, logger *slog.Logger) (*PriceHistoryService, error) {
+	s := &PriceHistoryService{
+		clusters: clusters,
+		config:   config,
+		logger:   logger,
+	}
+
+	storagePath := config.Current().PriceHistory.StoragePath
+	if err := os.MkdirAll(storagePath, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create price history storage directory: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(storagePath, priceVersionsFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read price versions: %v", err)
+	}
+	if len(data) > 0 {
+		var versions []internal.PriceVersion
+		if err := json.Unmarshal(data, &versions); err != nil {
+			return nil, fmt.Errorf("failed to parse price versions: %v", err)
+		}
+		for _, version := range versions {
+			for cluster, pricing := range version.Pricing {
+				if err := pricing.ParseSchedules(); err != nil {
+					return nil, fmt.Errorf("failed to parse price version %d of cluster %s: %v", version.ID, cluster, err)
+				}
+				version.Pricing[cluster] = pricing
+			}
+		}
+		clusters.PriceHistory().Set(versions)
+	}
+
+	if _, err := s.Record(time.Now()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Run records pricing changes made by config reloads until ctx is
+// cancelled.
+func (s *PriceHistoryService) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(priceCheckInterval):
+		}
+
+		if _, err := s.Record(time.Now()); err != nil {
+			s.logger.ErrorContext(ctx, "failed to record price version", "error", err)
+		}
+	}
+}
+
+// Record adds a version effective from now when the current pricing of
+// any cluster differs from the latest version, and reports whether it did.
+func (s *PriceHistoryService) Record(now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg := s.config.Current()
+	pricing := make(map[string]internal.PricingConfig)
+	for _, cluster := range cfg.ClusterConfigs() {
+		pricing[cluster.Name] = cfg.PricingFor(cluster.Name)
+	}
+
+	prices := s.clusters.PriceHistory()
+	versions := prices.Versions()
+	if n := len(versions); n > 0 {
+		same, err := samePricing(versions[n-1].Pricing, pricing)
+		if err != nil {
+			return false, err
+		}
+		if same {
+			return false, nil
+		}
+	}
+
+	version := internal.PriceVersion{
+		ID:            len(versions) + 1,
+		EffectiveFrom: now.UTC(),
+		Pricing:       pricing,
+	}
+	if n := len(versions); n > 0 && !version.EffectiveFrom.After(versions[n-1].EffectiveFrom) {
+		// Keep versions ordered even if the clock steps back.
+		version.EffectiveFrom = versions[n-1].EffectiveFrom.Add(time.Second)
+	}
+	updated := append(versions[:len(versions):len(versions)], version)
+
+	data, err := json.Marshal(updated)
+	if err != nil {
+		return false, err
+	}
+	if err := writeFileAtomic(filepath.Join(cfg.PriceHistory.StoragePath, priceVersionsFile), data); err != nil {
+		return false, fmt.Errorf("failed to persist price versions: %v", err)
+	}
+	prices.Set(updated)
+
+	s.logger.Info("recorded price version", "version", version.ID, "effective_from", version.EffectiveFrom)
+	return true, nil
+}
+
+// Versions returns the recorded versions, oldest first.
+func (s *PriceHistoryService) Versions() []internal.PriceVersion {
+	return s.clusters.PriceHistory().Versions()
+}
+
+// Compare prices the usage of [start, end) on clusters as it was priced
+// and under version id, for comparison, converted by converter.
+func (s *PriceHistoryService) Compare(ctx context.Context, clusters []*Cluster, id int, start, end time.Time, step time.Duration, namespace string, converter *CurrencyConverter) (*internal.PriceComparison, error) {
+	ctx, span := observability.StartSpan(ctx, "PriceHistoryService.Compare")
+	defer span.End()
+
+	version, err := s.clusters.PriceHistory().Version(id)
+	if err != nil {
+		return nil, err
+	}
+
+	results := fanOut(ctx, clusters, func(ctx context.Context, cluster *Cluster) ([2]*internal.CostHistory, error) {
+		actual, repriced, err := cluster.Cost.CompareCostHistory(ctx, start, end, step, namespace, version)
+		return [2]*internal.CostHistory{actual, repriced}, err
+	})
+	pairs, err := collect(ctx, s.logger, results)
+	if err != nil {
+		return nil, err
+	}
+
+	var actual, repriced []*internal.CostHistory
+	for _, pair := range pairs {
+		for _, history := range pair {
+			if err := converter.ConvertHistory(history, step); err != nil {
+				return nil, err
+			}
+		}
+		actual = append(actual, pair[0])
+		repriced = append(repriced, pair[1])
+	}
+
+	period := end.Sub(start).String()
+	comparison := &internal.PriceComparison{
+		Version:   id,
+		Currency:  converter.Currency,
+		StartTime: start,
+		EndTime:   end,
+		Step:      step.String(),
+	}
+	if len(pairs) == 1 {
+		comparison.Actual, comparison.Repriced = actual[0], repriced[0]
+	} else {
+		comparison.Actual, comparison.Repriced = mergeCostHistories(period, actual), mergeCostHistories(period, repriced)
+	}
+	for _, point := range comparison.Actual.Data {
+		comparison.ActualCost = comparison.ActualCost.Add(point.TotalCost.Mul(step.Hours()))
+	}
+	for _, point := range comparison.Repriced.Data {
+		comparison.RepricedCost = comparison.RepricedCost.Add(point.TotalCost.Mul(step.Hours()))
+	}
+	comparison.Difference = comparison.RepricedCost.Sub(comparison.ActualCost)
+
+	return comparison, nil
+}
+
+// samePricing compares pricings by their JSON encoding, so that a map left
+// empty in the config equals one omitted from a stored version.
+func samePricing(a, b map[string]internal.PricingConfig) (bool, error) {
+	encodedA, err := json.Marshal(a)
+	if err != nil {
+		return false, err
+	}
+	encodedB, err := json.Marshal(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(encodedA, encodedB), nil
+}
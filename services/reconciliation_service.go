@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SinghaAnirban005/KuBudget/internal"
+	"github.com/SinghaAnirban005/KuBudget/pkg/billing"
+	"github.com/SinghaAnirban005/KuBudget/pkg/observability"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// minBilledHours keeps resources seen only briefly in the billing data
+	// from producing wild factors.
+	minBilledHours = 1.0
+
+	bytesPerGB = 1024 * 1024 * 1024
+)
+
+var ErrNotReconciled = errors.New("reconciliation has not run yet")
+
+// billedResource is the total billed cost of one cloud resource and the
+// usage intervals its line items cover.
+type billedResource struct {
+	cost      float64
+	intervals [][2]time.Time
+}
+
+// ReconciliationService matches line items from cloud billing exports to
+// the nodes and volumes of each cluster and derives the factors that scale
+// allocated costs to what was actually paid.
+type ReconciliationService struct {
+	clusters *ClusterService
+	config   *internal.ConfigStore
+	logger   *slog.Logger
+
+	mu     sync.RWMutex
+	report *internal.ReconciliationReport
+}
+
+func NewReconciliationService(clusters *ClusterService, config *internal.ConfigStore, logger *slog.Logger) *ReconciliationService {
+	return &ReconciliationService{
+		clusters: clusters,
+		config:   config,
+		logger:   logger,
+	}
+}
+
+// Run reconciles immediately and then on the configured interval until ctx
+// is cancelled.
+func (s *ReconciliationService) Run(ctx context.Context) {
+	for {
+		if _, err := s.Reconcile(ctx); err != nil {
+			s.logger.ErrorContext(ctx, "cost reconciliation failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.config.Current().Reconciliation.Interval):
+		}
+	}
+}
+
+// Report returns the result of the last reconciliation.
+func (s *ReconciliationService) Report() (*internal.ReconciliationReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.report == nil {
+		return nil, ErrNotReconciled
+	}
+	return s.report, nil
+}
+
+// Reconcile reloads the billing files, recomputes every factor and
+// publishes them to the cluster service. Files that fail to load are
+// reported and skipped; an error is returned only when none could be read.
+func (s *ReconciliationService) Reconcile(ctx context.Context) (*internal.ReconciliationReport, error) {
+	ctx, span := observability.StartSpan(ctx, "ReconciliationService.Reconcile")
+	defer span.End()
+
+	cfg := s.config.Current()
+	report := &internal.ReconciliationReport{
+		GeneratedAt:    time.Now(),
+		Currency:       cfg.Currency.Base,
+		Files:          make([]string, 0),
+		Resources:      make([]internal.ResourceAdjustment, 0),
+		StorageFactors: make(map[string]float64),
+	}
+
+	billed := make(map[string]*billedResource)
+	for _, file := range cfg.Reconciliation.Files {
+		paths, err := filepath.Glob(file.Path)
+		if err != nil || len(paths) == 0 {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: no matching files", file.Path))
+			continue
+		}
+		for _, path := range paths {
+			items, err := billing.Load(path, file.Format)
+			if err != nil {
+				s.logger.WarnContext(ctx, "skipping billing file", "path", path, "error", err)
+				report.Errors = append(report.Errors, err.Error())
+				continue
+			}
+			report.Files = append(report.Files, path)
+			report.LineItems += len(items)
+
+			for _, item := range items {
+				key := billing.ResourceKey(item.ResourceID)
+				resource, ok := billed[key]
+				if !ok {
+					resource = &billedResource{}
+					billed[key] = resource
+				}
+				resource.cost += item.Cost
+				resource.intervals = append(resource.intervals, [2]time.Time{item.Start, item.End})
+				report.BilledCost += item.Cost
+			}
+		}
+	}
+	if len(report.Files) == 0 {
+		return nil, fmt.Errorf("no billing files could be read: %s", strings.Join(report.Errors, "; "))
+	}
+
+	nodeFactors := make(map[nodeRef]float64)
+	matched := make(map[string]bool)
+	for _, cluster := range s.clusters.Clusters() {
+		pricing := cfg.PricingFor(cluster.Name)
+
+		nodes, err := cluster.K8sClient.GetNodes(ctx)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("cluster %s: failed to get nodes: %v", cluster.Name, err))
+		} else {
+			for _, adjustment := range reconcileNodes(cluster.Name, nodes.Items, pricing, billed, matched) {
+				nodeFactors[nodeRef{cluster.Name, adjustment.Name}] = adjustment.Factor
+				report.Resources = append(report.Resources, adjustment)
+			}
+		}
+
+		volumes, err := cluster.K8sClient.GetPersistentVolumes(ctx)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("cluster %s: failed to get persistent volumes: %v", cluster.Name, err))
+			continue
+		}
+		var billedCost, estimatedCost float64
+		for _, pv := range volumes.Items {
+			id := volumeID(pv)
+			resource, ok := billed[billing.ResourceKey(id)]
+			if id == "" || !ok {
+				continue
+			}
+			capacity := pv.Spec.Capacity[corev1.ResourceStorage]
+			adjustment, ok := adjust(resource, float64(capacity.Value())/bytesPerGB*pricing.StorageCostPerGB)
+			if !ok {
+				continue
+			}
+			adjustment.Cluster, adjustment.Kind, adjustment.Name, adjustment.ResourceID = cluster.Name, "volume", pv.Name, id
+			report.Resources = append(report.Resources, adjustment)
+			matched[billing.ResourceKey(id)] = true
+
+			billedCost += adjustment.BilledCost
+			estimatedCost += adjustment.EstimatedCost
+		}
+		if estimatedCost > 0 {
+			report.StorageFactors[cluster.Name] = billedCost / estimatedCost
+		}
+	}
+
+	sort.Slice(report.Resources, func(i, j int) bool {
+		a, b := report.Resources[i], report.Resources[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+
+	for key := range matched {
+		report.MatchedCost += billed[key].cost
+	}
+	report.UnmatchedCost = report.BilledCost - report.MatchedCost
+
+	s.clusters.Adjustments().Set(nodeFactors, report.StorageFactors)
+
+	s.mu.Lock()
+	s.report = report
+	s.mu.Unlock()
+
+	s.logger.InfoContext(ctx, "reconciled costs with billing data",
+		"files", len(report.Files), "line_items", report.LineItems, "resources", len(report.Resources),
+		"matched_cost", report.MatchedCost, "unmatched_cost", report.UnmatchedCost)
+
+	return report, nil
+}
+
+// reconcileNodes matches nodes to billed instances by provider ID. AKS
+// bills scale set instances under the scale set itself, so a node that is
+// not billed individually falls back to its scale set, whose cost is
+// shared among its nodes in proportion to their list prices.
+func reconcileNodes(cluster string, nodes []corev1.Node, pricing internal.PricingConfig, billed map[string]*billedResource, matched map[string]bool) []internal.ResourceAdjustment {
+	var adjustments []internal.ResourceAdjustment
+	groups := make(map[string][]corev1.Node)
+
+	for _, node := range nodes {
+		key := billing.ResourceKey(node.Spec.ProviderID)
+		if key == "" {
+			continue
+		}
+
+		resource, ok := billed[key]
+		if !ok {
+			if group, _, found := strings.Cut(key, "/virtualmachines/"); found && billed[group] != nil {
+				groups[group] = append(groups[group], node)
+			}
+			continue
+		}
+
+		adjustment, ok := adjust(resource, nodeHourlyListCost(node, pricing))
+		if !ok {
+			continue
+		}
+		adjustment.Cluster, adjustment.Kind, adjustment.Name, adjustment.ResourceID = cluster, "node", node.Name, node.Spec.ProviderID
+		adjustments = append(adjustments, adjustment)
+		matched[key] = true
+	}
+
+	for group, members := range groups {
+		var hourly float64
+		for _, node := range members {
+			hourly += nodeHourlyListCost(node, pricing)
+		}
+		adjustment, ok := adjust(billed[group], hourly)
+		if !ok {
+			continue
+		}
+		for _, node := range members {
+			share := nodeHourlyListCost(node, pricing) / hourly
+			adjustments = append(adjustments, internal.ResourceAdjustment{
+				Cluster:       cluster,
+				Kind:          "node",
+				Name:          node.Name,
+				ResourceID:    node.Spec.ProviderID,
+				BilledHours:   adjustment.BilledHours,
+				EstimatedCost: adjustment.EstimatedCost * share,
+				BilledCost:    adjustment.BilledCost * share,
+				Factor:        adjustment.Factor,
+			})
+		}
+		matched[group] = true
+	}
+
+	return adjustments
+}
+
+// adjust compares a billed resource with its list price per hour over the
+// hours its line items cover. It reports false when there is too little
+// billing data or no estimate to compare with.
+func adjust(resource *billedResource, hourlyListCost float64) (internal.ResourceAdjustment, bool) {
+	hours := coveredHours(resource.intervals)
+	estimated := hourlyListCost * hours
+	if hours < minBilledHours || estimated <= 0 {
+		return internal.ResourceAdjustment{}, false
+	}
+
+	return internal.ResourceAdjustment{
+		BilledHours:   hours,
+		EstimatedCost: estimated,
+		BilledCost:    resource.cost,
+		Factor:        resource.cost / estimated,
+	}, true
+}
+
+// coveredHours is the length of the union of intervals, so that several
+// line items for the same hour, such as usage and a credit, count once.
+func coveredHours(intervals [][2]time.Time) float64 {
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i][0].Before(intervals[j][0])
+	})
+
+	var total time.Duration
+	var start, end time.Time
+	for i, interval := range intervals {
+		if i == 0 || interval[0].After(end) {
+			total += end.Sub(start)
+			start, end = interval[0], interval[1]
+			continue
+		}
+		if interval[1].After(end) {
+			end = interval[1]
+		}
+	}
+	total += end.Sub(start)
+
+	return total.Hours()
+}
+
+// nodeHourlyListCost prices a node's full capacity, extended resources
+// included, at the configured rates.
+func nodeHourlyListCost(node corev1.Node, pricing internal.PricingConfig) float64 {
+	cpu := node.Status.Capacity[corev1.ResourceCPU]
+	memory := node.Status.Capacity[corev1.ResourceMemory]
+	extended, _ := nodeExtendedResourceCosts(node, nil, pricing)
+	return cpu.AsApproximateFloat64()*pricing.CPUCostPerHour + float64(memory.Value())/bytesPerGB*pricing.MemoryCostPerGB + sumCosts(extended).Float64()
+}
+
+// volumeID returns the cloud disk ID backing a persistent volume.
+func volumeID(pv corev1.PersistentVolume) string {
+	switch {
+	case pv.Spec.CSI != nil:
+		return pv.Spec.CSI.VolumeHandle
+	case pv.Spec.AWSElasticBlockStore != nil:
+		return pv.Spec.AWSElasticBlockStore.VolumeID
+	case pv.Spec.AzureDisk != nil:
+		return pv.Spec.AzureDisk.DataDiskURI
+	default:
+		return ""
+	}
+}